@@ -15,6 +15,6 @@ var (
 func main() {
 	a := app.New(version, commit, date)
 	if err := a.Execute(); err != nil {
-		os.Exit(1)
+		os.Exit(app.ExitCode(err))
 	}
 }
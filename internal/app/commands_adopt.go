@@ -0,0 +1,171 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/openhoo/vibecontainer/internal/config"
+	"github.com/openhoo/vibecontainer/internal/docker"
+	"github.com/openhoo/vibecontainer/internal/domain"
+	"github.com/openhoo/vibecontainer/internal/keyring"
+	"github.com/openhoo/vibecontainer/internal/stack"
+	"github.com/openhoo/vibecontainer/internal/tui"
+	"github.com/openhoo/vibecontainer/internal/validate"
+	"github.com/spf13/cobra"
+)
+
+func newAdoptCmd(runs *stack.RunStore, compose docker.Backend) *cobra.Command {
+	name := ""
+	authProfile := ""
+	recreate := false
+	yes := false
+
+	cmd := &cobra.Command{
+		Use:   "adopt <container>",
+		Short: "Manage an existing vibecontainer container as a stack",
+		Long: "Reconstruct stack settings from a container started with docker run or compose\n" +
+			"and save them as a managed stack. The container keeps running until the stack\n" +
+			"is next started, when it is replaced by one with vibecontainer's labels.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			authProfile = domain.CredentialProfile(authProfile)
+			if err := validate.AuthProfile(authProfile); err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), 60*time.Second)
+			defer cancel()
+
+			info, err := compose.Inspect(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			imageEnv, err := compose.ImageEnv(ctx, info.Image)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Warning: failed to read image environment; keeping all variables:", err)
+			}
+			opts, warnings := stack.OptionsFromContainer(info, imageEnv)
+			if name != "" {
+				opts.Name = name
+			}
+			// Credentials passed to the container win over the keychain.
+			opts.AuthProfile = authProfile
+			opts.Auth = fillAuth(opts.Auth, keyring.NewProfile(authProfile).LoadAuth())
+			for _, w := range warnings {
+				fmt.Fprintln(os.Stderr, "Warning:", w)
+			}
+			if err := validate.CreateOptions(opts); err != nil {
+				return fmt.Errorf("container %s can't be adopted: %w", info.Name, err)
+			}
+			lock, err := runs.Lock(opts.Name)
+			if err != nil {
+				return err
+			}
+			defer lock.Unlock()
+			if runs.Exists(opts.Name) {
+				return fmt.Errorf("stack %q already exists; pick another with --name", opts.Name)
+			}
+
+			fmt.Printf("Adopting %s as stack %s:\n", info.Name, opts.Name)
+			image := opts.Image
+			if image == "" {
+				image = stack.DefaultImage(opts.Provider)
+			}
+			tui.RenderStackInfo(domain.RunMetadata{
+				Name:      opts.Name,
+				Provider:  opts.Provider,
+				Image:     image,
+				Workspace: opts.WorkspacePath,
+				Spec:      domain.SpecFromOptions(opts),
+			})
+			if recreate && !yes {
+				ok, err := tui.Confirm("Recreate the container now?", info.Name+" will be removed and replaced.", true)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return fmt.Errorf("adopt canceled")
+				}
+			}
+
+			if _, err := runs.Adopt(cmd.Context(), opts, info.Name); err != nil {
+				return fmt.Errorf("save stack config: %w", err)
+			}
+			fmt.Printf("Adopted %s as stack %s\n", info.Name, opts.Name)
+			fmt.Printf("Run dir: %s\n", config.RunDir(opts.Name))
+			if !recreate {
+				fmt.Printf("Run `vibecontainer start --name %s` to replace the container with a managed one.\n", opts.Name)
+				return nil
+			}
+			if err := upStack(cmd.Context(), runs, compose, opts.Name); err != nil {
+				return err
+			}
+			fmt.Printf("Recreated stack %s\n", opts.Name)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "", "stack name (default: from the container's labels or name)")
+	cmd.Flags().StringVar(&authProfile, "auth-profile", "", "keychain credential profile to fill in credentials the container lacks (default \"default\")")
+	cmd.Flags().BoolVar(&recreate, "recreate", false, "replace the container with a managed one right away")
+	cmd.Flags().BoolVar(&yes, "yes", false, "recreate without confirmation")
+	return cmd
+}
+
+// upTimeout bounds starting a stack's containers. Secrets are resolved
+// before it starts, under the secret managers' own timeouts, so a slow
+// unlock prompt doesn't eat into it.
+const upTimeout = 60 * time.Second
+
+// upStack starts a stack's services. Secret references are resolved again
+// first. A container the stack was adopted from is stopped so the stack's
+// own can take its ports, and removed only once they are up; if they can't
+// be started, it is started again.
+func upStack(ctx context.Context, runs *stack.RunStore, compose docker.Backend, name string) error {
+	meta, err := runs.Load(name)
+	if err != nil {
+		return err
+	}
+	// Resolve secrets before anything is stopped, so a reference that
+	// can't be resolved leaves an adopted container running.
+	if err := runs.RefreshSecrets(ctx, name); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, upTimeout)
+	defer cancel()
+	if meta.AdoptedFrom != "" {
+		if err := compose.StopContainer(ctx, meta.AdoptedFrom); err != nil {
+			return fmt.Errorf("stop adopted container %s: %w", meta.AdoptedFrom, err)
+		}
+	}
+	if err := compose.Up(ctx, name); err != nil {
+		if meta.AdoptedFrom != "" {
+			restoreAdopted(compose, name, meta.AdoptedFrom)
+		}
+		return err
+	}
+	if meta.AdoptedFrom != "" {
+		if err := runs.FinishAdoption(name); err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: failed to update metadata:", err)
+		}
+		if err := compose.RemoveContainer(ctx, meta.AdoptedFrom); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to remove adopted container %s: %v\n", meta.AdoptedFrom, err)
+		}
+	}
+	return nil
+}
+
+// restoreAdopted removes whatever a failed up started for a stack and starts
+// the container it was adopted from again. It uses its own context since
+// the up's may have been canceled.
+func restoreAdopted(compose docker.Backend, name, container string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	if err := compose.Down(ctx, name); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to remove the containers of %s: %v\n", name, err)
+	}
+	if err := compose.StartContainer(ctx, container); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to restart adopted container %s: %v\n", container, err)
+	}
+}
@@ -0,0 +1,109 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/adrg/xdg"
+	"github.com/openhoo/vibecontainer/internal/docker"
+	"github.com/openhoo/vibecontainer/internal/domain"
+	"github.com/openhoo/vibecontainer/internal/stack"
+)
+
+// fakeBackend records the calls the app makes and fails those named in
+// errs. Methods the tests don't need panic through the nil embedded
+// interface.
+type fakeBackend struct {
+	docker.Backend
+	calls []string
+	errs  map[string]error
+}
+
+func (f *fakeBackend) record(call string) error {
+	f.calls = append(f.calls, call)
+	return f.errs[call]
+}
+
+func (f *fakeBackend) Up(_ context.Context, stack string, _ ...string) error {
+	return f.record("up " + stack)
+}
+
+func (f *fakeBackend) Down(_ context.Context, stack string) error {
+	return f.record("down " + stack)
+}
+
+func (f *fakeBackend) StopContainer(_ context.Context, container string) error {
+	return f.record("stop " + container)
+}
+
+func (f *fakeBackend) StartContainer(_ context.Context, container string) error {
+	return f.record("start " + container)
+}
+
+func (f *fakeBackend) RemoveContainer(_ context.Context, container string) error {
+	return f.record("rm " + container)
+}
+
+// useTempDataDir points the XDG data and config dirs, and with them the run
+// store and the secrets file, at fresh temp directories for the duration of
+// the test.
+func useTempDataDir(t *testing.T) {
+	t.Helper()
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	xdg.Reload()
+	t.Cleanup(xdg.Reload)
+}
+
+func TestUpStackReplacesAdoptedContainer(t *testing.T) {
+	tests := []struct {
+		name    string
+		errs    map[string]error
+		calls   []string
+		adopted string
+	}{
+		{
+			name:  "up succeeds",
+			calls: []string{"stop agent", "up demo", "rm agent"},
+		},
+		{
+			name:    "up fails",
+			errs:    map[string]error{"up demo": errors.New("port in use")},
+			calls:   []string{"stop agent", "up demo", "down demo", "start agent"},
+			adopted: "agent",
+		},
+		{
+			name:    "stop fails",
+			errs:    map[string]error{"stop agent": errors.New("no such container")},
+			calls:   []string{"stop agent"},
+			adopted: "agent",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempDataDir(t)
+			runs := stack.NewRunStore()
+			opts := domain.CreateOptions{Name: "demo", Provider: domain.ProviderBase, TmuxAccess: "none"}
+			if _, err := runs.Adopt(context.Background(), opts, "agent"); err != nil {
+				t.Fatalf("Adopt failed: %v", err)
+			}
+			compose := &fakeBackend{errs: tt.errs}
+			err := upStack(context.Background(), runs, compose, "demo")
+			if (err != nil) != (tt.errs != nil) {
+				t.Fatalf("upStack returned %v", err)
+			}
+			if !slices.Equal(compose.calls, tt.calls) {
+				t.Fatalf("calls = %q, want %q", compose.calls, tt.calls)
+			}
+			meta, err := runs.Load("demo")
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if meta.AdoptedFrom != tt.adopted {
+				t.Fatalf("AdoptedFrom = %q, want %q", meta.AdoptedFrom, tt.adopted)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package app
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/openhoo/vibecontainer/internal/docker"
+	"github.com/openhoo/vibecontainer/internal/stack"
+	"github.com/spf13/cobra"
+)
+
+func newAttachCmd(runs *stack.RunStore, compose docker.Backend) *cobra.Command {
+	name := ""
+	readOnly := false
+	cmd := &cobra.Command{
+		Use:   "attach --name <stack>",
+		Short: "Attach the terminal to a stack's tmux session",
+		Long:  "Attach the terminal to a stack's tmux session. Detach with the tmux prefix followed by d.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := requireStackName(name); err != nil {
+				return err
+			}
+			if !stdinIsTerminal() {
+				return fmt.Errorf("attach requires an interactive terminal")
+			}
+			ctx := cmd.Context()
+			container, err := compose.ContainerFor(ctx, name, "vibecontainer")
+			if err != nil {
+				if !runs.Exists(name) {
+					return fmt.Errorf("stack %q does not exist", name)
+				}
+				return err
+			}
+			return compose.Attach(ctx, container, docker.AttachOptions{
+				ReadOnly: readOnly,
+				Stdin:    os.Stdin,
+				Stdout:   os.Stdout,
+				Stderr:   os.Stderr,
+			})
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "stack name")
+	cmd.Flags().BoolVar(&readOnly, "read-only", false, "attach without the ability to type into the session")
+	return cmd
+}
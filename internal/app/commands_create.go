@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/openhoo/vibecontainer/internal/config"
@@ -18,19 +20,23 @@ import (
 	"github.com/spf13/cobra"
 )
 
-func newCreateCmd(defaults *config.DefaultsStore, runs *stack.RunStore, compose *docker.Compose) *cobra.Command {
+func newCreateCmd(defaults *config.DefaultsStore, runs *stack.RunStore, compose docker.Backend) *cobra.Command {
 	opts := domain.CreateOptions{}
 	autoYes := false
 	noSaveAuth := false
+	dryRun := false
+	keepOnFailure := false
+	wait := waitOptions{}
 
 	cmd := &cobra.Command{
 		Use:   "create [path]",
 		Short: "Create and start a managed vibecontainer stack",
 		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx, cancel := context.WithTimeout(cmd.Context(), 60*time.Second)
-			defer cancel()
-
+			format := outputOf(cmd)
+			if format.structured() && !autoYes {
+				return fmt.Errorf("--output %s needs --yes; the wizard can't run while printing %s", format, format)
+			}
 			def, err := defaults.Load()
 			if err != nil {
 				return fmt.Errorf("load defaults: %w", err)
@@ -47,14 +53,32 @@ func newCreateCmd(defaults *config.DefaultsStore, runs *stack.RunStore, compose
 				opts.WorkspacePath = workspacePath
 			}
 
+			if !autoYes && !cmd.Flags().Changed("auth-profile") {
+				if opts.AuthProfile, err = pickAuthProfile(); err != nil {
+					return err
+				}
+			}
+			opts.AuthProfile = domain.CredentialProfile(opts.AuthProfile)
+			if err := validate.AuthProfile(opts.AuthProfile); err != nil {
+				return err
+			}
+
 			// Load stored credentials from keychain if not provided via flags
-			kr := keyring.New()
-			storedAuth := kr.LoadAuth()
-			opts.Auth = mergeAuth(cmd, opts.Auth, storedAuth)
+			kr := keyring.NewProfile(opts.AuthProfile)
+			opts.Auth = mergeAuth(cmd, opts.Auth, savedAuth(kr, def))
 
 			if !autoYes {
 				seedWorkspacePath := opts.WorkspacePath
-				result, err := tui.RunCreateWizard(def, opts)
+				suggest, err := portAllocator(runs, def, "", opts.BindAddress)
+				if err != nil {
+					return err
+				}
+				suggestPorts(suggest, &opts, cmd.Flags().Changed)
+				check, err := portAllocator(runs, def, "", opts.BindAddress)
+				if err != nil {
+					return err
+				}
+				result, err := tui.RunCreateWizard(def, opts, check.Check)
 				if err != nil {
 					return err
 				}
@@ -67,43 +91,111 @@ func newCreateCmd(defaults *config.DefaultsStore, runs *stack.RunStore, compose
 				}
 			}
 
-			if err := validate.CreateOptions(opts); err != nil {
+			lock, err := runs.Lock(opts.Name)
+			if err != nil {
 				return err
 			}
+			defer lock.Unlock()
 			if runs.Exists(opts.Name) {
 				return fmt.Errorf("stack %q already exists", opts.Name)
 			}
+			// Hold the ports from picking them until run.json records them.
+			portsLock, err := runs.LockPorts()
+			if err != nil {
+				return err
+			}
+			defer portsLock.Unlock()
+			alloc, err := portAllocator(runs, def, opts.Name, opts.BindAddress)
+			if err != nil {
+				return err
+			}
+			// Ports typed in the wizard are as deliberate as flags.
+			pinned := func(flag string) bool { return !autoYes || cmd.Flags().Changed(flag) }
+			if err := assignPorts(alloc, &opts, domain.StackSpec{}, pinned); err != nil {
+				return err
+			}
+			generatedCredential, err := ensureTTYDCredential(&opts, domain.StackSpec{})
+			if err != nil {
+				return err
+			}
+			if err := validate.CreateOptions(opts); err != nil {
+				return err
+			}
+			if dryRun {
+				return writeComposeRender(os.Stdout, format, opts)
+			}
 
-			meta, err := runs.Save(opts)
+			// From the save on, a failure or Ctrl-C rolls the create back
+			// rather than leaving a half-created stack behind.
+			interrupt, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			fail := func(err error) error {
+				if interrupt.Err() != nil {
+					err = fmt.Errorf("create interrupted: %w", err)
+				}
+				if keepOnFailure {
+					fmt.Fprintf(os.Stderr, "Kept stack %s for debugging; remove it with `vibecontainer remove --name %s`\n", opts.Name, opts.Name)
+					return err
+				}
+				rollbackCreate(compose, runs, opts.Name)
+				return err
+			}
+			meta, err := runs.Save(interrupt, opts)
+			portsLock.Unlock()
 			if err != nil {
-				return fmt.Errorf("save stack config: %w", err)
+				return fail(fmt.Errorf("save stack config: %w", err))
 			}
+			ctx, cancel := context.WithTimeout(interrupt, upTimeout)
+			defer cancel()
+			warnFirewall(ctx, compose, opts)
+			storeTTYDCredential(opts.Name, opts.TTYDCredential)
 
 			if err := compose.Up(ctx, opts.Name); err != nil {
-				return err
+				return fail(err)
 			}
 			if err := runs.Touch(opts.Name); err != nil {
 				fmt.Fprintln(os.Stderr, "Warning: failed to update metadata:", err)
 			}
 
+			if err := wait.wait(interrupt, compose, meta.Name); err != nil {
+				return fail(err)
+			}
+			if err := interrupt.Err(); err != nil {
+				return fail(err)
+			}
+
 			// Save credentials to keychain for next time
 			if !noSaveAuth {
-				if err := kr.SaveAuth(opts.Auth); err != nil {
+				if err := kr.SaveAuth(withoutConfigRefs(opts.Auth, def)); err != nil {
 					fmt.Fprintln(os.Stderr, "Warning: failed to save credentials to keychain:", err)
 				}
 			}
 
-			if err := defaults.Save(domain.Defaults{
-				Provider:        opts.Provider,
-				ReadOnlyPort:    opts.ReadOnlyPort,
-				InteractivePort: opts.InteractivePort,
-				TmuxAccess:      opts.TmuxAccess,
-				FirewallEnable:  opts.FirewallEnable,
-				TunnelEnable:    opts.TunnelEnable,
-			}); err != nil {
+			// Start from the loaded defaults so settings create doesn't
+			// manage, such as the engine, are kept.
+			saved := def
+			saved.Provider = opts.Provider
+			saved.ReadOnlyPort = opts.ReadOnlyPort
+			saved.InteractivePort = opts.InteractivePort
+			saved.TmuxAccess = opts.TmuxAccess
+			saved.FirewallEnable = opts.FirewallEnable
+			saved.TunnelEnable = opts.TunnelEnable
+			saved.BindAddress = opts.BindAddress
+			if err := defaults.Save(saved); err != nil {
 				fmt.Fprintln(os.Stderr, "Warning: failed to save defaults:", err)
 			}
 
+			if format.structured() {
+				// --wait may have outlasted ctx.
+				statusCtx, cancel := context.WithTimeout(cmd.Context(), 30*time.Second)
+				defer cancel()
+				statuses, err := compose.Status(statusCtx, meta.Name)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "Warning: failed to read service status:", err)
+				}
+				return writeOutput(os.Stdout, format, newStackOutput(meta, servicesFromStatus(statuses)))
+			}
+
 			fmt.Printf("Created stack %s (%s)\n", meta.Name, meta.Provider)
 			fmt.Printf("Run dir: %s\n", config.RunDir(meta.Name))
 			if opts.WorkspacePath == "" {
@@ -111,22 +203,26 @@ func newCreateCmd(defaults *config.DefaultsStore, runs *stack.RunStore, compose
 			} else {
 				fmt.Printf("Workspace: %s\n", opts.WorkspacePath)
 			}
-			if opts.TmuxAccess == "read" || opts.TmuxAccess == "write" {
-				fmt.Printf("Read-only URL: http://127.0.0.1:%d\n", opts.ReadOnlyPort)
+			readOnlyURL, interactiveURL := meta.Spec.ReadOnlyURL(), meta.Spec.InteractiveURL()
+			if readOnlyURL != "" {
+				fmt.Printf("Read-only URL: %s\n", readOnlyURL)
 			}
-			if opts.TmuxAccess == "write" {
-				fmt.Printf("Interactive URL: http://127.0.0.1:%d\n", opts.InteractivePort)
+			if interactiveURL != "" {
+				fmt.Printf("Interactive URL: %s\n", interactiveURL)
 			}
 			if opts.TunnelEnable {
 				fmt.Printf("Tunnel: enabled (Cloudflare)\n")
 			} else {
 				fmt.Printf("Tunnel: disabled\n")
 			}
+			if generatedCredential {
+				fmt.Printf("TTYD credential: generated; show it with `vibecontainer credentials show --stack %s`\n", meta.Name)
+			}
 
-			if opts.TmuxAccess == "read" || opts.TmuxAccess == "write" {
-				url := fmt.Sprintf("http://127.0.0.1:%d", opts.ReadOnlyPort)
-				if opts.TmuxAccess == "write" {
-					url = fmt.Sprintf("http://127.0.0.1:%d", opts.InteractivePort)
+			if readOnlyURL != "" {
+				url := readOnlyURL
+				if interactiveURL != "" {
+					url = interactiveURL
 				}
 				open, err := tui.Confirm("Open tmux session in browser?", fmt.Sprintf("URL: %s", url), true)
 				if err == nil && open {
@@ -141,23 +237,114 @@ func newCreateCmd(defaults *config.DefaultsStore, runs *stack.RunStore, compose
 
 	cmd.Flags().BoolVar(&autoYes, "yes", false, "skip the TUI and use flags only")
 	cmd.Flags().BoolVar(&noSaveAuth, "no-save-auth", false, "don't save credentials to keychain")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the compose file and redacted .env instead of creating the stack")
+	cmd.Flags().BoolVar(&keepOnFailure, "keep-on-failure", false, "keep the containers and run dir of a failed create for debugging")
 	cmd.Flags().StringVar(&opts.Name, "name", "", "stack name")
+	wait.bind(cmd)
+	bindStackFlags(cmd, &opts)
+	bindAuthFlags(cmd, &opts.Auth)
+
+	return cmd
+}
+
+// rollbackCreate removes a stack whose create failed. It uses its own
+// context since the create's may have been canceled. No containers can
+// exist before the compose file is written, so Down is skipped then. The
+// run dir is kept if the containers can't be removed, so `remove` can retry.
+func rollbackCreate(compose docker.Backend, runs *stack.RunStore, name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	fmt.Fprintf(os.Stderr, "Rolling back stack %s\n", name)
+	if _, err := os.Stat(config.RunComposePath(name)); err != nil {
+		if err := deleteStack(runs, name); err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: failed to delete run dir:", err)
+		}
+		return
+	}
+	if err := compose.Down(ctx, name); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to remove the containers of %s; clean up with `vibecontainer remove --name %s`: %v\n", name, name, err)
+		return
+	}
+	if err := deleteStack(runs, name); err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: failed to delete run dir:", err)
+	}
+}
+
+// bindStackFlags registers the non-secret stack settings shared by create
+// and update.
+func bindStackFlags(cmd *cobra.Command, opts *domain.CreateOptions) {
 	cmd.Flags().Var((*providerValue)(&opts.Provider), "provider", "provider: base|claude|codex")
 	cmd.Flags().StringVar(&opts.Image, "image", "", "image override")
-	cmd.Flags().IntVar(&opts.ReadOnlyPort, "readonly-port", 0, "read-only port")
+	cmd.Flags().Var((*portValue)(&opts.ReadOnlyPort), "readonly-port", "read-only host port, or auto to pick a free one")
 	cmd.Flags().StringVar(&opts.TmuxAccess, "tmux-access", "", "tmux access level: none|read|write")
-	cmd.Flags().IntVar(&opts.InteractivePort, "interactive-port", 0, "interactive port")
-	cmd.Flags().StringVar(&opts.TTYDCredential, "ttyd-credential", "", "ttyd basic auth credential user:password")
+	cmd.Flags().Var((*portValue)(&opts.InteractivePort), "interactive-port", "interactive host port, or auto to pick a free one")
+	cmd.Flags().StringVar(&opts.TTYDCredential, "ttyd-credential", "", "ttyd basic auth credential user:password, auto to generate one (the default for new stacks), or none for no login on this host only")
 	cmd.Flags().BoolVar(&opts.FirewallEnable, "firewall-enable", false, "enable firewall inside container")
 	cmd.Flags().BoolVar(&opts.TunnelEnable, "tunnel-enable", false, "enable cloudflare tunnel")
-	cmd.Flags().StringVar(&opts.Auth.TunnelToken, "tunnel-token", "", "cloudflare tunnel token (required when tunnel is enabled)")
-	cmd.Flags().StringVar(&opts.Auth.ClaudeOAuthToken, "claude-oauth-token", "", "claude oauth token")
-	cmd.Flags().StringVar(&opts.Auth.AnthropicAPIKey, "anthropic-api-key", "", "anthropic api key")
-	cmd.Flags().StringVar(&opts.Auth.CodexAuthJSON, "codex-auth-json", "", "codex auth json payload")
-	cmd.Flags().StringVar(&opts.Auth.OpenAIAPIKey, "openai-api-key", "", "openai api key")
-	cmd.Flags().StringVar(&opts.Auth.CodexAPIKey, "codex-api-key", "", "codex api key")
+	cmd.Flags().StringVar(&opts.BindAddress, "bind-address", "", "host address to publish ttyd on, e.g. a LAN or Tailscale IP (default 127.0.0.1; others get a generated ttyd credential)")
+	cmd.Flags().BoolVar(&opts.ForcePublicWrite, "force-public-write", false, "allow interactive access on a public bind address")
+	cmd.Flags().StringVar(&opts.AuthProfile, "auth-profile", "", "keychain credential profile to load and save credentials with (default \"default\")")
+}
 
-	return cmd
+// pickAuthProfile asks which credential profile to use when more than the
+// default one has saved credentials.
+func pickAuthProfile() (string, error) {
+	profiles, err := keyring.New().Profiles()
+	if err != nil || len(profiles) < 2 {
+		return keyring.DefaultProfile, nil
+	}
+	profile, err := tui.SelectProfile(profiles, keyring.DefaultProfile)
+	if err != nil {
+		return "", err
+	}
+	if profile == "" {
+		return "", fmt.Errorf("create canceled")
+	}
+	return profile, nil
+}
+
+// bindAuthFlags registers the credential flags read by mergeAuth.
+func bindAuthFlags(cmd *cobra.Command, auth *domain.Auth) {
+	cmd.Flags().StringVar(&auth.TunnelToken, "tunnel-token", "", "cloudflare tunnel token (required when tunnel is enabled)")
+	cmd.Flags().StringVar(&auth.ClaudeOAuthToken, "claude-oauth-token", "", "claude oauth token")
+	cmd.Flags().StringVar(&auth.AnthropicAPIKey, "anthropic-api-key", "", "anthropic api key")
+	cmd.Flags().StringVar(&auth.CodexAuthJSON, "codex-auth-json", "", "codex auth json payload")
+	cmd.Flags().StringVar(&auth.OpenAIAPIKey, "openai-api-key", "", "openai api key")
+	cmd.Flags().StringVar(&auth.CodexAPIKey, "codex-api-key", "", "codex api key")
+}
+
+// fillAuth fills credentials missing from auth with those from fallback.
+func fillAuth(auth, fallback domain.Auth) domain.Auth {
+	fill := func(dst *string, src string) {
+		if *dst == "" {
+			*dst = src
+		}
+	}
+	fill(&auth.ClaudeOAuthToken, fallback.ClaudeOAuthToken)
+	fill(&auth.AnthropicAPIKey, fallback.AnthropicAPIKey)
+	fill(&auth.CodexAuthJSON, fallback.CodexAuthJSON)
+	fill(&auth.OpenAIAPIKey, fallback.OpenAIAPIKey)
+	fill(&auth.CodexAPIKey, fallback.CodexAPIKey)
+	fill(&auth.TunnelToken, fallback.TunnelToken)
+	return auth
+}
+
+// savedAuth returns the credentials of a keychain profile, with the secret
+// references in config.json filling in those the profile lacks.
+func savedAuth(kr *keyring.Store, def domain.Defaults) domain.Auth {
+	return fillAuth(kr.LoadAuth(), domain.Auth{}.WithRefs(def.CredentialRefs))
+}
+
+// withoutConfigRefs clears the credentials in auth that are references taken
+// from config.json, which needn't be copied to the keychain.
+func withoutConfigRefs(auth domain.Auth, def domain.Defaults) domain.Auth {
+	auth, _ = auth.Map(func(env, value string) (string, error) {
+		if def.CredentialRefs[env] == value {
+			return "", nil
+		}
+		return value, nil
+	})
+	return auth
 }
 
 // mergeAuth merges command-line provided auth with stored auth from keychain
@@ -207,6 +394,9 @@ func applyDefaults(cmd *cobra.Command, opts domain.CreateOptions, d domain.Defau
 	if !cmd.Flags().Changed("tunnel-enable") {
 		opts.TunnelEnable = d.TunnelEnable
 	}
+	if !cmd.Flags().Changed("bind-address") {
+		opts.BindAddress = d.BindAddress
+	}
 	return opts
 }
 
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/openhoo/vibecontainer/internal/config"
+	"github.com/openhoo/vibecontainer/internal/domain"
+	"github.com/openhoo/vibecontainer/internal/keyring"
+	"github.com/openhoo/vibecontainer/internal/stack"
+)
+
+func TestRollbackCreate(t *testing.T) {
+	tests := []struct {
+		name    string
+		saved   bool
+		errs    map[string]error
+		calls   []string
+		keepDir bool
+	}{
+		{name: "save failed"},
+		{name: "down succeeds", saved: true, calls: []string{"down demo"}},
+		{name: "down fails", saved: true, errs: map[string]error{"down demo": errors.New("daemon gone")}, calls: []string{"down demo"}, keepDir: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempDataDir(t)
+			// deleteStack also drops the stack's ttyd credential; keep that
+			// away from the system keychain.
+			t.Setenv(keyring.BackendEnv, "file")
+			runs := stack.NewRunStore()
+			if tt.saved {
+				opts := domain.CreateOptions{Name: "demo", Provider: domain.ProviderBase, TmuxAccess: "none"}
+				if _, err := runs.Save(context.Background(), opts); err != nil {
+					t.Fatalf("Save failed: %v", err)
+				}
+			} else if err := os.MkdirAll(config.RunDir("demo"), 0o700); err != nil {
+				t.Fatal(err)
+			}
+			compose := &fakeBackend{errs: tt.errs}
+			rollbackCreate(compose, runs, "demo")
+			if !slices.Equal(compose.calls, tt.calls) {
+				t.Fatalf("calls = %q, want %q", compose.calls, tt.calls)
+			}
+			_, err := os.Stat(config.RunDir("demo"))
+			if kept := err == nil; kept != tt.keepDir {
+				t.Fatalf("run dir kept = %v, want %v", kept, tt.keepDir)
+			}
+		})
+	}
+}
+
+func TestWithoutConfigRefs(t *testing.T) {
+	def := domain.Defaults{CredentialRefs: map[string]string{
+		"ANTHROPIC_API_KEY": "op://dev/anthropic/key",
+		"TUNNEL_TOKEN":      "env:TUNNEL_TOKEN",
+	}}
+	tests := []struct {
+		name string
+		auth domain.Auth
+		want domain.Auth
+	}{
+		{
+			name: "config refs dropped",
+			auth: domain.Auth{AnthropicAPIKey: "op://dev/anthropic/key", TunnelToken: "env:TUNNEL_TOKEN"},
+		},
+		{
+			name: "other values kept",
+			auth: domain.Auth{AnthropicAPIKey: "sk-ant-123", OpenAIAPIKey: "pass:openai"},
+			want: domain.Auth{AnthropicAPIKey: "sk-ant-123", OpenAIAPIKey: "pass:openai"},
+		},
+		{
+			name: "different ref kept",
+			auth: domain.Auth{AnthropicAPIKey: "op://prod/anthropic/key", TunnelToken: "env:TUNNEL_TOKEN"},
+			want: domain.Auth{AnthropicAPIKey: "op://prod/anthropic/key"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withoutConfigRefs(tt.auth, def); got != tt.want {
+				t.Fatalf("withoutConfigRefs = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFillAuth(t *testing.T) {
+	refs := domain.Auth{}.WithRefs(map[string]string{
+		"ANTHROPIC_API_KEY": "op://dev/anthropic/key",
+		"OPENAI_API_KEY":    "not a ref",
+	})
+	tests := []struct {
+		name  string
+		saved domain.Auth
+		want  domain.Auth
+	}{
+		{
+			name:  "saved value wins",
+			saved: domain.Auth{ClaudeOAuthToken: "oauth-token", AnthropicAPIKey: "sk-ant-123"},
+			want:  domain.Auth{ClaudeOAuthToken: "oauth-token", AnthropicAPIKey: "sk-ant-123"},
+		},
+		{
+			name:  "ref fills the gap",
+			saved: domain.Auth{ClaudeOAuthToken: "oauth-token"},
+			want:  domain.Auth{ClaudeOAuthToken: "oauth-token", AnthropicAPIKey: "op://dev/anthropic/key"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fillAuth(tt.saved, refs); got != tt.want {
+				t.Fatalf("fillAuth = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
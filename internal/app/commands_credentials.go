@@ -1,13 +1,24 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/openhoo/vibecontainer/internal/docker"
+	"github.com/openhoo/vibecontainer/internal/domain"
 	"github.com/openhoo/vibecontainer/internal/keyring"
+	"github.com/openhoo/vibecontainer/internal/stack"
+	"github.com/openhoo/vibecontainer/internal/tui"
+	"github.com/openhoo/vibecontainer/internal/validate"
 	"github.com/spf13/cobra"
 )
 
-func newCredentialsCmd() *cobra.Command {
+func newCredentialsCmd(runs *stack.RunStore, compose docker.Backend) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "credentials",
 		Short: "Manage stored credentials",
@@ -16,44 +27,317 @@ func newCredentialsCmd() *cobra.Command {
 
 	cmd.AddCommand(newCredentialsClearCmd())
 	cmd.AddCommand(newCredentialsListCmd())
+	cmd.AddCommand(newCredentialsSetCmd())
+	cmd.AddCommand(newCredentialsGetCmd())
+	cmd.AddCommand(newCredentialsDeleteCmd())
+	cmd.AddCommand(newCredentialsImportCmd())
+	cmd.AddCommand(newCredentialsShowCmd(runs))
+	cmd.AddCommand(newCredentialsRotateCmd(runs, compose))
 
 	return cmd
 }
 
 func newCredentialsClearCmd() *cobra.Command {
-	return &cobra.Command{
+	profile := ""
+	cmd := &cobra.Command{
 		Use:   "clear",
 		Short: "Clear all stored credentials from keychain",
+		Long:  "Clear the stored provider credentials and tunnel token from the keychain, of every profile or only of --profile. The ttyd credentials of stacks are kept until the stack is removed.",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			kr := keyring.New()
-			if err := kr.Clear(); err != nil {
-				return fmt.Errorf("clear credentials: %w", err)
+			profiles, err := credentialProfiles(profile)
+			if err != nil {
+				return err
+			}
+			for _, p := range profiles {
+				if err := keyring.NewProfile(p).Clear(); err != nil {
+					return fmt.Errorf("clear credentials of profile %s: %w", p, err)
+				}
+			}
+			if profile != "" {
+				fmt.Printf("Credentials of profile %s cleared from keychain\n", profile)
+				return nil
 			}
 			fmt.Println("All credentials cleared from keychain")
 			return nil
 		},
 	}
+	cmd.Flags().StringVar(&profile, "profile", "", "only clear this credential profile")
+	return cmd
 }
 
 func newCredentialsListCmd() *cobra.Command {
-	return &cobra.Command{
+	profile := ""
+	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List which credentials are stored (without showing values)",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			kr := keyring.New()
-			auth := kr.LoadAuth()
+			profiles, err := credentialProfiles(profile)
+			if err != nil {
+				return err
+			}
+			for i, p := range profiles {
+				if i > 0 {
+					fmt.Println()
+				}
+				kr := keyring.NewProfile(p)
+				fmt.Printf("Stored credentials (profile %s):\n", p)
+				for _, cred := range keyring.Credentials {
+					value, _ := kr.Get(cred.Key)
+					printCredentialStatus(cred.Title, value)
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&profile, "profile", "", "only list this credential profile")
+	return cmd
+}
 
-			fmt.Println("Stored credentials:")
-			printCredentialStatus("Claude OAuth Token", auth.ClaudeOAuthToken)
-			printCredentialStatus("Anthropic API Key", auth.AnthropicAPIKey)
-			printCredentialStatus("Codex Auth JSON", auth.CodexAuthJSON)
-			printCredentialStatus("OpenAI API Key", auth.OpenAIAPIKey)
-			printCredentialStatus("Codex API Key", auth.CodexAPIKey)
-			printCredentialStatus("Tunnel Token", auth.TunnelToken)
+// credentialProfiles returns the profile named by a --profile flag, or every
+// profile with saved credentials when it is empty.
+func credentialProfiles(profile string) ([]string, error) {
+	if profile == "" {
+		profiles, err := keyring.New().Profiles()
+		if err != nil {
+			// As LoadAuth does, treat an unreadable keychain as empty.
+			return []string{keyring.DefaultProfile}, nil
+		}
+		return profiles, nil
+	}
+	if err := validate.AuthProfile(profile); err != nil {
+		return nil, err
+	}
+	ok, err := keyring.New().HasProfile(profile)
+	if err != nil {
+		return nil, fmt.Errorf("list credential profiles: %w", err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("credential profile %q has no stored credentials", profile)
+	}
+	return []string{profile}, nil
+}
 
+func newCredentialsSetCmd() *cobra.Command {
+	profile := ""
+	cmd := &cobra.Command{
+		Use:   "set <key>",
+		Short: "Store a credential, read from stdin or a prompt",
+		Long: "Store a credential in the keychain. The value is read from stdin when it is\n" +
+			"piped in and asked for otherwise; it is never taken from the command line.\n" +
+			"Keys: " + credentialKeys(),
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cred, kr, err := credentialArgs(args[0], profile)
+			if err != nil {
+				return err
+			}
+			var value string
+			if stdinIsTerminal() {
+				if value, err = tui.PromptSecret(cred.Title); err != nil {
+					return err
+				}
+				if value == "" {
+					return fmt.Errorf("set canceled")
+				}
+			} else {
+				b, err := io.ReadAll(os.Stdin)
+				if err != nil {
+					return fmt.Errorf("read %s from stdin: %w", cred.Key, err)
+				}
+				value = strings.TrimRight(string(b), "\r\n")
+			}
+			if err := validate.Credential(cred.Key, value); err != nil {
+				return err
+			}
+			if err := kr.Set(cred.Key, value); err != nil {
+				return fmt.Errorf("save %s: %w", cred.Key, err)
+			}
+			fmt.Printf("Stored %s in profile %s\n", cred.Title, kr.Profile())
 			return nil
 		},
 	}
+	cmd.Flags().StringVar(&profile, "profile", "", "credential profile (default \"default\")")
+	return cmd
+}
+
+func newCredentialsGetCmd() *cobra.Command {
+	profile := ""
+	reveal := false
+	yes := false
+	cmd := &cobra.Command{
+		Use:   "get <key>",
+		Short: "Check a stored credential, or print it with --reveal",
+		Long:  "Report whether a credential is stored. --reveal prints its value after a confirmation.\nKeys: " + credentialKeys(),
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cred, kr, err := credentialArgs(args[0], profile)
+			if err != nil {
+				return err
+			}
+			value, err := kr.Get(cred.Key)
+			if errors.Is(err, keyring.ErrNotFound) {
+				return fmt.Errorf("%s is not stored in profile %s", cred.Title, kr.Profile())
+			}
+			if err != nil {
+				return fmt.Errorf("read %s: %w", cred.Key, err)
+			}
+			if !reveal {
+				fmt.Printf("%s is stored in profile %s (%d characters); pass --reveal to print it\n", cred.Title, kr.Profile(), len(value))
+				return nil
+			}
+			if !yes {
+				if !stdinIsTerminal() {
+					return fmt.Errorf("--reveal asks for confirmation; pass --yes when not on a terminal")
+				}
+				ok, err := tui.Confirm("Print "+cred.Title+"?", "The value will be shown in your terminal and its scrollback.", false)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return fmt.Errorf("get canceled")
+				}
+			}
+			fmt.Println(value)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&profile, "profile", "", "credential profile (default \"default\")")
+	cmd.Flags().BoolVar(&reveal, "reveal", false, "print the value")
+	cmd.Flags().BoolVar(&yes, "yes", false, "reveal without confirmation")
+	return cmd
+}
+
+func newCredentialsDeleteCmd() *cobra.Command {
+	profile := ""
+	cmd := &cobra.Command{
+		Use:   "delete <key>",
+		Short: "Delete a stored credential",
+		Long:  "Delete a credential from the keychain.\nKeys: " + credentialKeys(),
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cred, kr, err := credentialArgs(args[0], profile)
+			if err != nil {
+				return err
+			}
+			err = kr.Delete(cred.Key)
+			if errors.Is(err, keyring.ErrNotFound) {
+				return fmt.Errorf("%s is not stored in profile %s", cred.Title, kr.Profile())
+			}
+			if err != nil {
+				return fmt.Errorf("delete %s: %w", cred.Key, err)
+			}
+			fmt.Printf("Deleted %s from profile %s\n", cred.Title, kr.Profile())
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&profile, "profile", "", "credential profile (default \"default\")")
+	return cmd
+}
+
+func newCredentialsImportCmd() *cobra.Command {
+	profile := ""
+	envFile := ""
+	fromEnv := false
+	cmd := &cobra.Command{
+		Use:   "import (--env-file <path> | --from-env)",
+		Short: "Store credentials from an env file or environment variables",
+		Long: "Store every credential found in an env file or the environment, by the\n" +
+			"variable names the containers use: " + credentialEnvNames() + ".\n" +
+			"Nothing is stored unless all of them are valid.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if (envFile == "") == !fromEnv {
+				return fmt.Errorf("pass one of --env-file or --from-env")
+			}
+			kr, err := credentialStore(profile)
+			if err != nil {
+				return err
+			}
+			lookup := os.LookupEnv
+			if envFile != "" {
+				b, err := os.ReadFile(envFile)
+				if err != nil {
+					return fmt.Errorf("read env file: %w", err)
+				}
+				env, err := stack.ParseEnvFile(b)
+				if err != nil {
+					return fmt.Errorf("parse %s: %w", envFile, err)
+				}
+				lookup = func(key string) (string, bool) {
+					v, ok := env[key]
+					return v, ok
+				}
+			}
+
+			var found []keyring.Credential
+			values := map[string]string{}
+			for _, cred := range keyring.Credentials {
+				value, ok := lookup(cred.Env)
+				if !ok || value == "" {
+					continue
+				}
+				if err := validate.Credential(cred.Key, value); err != nil {
+					return fmt.Errorf("%s: %w", cred.Env, err)
+				}
+				found = append(found, cred)
+				values[cred.Key] = value
+			}
+			if len(found) == 0 {
+				return fmt.Errorf("no credentials found; looked for %s", credentialEnvNames())
+			}
+			for _, cred := range found {
+				if err := kr.Set(cred.Key, values[cred.Key]); err != nil {
+					return fmt.Errorf("save %s: %w", cred.Key, err)
+				}
+				fmt.Printf("Stored %s in profile %s\n", cred.Title, kr.Profile())
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&profile, "profile", "", "credential profile (default \"default\")")
+	cmd.Flags().StringVar(&envFile, "env-file", "", "env file with KEY=VALUE lines")
+	cmd.Flags().BoolVar(&fromEnv, "from-env", false, "read the current environment")
+	return cmd
+}
+
+// credentialArgs resolves the key and --profile arguments of the
+// single-credential commands.
+func credentialArgs(key, profile string) (keyring.Credential, *keyring.Store, error) {
+	cred, ok := keyring.LookupCredential(key)
+	if !ok {
+		return keyring.Credential{}, nil, fmt.Errorf("unknown credential %q (want %s)", key, credentialKeys())
+	}
+	kr, err := credentialStore(profile)
+	if err != nil {
+		return keyring.Credential{}, nil, err
+	}
+	return cred, kr, nil
+}
+
+// credentialStore opens the profile named by --profile, or the default one.
+func credentialStore(profile string) (*keyring.Store, error) {
+	if profile != "" {
+		if err := validate.AuthProfile(profile); err != nil {
+			return nil, err
+		}
+	}
+	return keyring.NewProfile(profile), nil
+}
+
+func credentialKeys() string {
+	keys := make([]string, 0, len(keyring.Credentials))
+	for _, c := range keyring.Credentials {
+		keys = append(keys, c.Key)
+	}
+	return strings.Join(keys, "|")
+}
+
+func credentialEnvNames() string {
+	names := make([]string, 0, len(keyring.Credentials))
+	for _, c := range keyring.Credentials {
+		names = append(names, c.Env)
+	}
+	return strings.Join(names, ", ")
 }
 
 func printCredentialStatus(name, value string) {
@@ -63,3 +347,169 @@ func printCredentialStatus(name, value string) {
 	}
 	fmt.Printf("  %-24s %s\n", name+":", status)
 }
+
+func newCredentialsShowCmd(runs *stack.RunStore) *cobra.Command {
+	name := ""
+	cmd := &cobra.Command{
+		Use:   "show --stack <name>",
+		Short: "Print the ttyd credential of a stack",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := requireStackName(name); err != nil {
+				return err
+			}
+			if !runs.Exists(name) {
+				return fmt.Errorf("stack %q does not exist", name)
+			}
+			credential, err := keyring.New().LoadTTYDCredential(name)
+			if err != nil {
+				// The keychain may be unavailable or the stack older than
+				// stored credentials; .env holds the value ttyd runs with.
+				opts, loadErr := runs.LoadOptions(name)
+				if loadErr != nil {
+					return fmt.Errorf("load stack config: %w", loadErr)
+				}
+				credential = opts.TTYDCredential
+			}
+			if credential == "" {
+				return fmt.Errorf("stack %q has no ttyd credential", name)
+			}
+			fmt.Println(credential)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&name, "stack", "", "stack name")
+	return cmd
+}
+
+func newCredentialsRotateCmd(runs *stack.RunStore, compose docker.Backend) *cobra.Command {
+	name := ""
+	cmd := &cobra.Command{
+		Use:   "rotate --stack <name>",
+		Short: "Replace the ttyd credential of a stack",
+		Long: "Generate a new ttyd credential for a stack, store it in the keychain and,\n" +
+			"if the stack is running, recreate only its vibecontainer service. The tunnel\n" +
+			"sidecar shares that service's network namespace, so it is recreated too.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := requireStackName(name); err != nil {
+				return err
+			}
+			lock, err := runs.Lock(name)
+			if err != nil {
+				return err
+			}
+			defer lock.Unlock()
+			if !runs.Exists(name) {
+				return fmt.Errorf("stack %q does not exist", name)
+			}
+			current, err := runs.LoadOptions(name)
+			if err != nil {
+				return fmt.Errorf("load stack config: %w", err)
+			}
+			if current.TmuxAccess != "read" && current.TmuxAccess != "write" {
+				return fmt.Errorf("stack %q does not run ttyd (tmux access is %s)", name, current.TmuxAccess)
+			}
+			next := current
+			if next.TTYDCredential, err = keyring.GenerateTTYDCredential(); err != nil {
+				return err
+			}
+			if _, err := runs.Update(cmd.Context(), next); err != nil {
+				return fmt.Errorf("save stack config: %w", err)
+			}
+			storeTTYDCredential(name, next.TTYDCredential)
+
+			ctx, cancel := context.WithTimeout(cmd.Context(), 60*time.Second)
+			defer cancel()
+			statuses, err := compose.Status(ctx, name)
+			if err != nil {
+				return err
+			}
+			running := false
+			for _, s := range statuses {
+				running = running || s.State == "running"
+			}
+			if !running {
+				fmt.Printf("Rotated ttyd credential of stack %s; it takes effect on the next start\n", name)
+				return nil
+			}
+			// ttyd runs in the vibecontainer service; the tunnel sidecar
+			// joins its network namespace and must follow it.
+			services := []string{"vibecontainer"}
+			if current.TunnelEnable {
+				services = append(services, "cloudflared")
+			}
+			if err := compose.Up(ctx, name, services...); err != nil {
+				return err
+			}
+			fmt.Printf("Rotated ttyd credential of stack %s\n", name)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&name, "stack", "", "stack name")
+	return cmd
+}
+
+// ttydPublished reports whether a stack with tmuxAccess runs ttyd.
+func ttydPublished(tmuxAccess string) bool {
+	return tmuxAccess == "read" || tmuxAccess == "write"
+}
+
+// ttydExposed reports whether ttyd can be reached from beyond this host,
+// through the tunnel or a non-loopback bind address.
+func ttydExposed(opts domain.CreateOptions) bool {
+	if !ttydPublished(opts.TmuxAccess) {
+		return false
+	}
+	return opts.TunnelEnable || !validate.IsLoopback(domain.PublishAddress(opts.BindAddress))
+}
+
+// ensureTTYDCredential settles the ttyd credential of opts, a stack that
+// had the settings in prev. "none" opts out of a credential, which ttyd
+// reachable beyond this host doesn't allow. Otherwise one is generated when
+// the credential is "auto", or when ttyd is published without one, unless
+// the stack already published it without one on this host only. It
+// reports whether a credential was generated.
+func ensureTTYDCredential(opts *domain.CreateOptions, prev domain.StackSpec) (bool, error) {
+	switch {
+	case opts.TTYDCredential == "none":
+		if ttydExposed(*opts) {
+			return false, errors.New("--ttyd-credential none is only allowed while ttyd is reachable from this host alone; drop the tunnel and bind address, or keep a credential")
+		}
+		opts.TTYDCredential = ""
+		return false, nil
+	case opts.TTYDCredential == "auto":
+	case opts.TTYDCredential != "" || !ttydPublished(opts.TmuxAccess):
+		return false, nil
+	case ttydPublished(prev.TmuxAccess) && !ttydExposed(*opts):
+		return false, nil
+	}
+	credential, err := keyring.GenerateTTYDCredential()
+	if err != nil {
+		return false, err
+	}
+	opts.TTYDCredential = credential
+	return true, nil
+}
+
+// storeTTYDCredential keeps the keychain entry of a stack in step with its
+// ttyd credential. .env stays the source ttyd reads, so failures only warn.
+func storeTTYDCredential(name, credential string) {
+	kr := keyring.New()
+	var err error
+	if credential == "" {
+		err = kr.DeleteTTYDCredential(name)
+	} else {
+		err = kr.SaveTTYDCredential(name, credential)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: failed to update ttyd credential in keychain:", err)
+	}
+}
+
+// deleteStack removes the run dir of a stack and its keychain entries.
+func deleteStack(runs *stack.RunStore, name string) error {
+	if err := runs.Delete(name); err != nil {
+		return err
+	}
+	_ = keyring.New().DeleteTTYDCredential(name)
+	return nil
+}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/openhoo/vibecontainer/internal/domain"
+)
+
+func TestEnsureTTYDCredential(t *testing.T) {
+	published := domain.StackSpec{TmuxAccess: "read"}
+	tests := []struct {
+		name       string
+		opts       domain.CreateOptions
+		prev       domain.StackSpec
+		generated  bool
+		credential string
+		err        bool
+	}{
+		{name: "new stack", opts: domain.CreateOptions{TmuxAccess: "read"}, generated: true},
+		{name: "no ttyd", opts: domain.CreateOptions{TmuxAccess: "none"}},
+		{name: "given", opts: domain.CreateOptions{TmuxAccess: "write", TTYDCredential: "dev:pw"}, credential: "dev:pw"},
+		{name: "auto", opts: domain.CreateOptions{TmuxAccess: "read", TTYDCredential: "auto"}, prev: published, generated: true},
+		{name: "opted out", opts: domain.CreateOptions{TmuxAccess: "read", TTYDCredential: "none"}},
+		{name: "opted out with tunnel", opts: domain.CreateOptions{TmuxAccess: "read", TTYDCredential: "none", TunnelEnable: true}, err: true},
+		{name: "existing stack without one", opts: domain.CreateOptions{TmuxAccess: "write"}, prev: published},
+		{name: "existing stack moved off loopback", opts: domain.CreateOptions{TmuxAccess: "read", BindAddress: "100.101.102.103"}, prev: published, generated: true},
+		{name: "existing stack starts publishing", opts: domain.CreateOptions{TmuxAccess: "read"}, prev: domain.StackSpec{TmuxAccess: "none"}, generated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			generated, err := ensureTTYDCredential(&opts, tt.prev)
+			if (err != nil) != tt.err {
+				t.Fatalf("ensureTTYDCredential returned %v", err)
+			}
+			if generated != tt.generated {
+				t.Fatalf("generated = %v, want %v", generated, tt.generated)
+			}
+			if tt.generated {
+				if opts.TTYDCredential == "" || opts.TTYDCredential == "auto" {
+					t.Fatalf("expected a generated credential, got %q", opts.TTYDCredential)
+				}
+			} else if !tt.err && opts.TTYDCredential != tt.credential {
+				t.Fatalf("credential = %q, want %q", opts.TTYDCredential, tt.credential)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/openhoo/vibecontainer/internal/config"
+	"github.com/openhoo/vibecontainer/internal/docker"
+	"github.com/openhoo/vibecontainer/internal/doctor"
+	"github.com/openhoo/vibecontainer/internal/keyring"
+	"github.com/openhoo/vibecontainer/internal/stack"
+	"github.com/spf13/cobra"
+)
+
+func newDoctorCmd(defaults *config.DefaultsStore, runs *stack.RunStore, runner docker.Runner, engine *engineBackend) *cobra.Command {
+	asJSON := false
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check that the environment can run stacks",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			def, err := defaults.Load()
+			if err != nil {
+				return fmt.Errorf("load defaults: %w", err)
+			}
+			// Ports of managed stacks are meant to be in use.
+			stackPorts := map[int]string{}
+			metas, _, err := runs.List()
+			if err != nil {
+				return fmt.Errorf("list stacks: %w", err)
+			}
+			for _, m := range metas {
+				for _, p := range stack.PublishedPorts(m.Spec) {
+					stackPorts[p] = m.Name
+				}
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), 30*time.Second)
+			defer cancel()
+
+			// The Keychain check reports a fallback; don't print it twice.
+			keyring.Notices = io.Discard
+			secrets := keyring.DefaultBackend()
+			d := &doctor.Doctor{
+				Runner:          runner,
+				Dialect:         engine.dialect,
+				Defaults:        def,
+				Keyring:         keyring.New().Probe,
+				KeyringBackend:  secrets.Backend.Name(),
+				KeyringFallback: secrets.Fallback,
+				PortFree:        doctor.PortFree,
+				StackPorts:      stackPorts,
+				Dirs: []doctor.Dir{
+					{Name: "Config dir", Path: config.ConfigDir()},
+					{Name: "Data dir", Path: config.DataDir()},
+				},
+			}
+			results := d.Run(ctx)
+			failed := doctor.Failed(results)
+
+			format := outputOf(cmd)
+			if asJSON {
+				format = outputJSON
+			}
+			if format.structured() {
+				if err := writeOutput(os.Stdout, format, struct {
+					OK     bool            `json:"ok" yaml:"ok"`
+					Checks []doctor.Result `json:"checks" yaml:"checks"`
+				}{OK: failed == 0, Checks: results}); err != nil {
+					return err
+				}
+			} else {
+				printDoctorResults(results)
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d check(s) failed", failed)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print results as JSON (same as --output json)")
+	return cmd
+}
+
+func printDoctorResults(results []doctor.Result) {
+	for _, r := range results {
+		mark := "✓"
+		switch r.Status {
+		case doctor.StatusWarn:
+			mark = "!"
+		case doctor.StatusFail:
+			mark = "✗"
+		}
+		fmt.Printf("%s %-24s %s\n", mark, r.Name, r.Detail)
+		if r.Fix != "" {
+			fmt.Printf("  %-24s %s\n", "", r.Fix)
+		}
+	}
+}
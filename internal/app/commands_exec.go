@@ -0,0 +1,61 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/openhoo/vibecontainer/internal/docker"
+	"github.com/openhoo/vibecontainer/internal/stack"
+	"github.com/spf13/cobra"
+)
+
+func newExecCmd(runs *stack.RunStore, compose docker.Backend) *cobra.Command {
+	env := []string{}
+	workdir := ""
+	cmd := &cobra.Command{
+		Use:   "exec <stack> -- <command> [args...]",
+		Short: "Run a command inside a stack's vibecontainer service",
+		Long: "Run a command inside a stack's vibecontainer service as the dev user.\n" +
+			"The command's exit status becomes the exit status of vibecontainer.",
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if dash := cmd.ArgsLenAtDash(); dash > 1 {
+				return fmt.Errorf("expected a single stack name before --, got %v", args[:dash])
+			}
+			name := args[0]
+			for _, e := range env {
+				if !strings.Contains(e, "=") {
+					return fmt.Errorf("--env must be in KEY=VALUE format, got %q", e)
+				}
+			}
+			ctx := cmd.Context()
+			container, err := compose.ContainerFor(ctx, name, "vibecontainer")
+			if err != nil {
+				if !runs.Exists(name) {
+					return fmt.Errorf("stack %q does not exist", name)
+				}
+				return err
+			}
+			dir := workdir
+			if dir == "" {
+				if meta, err := runs.Load(name); err == nil && meta.Workspace != "" {
+					dir = "/workspace"
+				}
+			}
+			return compose.Exec(ctx, container, docker.ExecOptions{
+				Command: args[1:],
+				User:    "dev",
+				Workdir: dir,
+				Env:     env,
+				TTY:     stdinIsTerminal(),
+				Stdin:   os.Stdin,
+				Stdout:  os.Stdout,
+				Stderr:  os.Stderr,
+			})
+		},
+	}
+	cmd.Flags().StringArrayVarP(&env, "env", "e", nil, "set an environment variable (KEY=VALUE), repeatable")
+	cmd.Flags().StringVarP(&workdir, "workdir", "w", "", "working directory inside the container")
+	return cmd
+}
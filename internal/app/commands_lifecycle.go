@@ -3,6 +3,8 @@ package app
 import (
 	"context"
 	"fmt"
+	"os"
+	"os/signal"
 	"time"
 
 	"github.com/openhoo/vibecontainer/internal/docker"
@@ -11,8 +13,9 @@ import (
 	"github.com/spf13/cobra"
 )
 
-func newStartCmd(runs *stack.RunStore, compose *docker.Compose) *cobra.Command {
+func newStartCmd(runs *stack.RunStore, compose docker.Backend) *cobra.Command {
 	name := ""
+	wait := waitOptions{}
 	cmd := &cobra.Command{
 		Use:   "start --name <stack>",
 		Short: "Start a stack",
@@ -20,24 +23,31 @@ func newStartCmd(runs *stack.RunStore, compose *docker.Compose) *cobra.Command {
 			if err := requireStackName(name); err != nil {
 				return err
 			}
+			lock, err := runs.Lock(name)
+			if err != nil {
+				return err
+			}
+			defer lock.Unlock()
 			if !runs.Exists(name) {
 				return fmt.Errorf("stack %q does not exist", name)
 			}
-			ctx, cancel := context.WithTimeout(cmd.Context(), 60*time.Second)
-			defer cancel()
-			if err := compose.Up(ctx, name); err != nil {
+			if err := upStack(cmd.Context(), runs, compose, name); err != nil {
 				return err
 			}
 			_ = runs.Touch(name)
+			if err := wait.wait(cmd.Context(), compose, name); err != nil {
+				return err
+			}
 			fmt.Printf("Started stack %s\n", name)
 			return nil
 		},
 	}
 	cmd.Flags().StringVar(&name, "name", "", "stack name")
+	wait.bind(cmd)
 	return cmd
 }
 
-func newStopCmd(runs *stack.RunStore, compose *docker.Compose) *cobra.Command {
+func newStopCmd(runs *stack.RunStore, compose docker.Backend) *cobra.Command {
 	name := ""
 	cmd := &cobra.Command{
 		Use:   "stop --name <stack>",
@@ -46,6 +56,11 @@ func newStopCmd(runs *stack.RunStore, compose *docker.Compose) *cobra.Command {
 			if err := requireStackName(name); err != nil {
 				return err
 			}
+			lock, err := runs.Lock(name)
+			if err != nil {
+				return err
+			}
+			defer lock.Unlock()
 			if !runs.Exists(name) {
 				return fmt.Errorf("stack %q does not exist", name)
 			}
@@ -63,8 +78,9 @@ func newStopCmd(runs *stack.RunStore, compose *docker.Compose) *cobra.Command {
 	return cmd
 }
 
-func newRestartCmd(runs *stack.RunStore, compose *docker.Compose) *cobra.Command {
+func newRestartCmd(runs *stack.RunStore, compose docker.Backend) *cobra.Command {
 	name := ""
+	wait := waitOptions{}
 	cmd := &cobra.Command{
 		Use:   "restart --name <stack>",
 		Short: "Restart a stack",
@@ -72,6 +88,11 @@ func newRestartCmd(runs *stack.RunStore, compose *docker.Compose) *cobra.Command
 			if err := requireStackName(name); err != nil {
 				return err
 			}
+			lock, err := runs.Lock(name)
+			if err != nil {
+				return err
+			}
+			defer lock.Unlock()
 			if !runs.Exists(name) {
 				return fmt.Errorf("stack %q does not exist", name)
 			}
@@ -81,18 +102,23 @@ func newRestartCmd(runs *stack.RunStore, compose *docker.Compose) *cobra.Command
 				return err
 			}
 			_ = runs.Touch(name)
+			if err := wait.wait(cmd.Context(), compose, name); err != nil {
+				return err
+			}
 			fmt.Printf("Restarted stack %s\n", name)
 			return nil
 		},
 	}
 	cmd.Flags().StringVar(&name, "name", "", "stack name")
+	wait.bind(cmd)
 	return cmd
 }
 
-func newLogsCmd(runs *stack.RunStore, compose *docker.Compose) *cobra.Command {
+func newLogsCmd(runs *stack.RunStore, compose docker.Backend) *cobra.Command {
 	name := ""
 	service := ""
-	follow := false
+	noPrefix := false
+	opts := docker.LogsOptions{}
 	cmd := &cobra.Command{
 		Use:   "logs --name <stack>",
 		Short: "Show stack logs",
@@ -103,21 +129,32 @@ func newLogsCmd(runs *stack.RunStore, compose *docker.Compose) *cobra.Command {
 			if !runs.Exists(name) {
 				return fmt.Errorf("stack %q does not exist", name)
 			}
-			out, err := compose.Logs(cmd.Context(), name, service, follow)
-			if err != nil {
-				return err
+			if service != "" {
+				opts.Services = []string{service}
 			}
-			fmt.Print(out)
-			return nil
+			opts.Prefix = !noPrefix
+
+			// Ctrl-C is the normal way to leave --follow, so treat it as success.
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+			err := compose.Logs(ctx, name, opts, os.Stdout, os.Stderr)
+			if err != nil && ctx.Err() != nil {
+				return nil
+			}
+			return err
 		},
 	}
 	cmd.Flags().StringVar(&name, "name", "", "stack name")
 	cmd.Flags().StringVar(&service, "service", "", "service name filter")
-	cmd.Flags().BoolVar(&follow, "follow", false, "follow logs")
+	cmd.Flags().BoolVar(&opts.Follow, "follow", false, "follow logs")
+	cmd.Flags().StringVar(&opts.Tail, "tail", "", "number of lines to show from the end of the logs (or \"all\")")
+	cmd.Flags().StringVar(&opts.Since, "since", "", "show logs since timestamp (e.g. 2026-01-02T13:23:37Z) or relative (e.g. 42m)")
+	cmd.Flags().BoolVar(&opts.Timestamps, "timestamps", false, "show timestamps")
+	cmd.Flags().BoolVar(&noPrefix, "no-prefix", false, "don't prefix lines with the service name")
 	return cmd
 }
 
-func newRemoveCmd(runs *stack.RunStore, compose *docker.Compose) *cobra.Command {
+func newRemoveCmd(runs *stack.RunStore, compose docker.Backend) *cobra.Command {
 	name := ""
 	yes := false
 	all := false
@@ -131,6 +168,11 @@ func newRemoveCmd(runs *stack.RunStore, compose *docker.Compose) *cobra.Command
 			if err := requireStackName(name); err != nil {
 				return err
 			}
+			lock, err := runs.Lock(name)
+			if err != nil {
+				return err
+			}
+			defer lock.Unlock()
 			if !runs.Exists(name) {
 				return fmt.Errorf("stack %q does not exist", name)
 			}
@@ -148,7 +190,7 @@ func newRemoveCmd(runs *stack.RunStore, compose *docker.Compose) *cobra.Command
 			if err := compose.Down(ctx, name); err != nil {
 				return err
 			}
-			if err := runs.Delete(name); err != nil {
+			if err := deleteStack(runs, name); err != nil {
 				return err
 			}
 			fmt.Printf("Removed stack %s\n", name)
@@ -161,11 +203,19 @@ func newRemoveCmd(runs *stack.RunStore, compose *docker.Compose) *cobra.Command
 	return cmd
 }
 
-func removeAll(ctx context.Context, runs *stack.RunStore, compose *docker.Compose, yes bool) error {
-	metas, err := runs.List()
+func removeAll(ctx context.Context, runs *stack.RunStore, compose docker.Backend, yes bool) error {
+	lock, err := runs.LockAll()
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	metas, warnings, err := runs.List()
 	if err != nil {
 		return fmt.Errorf("list stacks: %w", err)
 	}
+	for _, w := range warnings {
+		fmt.Fprintln(os.Stderr, "Warning:", w)
+	}
 	if len(metas) == 0 {
 		fmt.Println("No stacks to remove")
 		return nil
@@ -185,7 +235,7 @@ func removeAll(ctx context.Context, runs *stack.RunStore, compose *docker.Compos
 		if err := compose.Down(ctx, m.Name); err != nil {
 			fmt.Printf("Warning: failed to stop stack %s: %v\n", m.Name, err)
 		}
-		if err := runs.Delete(m.Name); err != nil {
+		if err := deleteStack(runs, m.Name); err != nil {
 			fmt.Printf("Warning: failed to delete stack %s: %v\n", m.Name, err)
 			continue
 		}
@@ -2,6 +2,7 @@ package app
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/openhoo/vibecontainer/internal/docker"
 	"github.com/openhoo/vibecontainer/internal/stack"
@@ -11,33 +12,46 @@ import (
 
 const labelStack = "com.openhoo.vibecontainer.stack"
 
-func newListCmd(runs *stack.RunStore, compose *docker.Compose) *cobra.Command {
+func newListCmd(runs *stack.RunStore, compose docker.Backend) *cobra.Command {
 	return &cobra.Command{
 		Use:   "list",
 		Short: "List managed stacks",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
-			metas, err := runs.List()
+			metas, warnings, err := runs.List()
 			if err != nil {
 				return err
 			}
+			for _, w := range warnings {
+				fmt.Fprintln(os.Stderr, "Warning:", w)
+			}
 			managed, err := compose.ListManagedContainers(ctx)
 			if err != nil {
 				return err
 			}
 			stateByStack := map[string][]string{}
+			containersByStack := map[string][]docker.ManagedContainer{}
 			for _, c := range managed {
 				stackName := c.Labels[labelStack]
 				if stackName == "" {
 					continue
 				}
 				stateByStack[stackName] = append(stateByStack[stackName], c.State)
+				containersByStack[stackName] = append(containersByStack[stackName], c)
+			}
+			format := outputOf(cmd)
+			if format.structured() {
+				out := make([]stackOutput, 0, len(metas))
+				for _, m := range metas {
+					out = append(out, newStackOutput(m, servicesFromContainers(containersByStack[m.Name])))
+				}
+				return writeOutput(os.Stdout, format, out)
 			}
 			if len(metas) == 0 {
 				fmt.Println("No managed stacks found")
 				return nil
 			}
-			tui.RenderList(metas, stateByStack)
+			tui.RenderList(metas, stateByStack, format == outputWide)
 			return nil
 		},
 	}
@@ -0,0 +1,213 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/openhoo/vibecontainer/internal/config"
+	"github.com/openhoo/vibecontainer/internal/docker"
+	"github.com/openhoo/vibecontainer/internal/domain"
+	"github.com/openhoo/vibecontainer/internal/keyring"
+	"github.com/openhoo/vibecontainer/internal/project"
+	"github.com/openhoo/vibecontainer/internal/stack"
+	"github.com/openhoo/vibecontainer/internal/validate"
+	"github.com/spf13/cobra"
+)
+
+func newUpCmd(defaults *config.DefaultsStore, runs *stack.RunStore, compose docker.Backend) *cobra.Command {
+	file := ""
+	cmd := &cobra.Command{
+		Use:   "up",
+		Short: "Create or reconcile the stack described by " + project.FileName,
+		Long: "Create the stack described by " + project.FileName + " in the current directory,\n" +
+			"or bring an existing stack in line with it. Credentials come from the keychain.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := loadProjectFile(file)
+			if err != nil {
+				return err
+			}
+			def, err := defaults.Load()
+			if err != nil {
+				return fmt.Errorf("load defaults: %w", err)
+			}
+			opts, err := f.Options(def)
+			if err != nil {
+				return err
+			}
+			lock, err := runs.Lock(opts.Name)
+			if err != nil {
+				return err
+			}
+			defer lock.Unlock()
+
+			// Ports the file doesn't set stay where they are on an existing
+			// stack and move to a free port on a new one when taken.
+			var current domain.CreateOptions
+			exists := runs.Exists(opts.Name)
+			if exists {
+				if current, err = runs.LoadOptions(opts.Name); err != nil {
+					return fmt.Errorf("load stack config: %w", err)
+				}
+				if f.Tmux.ReadOnlyPort == 0 && current.ReadOnlyPort != 0 {
+					opts.ReadOnlyPort = current.ReadOnlyPort
+				}
+				if f.Tmux.InteractivePort == 0 && current.InteractivePort != 0 {
+					opts.InteractivePort = current.InteractivePort
+				}
+				// Project files never hold credentials; keep the stack's
+				// ttyd login.
+				opts.TTYDCredential = current.TTYDCredential
+				if f.AuthProfile == "" {
+					opts.AuthProfile = current.AuthProfile
+				}
+			}
+			opts.AuthProfile = domain.CredentialProfile(opts.AuthProfile)
+			if err := validate.AuthProfile(opts.AuthProfile); err != nil {
+				return err
+			}
+			profile := keyring.NewProfile(opts.AuthProfile)
+			if exists && opts.AuthProfile == domain.CredentialProfile(current.AuthProfile) {
+				// An existing stack keeps its credentials, which may have
+				// been passed by flag, and takes only missing ones from the
+				// keychain.
+				opts.Auth = fillAuth(current.Auth, savedAuth(profile, def))
+			} else {
+				opts.Auth = savedAuth(profile, def)
+			}
+			portsLock, err := runs.LockPorts()
+			if err != nil {
+				return err
+			}
+			defer portsLock.Unlock()
+			alloc, err := portAllocator(runs, def, opts.Name, opts.BindAddress)
+			if err != nil {
+				return err
+			}
+			pinned := map[string]bool{"readonly-port": f.Tmux.ReadOnlyPort != 0, "interactive-port": f.Tmux.InteractivePort != 0}
+			if err := assignPorts(alloc, &opts, domain.SpecFromOptions(current), func(flag string) bool { return pinned[flag] }); err != nil {
+				return err
+			}
+			if _, err := ensureTTYDCredential(&opts, domain.SpecFromOptions(current)); err != nil {
+				return err
+			}
+			if err := validate.CreateOptions(opts); err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithTimeout(cmd.Context(), 60*time.Second)
+			defer cancel()
+			warnFirewall(ctx, compose, opts)
+
+			if !exists {
+				meta, err := runs.Save(cmd.Context(), opts)
+				portsLock.Unlock()
+				if err != nil {
+					return fmt.Errorf("save stack config: %w", err)
+				}
+				storeTTYDCredential(opts.Name, opts.TTYDCredential)
+				upCtx, cancelUp := context.WithTimeout(cmd.Context(), upTimeout)
+				defer cancelUp()
+				if err := compose.Up(upCtx, opts.Name); err != nil {
+					return err
+				}
+				fmt.Printf("Created stack %s (%s)\n", meta.Name, meta.Provider)
+				printStackURLs(meta.Spec)
+				return nil
+			}
+
+			changes := stack.DiffOptions(current, opts)
+			meta, err := runs.Load(opts.Name)
+			if err != nil {
+				return fmt.Errorf("load stack config: %w", err)
+			}
+			if len(changes) > 0 {
+				fmt.Printf("Reconciling stack %s:\n", opts.Name)
+				printChanges(changes)
+				if meta, err = runs.Update(cmd.Context(), opts); err != nil {
+					return fmt.Errorf("save stack config: %w", err)
+				}
+				storeTTYDCredential(opts.Name, opts.TTYDCredential)
+			}
+			portsLock.Unlock()
+			if err := upStack(cmd.Context(), runs, compose, opts.Name); err != nil {
+				return err
+			}
+			if len(changes) == 0 {
+				fmt.Printf("Stack %s is up to date\n", opts.Name)
+			} else {
+				fmt.Printf("Updated stack %s\n", opts.Name)
+			}
+			printStackURLs(meta.Spec)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&file, "file", "f", "", "path to the project file (default ./"+project.FileName+")")
+	return cmd
+}
+
+func newDownCmd(runs *stack.RunStore, compose docker.Backend) *cobra.Command {
+	file := ""
+	cmd := &cobra.Command{
+		Use:   "down",
+		Short: "Remove the stack described by " + project.FileName,
+		Long: "Stop and remove the stack described by " + project.FileName + " and delete its\n" +
+			"run directory. Run `vibecontainer up` to recreate it from the file.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := loadProjectFile(file)
+			if err != nil {
+				return err
+			}
+			name, err := f.StackName()
+			if err != nil {
+				return err
+			}
+			lock, err := runs.Lock(name)
+			if err != nil {
+				return err
+			}
+			defer lock.Unlock()
+			if !runs.Exists(name) {
+				fmt.Printf("Stack %s does not exist\n", name)
+				return nil
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), 60*time.Second)
+			defer cancel()
+			if err := compose.Down(ctx, name); err != nil {
+				return err
+			}
+			if err := deleteStack(runs, name); err != nil {
+				return err
+			}
+			fmt.Printf("Removed stack %s\n", name)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&file, "file", "f", "", "path to the project file (default ./"+project.FileName+")")
+	return cmd
+}
+
+func loadProjectFile(path string) (*project.File, error) {
+	if path == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		if path, err = project.Find(wd); err != nil {
+			return nil, err
+		}
+	}
+	return project.Load(path)
+}
+
+func printStackURLs(spec domain.StackSpec) {
+	if url := spec.ReadOnlyURL(); url != "" {
+		fmt.Printf("Read-only URL: %s\n", url)
+	}
+	if url := spec.InteractiveURL(); url != "" {
+		fmt.Printf("Interactive URL: %s\n", url)
+	}
+}
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/openhoo/vibecontainer/internal/docker"
+	"github.com/openhoo/vibecontainer/internal/stack"
+	"github.com/openhoo/vibecontainer/internal/tui"
+	"github.com/spf13/cobra"
+)
+
+func newPruneCmd(runs *stack.RunStore, compose docker.Backend) *cobra.Command {
+	dryRun := false
+	yes := false
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove orphaned containers and stale run directories",
+		Long: "Find managed containers whose run directory is gone and run directories\n" +
+			"whose containers were removed outside vibecontainer, and clean them up.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			lock, err := runs.LockAll()
+			if err != nil {
+				return err
+			}
+			defer lock.Unlock()
+			ctx, cancel := context.WithTimeout(cmd.Context(), 120*time.Second)
+			defer cancel()
+
+			names, err := runs.Names()
+			if err != nil {
+				return fmt.Errorf("list stacks: %w", err)
+			}
+			managed, err := compose.ListManagedContainers(ctx)
+			if err != nil {
+				return err
+			}
+			containersByStack := map[string][]string{}
+			for _, c := range managed {
+				if stackName := c.Labels[labelStack]; stackName != "" {
+					containersByStack[stackName] = append(containersByStack[stackName], c.Name)
+				}
+			}
+			// Until their next start, adopted stacks run on the original
+			// container, which may not be labelled for them.
+			adopted := map[string]bool{}
+			for _, name := range names {
+				if meta, err := runs.Load(name); err == nil && meta.AdoptedFrom != "" {
+					adopted[name] = true
+				}
+			}
+			drift := stack.FindDrift(names, adopted, containersByStack)
+			if drift.Empty() {
+				fmt.Println("Nothing to prune")
+				return nil
+			}
+
+			if len(drift.OrphanContainers) > 0 {
+				fmt.Println("Orphaned containers (no run directory):")
+				for _, name := range sortedKeys(drift.OrphanContainers) {
+					fmt.Printf("  %s: %s\n", name, strings.Join(drift.OrphanContainers[name], ", "))
+				}
+			}
+			if len(drift.DeadRunDirs) > 0 {
+				fmt.Println("Stale run directories (no containers):")
+				for _, name := range drift.DeadRunDirs {
+					fmt.Printf("  %s\n", name)
+				}
+			}
+			if dryRun {
+				return nil
+			}
+			if !yes {
+				ok, err := tui.Confirm("Prune these?", "Orphaned containers are force-removed and stale run directories deleted.", false)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return fmt.Errorf("prune canceled")
+				}
+			}
+
+			for _, name := range sortedKeys(drift.OrphanContainers) {
+				if err := compose.RemoveStackContainers(ctx, name, drift.OrphanContainers[name]); err != nil {
+					fmt.Printf("Warning: failed to remove containers of %s: %v\n", name, err)
+					continue
+				}
+				fmt.Printf("Removed orphaned containers of %s\n", name)
+			}
+			for _, name := range drift.DeadRunDirs {
+				if err := deleteStack(runs, name); err != nil {
+					fmt.Printf("Warning: failed to delete run directory of %s: %v\n", name, err)
+					continue
+				}
+				fmt.Printf("Deleted run directory of %s\n", name)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "only list what would be removed")
+	cmd.Flags().BoolVar(&yes, "yes", false, "remove without confirmation")
+	return cmd
+}
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/openhoo/vibecontainer/internal/domain"
+	"github.com/openhoo/vibecontainer/internal/secretref"
+	"github.com/openhoo/vibecontainer/internal/stack"
+	"github.com/openhoo/vibecontainer/internal/validate"
+	"github.com/spf13/cobra"
+)
+
+func newRenderCmd(runs *stack.RunStore) *cobra.Command {
+	target := "compose"
+	cmd := &cobra.Command{
+		Use:   "render <stack>",
+		Short: "Print the configuration of an existing stack",
+		Long: "Print the configuration of an existing stack. The compose target prints the\n" +
+			"compose file and a redacted .env as vibecontainer would write them now. The\n" +
+			"k8s target writes a Secret holding the stack's credentials; treat the output\n" +
+			"accordingly.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if !runs.Exists(name) {
+				return fmt.Errorf("stack %q does not exist", name)
+			}
+			opts, err := runs.LoadOptions(name)
+			if err != nil {
+				return fmt.Errorf("load stack config: %w", err)
+			}
+			switch target {
+			case "compose":
+				return writeComposeRender(os.Stdout, outputOf(cmd), opts)
+			case "k8s":
+				refs := opts.Auth.Refs()
+				opts.Auth, err = secretref.ResolveAuth(cmd.Context(), opts.Auth)
+				if err != nil {
+					return err
+				}
+				if err := validate.ResolvedAuth(opts.Auth, refs); err != nil {
+					return err
+				}
+				b, warnings, err := stack.KubernetesYAML(opts)
+				if err != nil {
+					return fmt.Errorf("render kubernetes manifests: %w", err)
+				}
+				for _, w := range warnings {
+					fmt.Fprintln(os.Stderr, "Warning:", w)
+				}
+				_, err = os.Stdout.Write(b)
+				return err
+			default:
+				return fmt.Errorf("unknown target %q (want compose or k8s)", target)
+			}
+		},
+	}
+	cmd.Flags().StringVar(&target, "target", target, "what to render: compose|k8s")
+	return cmd
+}
+
+// writeComposeRender prints the compose file and .env RunStore.Save writes
+// for opts, with the .env values redacted.
+func writeComposeRender(w io.Writer, format outputFormat, opts domain.CreateOptions) error {
+	compose, _, err := stack.ComposeYAML(opts)
+	if err != nil {
+		return fmt.Errorf("render compose file: %w", err)
+	}
+	if format.structured() {
+		return writeOutput(w, format, struct {
+			Compose string `json:"compose" yaml:"compose"`
+			Env     string `json:"env" yaml:"env"`
+		}{Compose: string(compose), Env: string(stack.RedactedEnvFile(opts))})
+	}
+	fmt.Fprintln(w, "# compose.yaml")
+	if _, err := w.Write(compose); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "\n# .env")
+	_, err = w.Write(stack.RedactedEnvFile(opts))
+	return err
+}
@@ -2,6 +2,7 @@ package app
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/openhoo/vibecontainer/internal/docker"
 	"github.com/openhoo/vibecontainer/internal/stack"
@@ -9,7 +10,7 @@ import (
 	"github.com/spf13/cobra"
 )
 
-func newStatusCmd(runs *stack.RunStore, compose *docker.Compose) *cobra.Command {
+func newStatusCmd(runs *stack.RunStore, compose docker.Backend) *cobra.Command {
 	name := ""
 	cmd := &cobra.Command{
 		Use:   "status --name <stack>",
@@ -21,15 +22,24 @@ func newStatusCmd(runs *stack.RunStore, compose *docker.Compose) *cobra.Command
 			if !runs.Exists(name) {
 				return fmt.Errorf("stack %q does not exist", name)
 			}
+			meta, err := runs.Load(name)
+			if err != nil {
+				return fmt.Errorf("load stack config: %w", err)
+			}
 			statuses, err := compose.Status(cmd.Context(), name)
 			if err != nil {
 				return err
 			}
+			format := outputOf(cmd)
+			if format.structured() {
+				return writeOutput(os.Stdout, format, newStackOutput(meta, servicesFromStatus(statuses)))
+			}
+			tui.RenderStackInfo(meta)
 			if len(statuses) == 0 {
 				fmt.Println("No services found")
 				return nil
 			}
-			tui.RenderStatus(statuses)
+			tui.RenderStatus(statuses, format == outputWide)
 			return nil
 		},
 	}
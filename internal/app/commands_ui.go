@@ -0,0 +1,137 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/openhoo/vibecontainer/internal/docker"
+	"github.com/openhoo/vibecontainer/internal/stack"
+	"github.com/openhoo/vibecontainer/internal/tui"
+	"github.com/spf13/cobra"
+)
+
+func newUICmd(runs *stack.RunStore, compose docker.Backend) *cobra.Command {
+	return &cobra.Command{
+		Use:   "ui",
+		Short: "Open a live dashboard of all stacks",
+		Long: "Open a full-screen dashboard that lists every stack with its container state,\n" +
+			"refreshed on container events (or every few seconds where the engine has no\n" +
+			"events), and starts, stops, restarts, removes, tails logs, opens the browser or\n" +
+			"attaches to the selected stack.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !stdinIsTerminal() {
+				return fmt.Errorf("ui requires an interactive terminal")
+			}
+			return tui.RunDashboard(dashboardActions(runs, compose))
+		},
+	}
+}
+
+func dashboardActions(runs *stack.RunStore, compose docker.Backend) tui.DashboardActions {
+	return tui.DashboardActions{
+		Load: func(ctx context.Context) ([]tui.DashboardStack, error) {
+			// Printing would garble the dashboard; broken stacks just
+			// don't show up, and list names them.
+			metas, _, err := runs.List()
+			if err != nil {
+				return nil, err
+			}
+			managed, err := compose.ListManagedContainers(ctx)
+			if err != nil {
+				return nil, err
+			}
+			byStack := map[string][]docker.ManagedContainer{}
+			for _, c := range managed {
+				byStack[c.Labels[labelStack]] = append(byStack[c.Labels[labelStack]], c)
+			}
+			out := make([]tui.DashboardStack, 0, len(metas))
+			for _, m := range metas {
+				row := tui.DashboardStack{Meta: m}
+				for _, c := range byStack[m.Name] {
+					row.States = append(row.States, c.State)
+					row.Health = append(row.Health, c.Health)
+				}
+				out = append(out, row)
+			}
+			return out, nil
+		},
+		Start: func(ctx context.Context, name string) error {
+			lock, err := runs.Lock(name)
+			if err != nil {
+				return err
+			}
+			defer lock.Unlock()
+			if err := upStack(ctx, runs, compose, name); err != nil {
+				return err
+			}
+			_ = runs.Touch(name)
+			return nil
+		},
+		Stop: func(ctx context.Context, name string) error {
+			lock, err := runs.Lock(name)
+			if err != nil {
+				return err
+			}
+			defer lock.Unlock()
+			if err := compose.Stop(ctx, name); err != nil {
+				return err
+			}
+			_ = runs.Touch(name)
+			return nil
+		},
+		Restart: func(ctx context.Context, name string) error {
+			lock, err := runs.Lock(name)
+			if err != nil {
+				return err
+			}
+			defer lock.Unlock()
+			if err := compose.Restart(ctx, name); err != nil {
+				return err
+			}
+			_ = runs.Touch(name)
+			return nil
+		},
+		Remove: func(ctx context.Context, name string) error {
+			lock, err := runs.Lock(name)
+			if err != nil {
+				return err
+			}
+			defer lock.Unlock()
+			if err := compose.Down(ctx, name); err != nil {
+				return err
+			}
+			return deleteStack(runs, name)
+		},
+		Logs: func(name string) tui.TerminalFunc {
+			return func(stdin io.Reader, stdout, stderr io.Writer) error {
+				fmt.Fprintf(stdout, "Following logs of %s; press Ctrl-C to return.\n", name)
+				// Ctrl-C is the only way back to the dashboard, so it isn't an error.
+				ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+				defer stop()
+				err := compose.Logs(ctx, name, docker.LogsOptions{Follow: true, Tail: "100", Prefix: true}, stdout, stderr)
+				if err != nil && ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+		},
+		Attach: func(name string) tui.TerminalFunc {
+			return func(stdin io.Reader, stdout, stderr io.Writer) error {
+				ctx := context.Background()
+				container, err := compose.ContainerFor(ctx, name, "vibecontainer")
+				if err != nil {
+					return err
+				}
+				return compose.Attach(ctx, container, docker.AttachOptions{Stdin: stdin, Stdout: stdout, Stderr: stderr})
+			}
+		},
+		Open: openBrowser,
+		Watch: func(ctx context.Context, changed func()) error {
+			return compose.Events(ctx, func(docker.Event) { changed() })
+		},
+	}
+}
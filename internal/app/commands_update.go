@@ -0,0 +1,214 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/openhoo/vibecontainer/internal/config"
+	"github.com/openhoo/vibecontainer/internal/docker"
+	"github.com/openhoo/vibecontainer/internal/domain"
+	"github.com/openhoo/vibecontainer/internal/keyring"
+	"github.com/openhoo/vibecontainer/internal/stack"
+	"github.com/openhoo/vibecontainer/internal/tui"
+	"github.com/openhoo/vibecontainer/internal/validate"
+	"github.com/spf13/cobra"
+)
+
+func newUpdateCmd(defaults *config.DefaultsStore, runs *stack.RunStore, compose docker.Backend) *cobra.Command {
+	name := ""
+	flagOpts := domain.CreateOptions{}
+	workspace := ""
+	wizard := false
+	yes := false
+
+	cmd := &cobra.Command{
+		Use:   "update --name <stack>",
+		Short: "Change the settings of an existing stack",
+		Long: "Change the settings of an existing stack. Only settings passed as flags\n" +
+			"(or edited in the wizard) change; compose recreates only the affected services.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := requireStackName(name); err != nil {
+				return err
+			}
+			lock, err := runs.Lock(name)
+			if err != nil {
+				return err
+			}
+			defer lock.Unlock()
+			if !runs.Exists(name) {
+				return fmt.Errorf("stack %q does not exist", name)
+			}
+			def, err := defaults.Load()
+			if err != nil {
+				return fmt.Errorf("load defaults: %w", err)
+			}
+			current, err := runs.LoadOptions(name)
+			if err != nil {
+				return fmt.Errorf("load stack config: %w", err)
+			}
+			if current.ReadOnlyPort == 0 {
+				current.ReadOnlyPort = def.ReadOnlyPort
+			}
+			if current.InteractivePort == 0 {
+				current.InteractivePort = def.InteractivePort
+			}
+
+			next := applyChangedFlags(cmd, current, flagOpts)
+			if cmd.Flags().Changed("workspace") {
+				next.WorkspacePath = workspace
+				if workspace != "" {
+					if next.WorkspacePath, err = filepath.Abs(workspace); err != nil {
+						return fmt.Errorf("resolve workspace path: %w", err)
+					}
+				}
+			}
+			if err := validate.AuthProfile(domain.CredentialProfile(next.AuthProfile)); err != nil {
+				return err
+			}
+			profile := keyring.NewProfile(next.AuthProfile)
+			if domain.CredentialProfile(next.AuthProfile) != domain.CredentialProfile(current.AuthProfile) {
+				// Another profile brings its own credentials.
+				next.Auth = mergeAuth(cmd, flagOpts.Auth, savedAuth(profile, def))
+			} else {
+				next.Auth = mergeAuth(cmd, flagOpts.Auth, current.Auth)
+				// A new provider or a newly enabled tunnel needs credentials
+				// the stack never had; take them from the keychain.
+				if next.Provider != current.Provider || next.TunnelEnable && !current.TunnelEnable {
+					next.Auth = fillAuth(next.Auth, savedAuth(profile, def))
+				}
+			}
+
+			if wizard {
+				check, err := portAllocator(runs, def, name, next.BindAddress)
+				if err != nil {
+					return err
+				}
+				result, err := tui.RunUpdateWizard(def, next, checkPortKeeping(check, domain.SpecFromOptions(current)))
+				if err != nil {
+					return err
+				}
+				if !result.OK {
+					return fmt.Errorf("update canceled")
+				}
+				next = result.Options
+			}
+
+			alloc, err := portAllocator(runs, def, name, next.BindAddress)
+			if err != nil {
+				return err
+			}
+			pinned := func(flag string) bool { return wizard || cmd.Flags().Changed(flag) }
+			if err := assignPorts(alloc, &next, domain.SpecFromOptions(current), pinned); err != nil {
+				return err
+			}
+			if _, err := ensureTTYDCredential(&next, domain.SpecFromOptions(current)); err != nil {
+				return err
+			}
+			if err := validate.CreateOptions(next); err != nil {
+				return err
+			}
+			changes := stack.DiffOptions(current, next)
+			if len(changes) == 0 {
+				fmt.Printf("Stack %s is already up to date\n", name)
+				return nil
+			}
+			fmt.Printf("Changes to stack %s:\n", name)
+			printChanges(changes)
+			if !yes {
+				ok, err := tui.Confirm("Apply these changes?", "Services whose configuration changed will be recreated.", true)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return fmt.Errorf("update canceled")
+				}
+			}
+
+			// Ports are held only once the changes are confirmed, so the
+			// prompt doesn't hold up other stacks; the ones picked above
+			// must still be free.
+			portsLock, err := runs.LockPorts()
+			if err != nil {
+				return err
+			}
+			defer portsLock.Unlock()
+			if alloc, err = portAllocator(runs, def, name, next.BindAddress); err != nil {
+				return err
+			}
+			if err := assignPorts(alloc, &next, domain.SpecFromOptions(current), func(string) bool { return true }); err != nil {
+				return err
+			}
+			_, err = runs.Update(cmd.Context(), next)
+			portsLock.Unlock()
+			if err != nil {
+				return fmt.Errorf("save stack config: %w", err)
+			}
+			storeTTYDCredential(name, next.TTYDCredential)
+			ctx, cancel := context.WithTimeout(cmd.Context(), 60*time.Second)
+			defer cancel()
+			warnFirewall(ctx, compose, next)
+			if err := upStack(cmd.Context(), runs, compose, name); err != nil {
+				return err
+			}
+			fmt.Printf("Updated stack %s\n", name)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "", "stack name")
+	cmd.Flags().BoolVar(&wizard, "wizard", false, "edit settings in the TUI, prefilled from the stack")
+	cmd.Flags().BoolVar(&yes, "yes", false, "apply changes without confirmation")
+	cmd.Flags().StringVar(&workspace, "workspace", "", "workspace path to mount (empty to unmap)")
+	bindStackFlags(cmd, &flagOpts)
+	bindAuthFlags(cmd, &flagOpts.Auth)
+
+	return cmd
+}
+
+// applyChangedFlags overrides settings in opts with the stack flags that
+// were set explicitly on the command line.
+func applyChangedFlags(cmd *cobra.Command, opts, flags domain.CreateOptions) domain.CreateOptions {
+	changed := cmd.Flags().Changed
+	if changed("provider") {
+		opts.Provider = flags.Provider
+	}
+	if changed("image") {
+		opts.Image = flags.Image
+	}
+	if changed("readonly-port") {
+		opts.ReadOnlyPort = flags.ReadOnlyPort
+	}
+	if changed("interactive-port") {
+		opts.InteractivePort = flags.InteractivePort
+	}
+	if changed("tmux-access") {
+		opts.TmuxAccess = flags.TmuxAccess
+	}
+	if changed("ttyd-credential") {
+		opts.TTYDCredential = flags.TTYDCredential
+	}
+	if changed("firewall-enable") {
+		opts.FirewallEnable = flags.FirewallEnable
+	}
+	if changed("tunnel-enable") {
+		opts.TunnelEnable = flags.TunnelEnable
+	}
+	if changed("bind-address") {
+		opts.BindAddress = flags.BindAddress
+	}
+	if changed("force-public-write") {
+		opts.ForcePublicWrite = flags.ForcePublicWrite
+	}
+	if changed("auth-profile") {
+		opts.AuthProfile = domain.CredentialProfile(flags.AuthProfile)
+	}
+	return opts
+}
+
+func printChanges(changes []stack.Change) {
+	for _, c := range changes {
+		fmt.Printf("  %-18s %s -> %s\n", c.Field+":", c.Old, c.New)
+	}
+}
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/openhoo/vibecontainer/internal/domain"
+	"github.com/spf13/cobra"
+)
+
+func TestApplyChangedFlags(t *testing.T) {
+	current := domain.CreateOptions{
+		Name:            "demo",
+		Provider:        domain.ProviderClaude,
+		ReadOnlyPort:    7681,
+		InteractivePort: 7682,
+		TmuxAccess:      "write",
+		TTYDCredential:  "dev:pw",
+		FirewallEnable:  true,
+		AuthProfile:     "default",
+	}
+	tests := []struct {
+		name string
+		args []string
+		want func(*domain.CreateOptions)
+	}{
+		{name: "no flags", want: func(*domain.CreateOptions) {}},
+		{
+			name: "changed flags applied",
+			args: []string{"--provider", "codex", "--readonly-port", "auto", "--tmux-access", "read", "--auth-profile", "Work"},
+			want: func(o *domain.CreateOptions) {
+				o.Provider = domain.ProviderCodex
+				o.ReadOnlyPort = domain.AutoPort
+				o.TmuxAccess = "read"
+				o.AuthProfile = domain.CredentialProfile("Work")
+			},
+		},
+		{
+			name: "flags set to their zero value",
+			args: []string{"--firewall-enable=false", "--ttyd-credential", ""},
+			want: func(o *domain.CreateOptions) {
+				o.FirewallEnable = false
+				o.TTYDCredential = ""
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var flags domain.CreateOptions
+			cmd := &cobra.Command{}
+			bindStackFlags(cmd, &flags)
+			if err := cmd.ParseFlags(tt.args); err != nil {
+				t.Fatalf("ParseFlags failed: %v", err)
+			}
+			want := current
+			tt.want(&want)
+			if got := applyChangedFlags(cmd, current, flags); !reflect.DeepEqual(got, want) {
+				t.Fatalf("applyChangedFlags = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/openhoo/vibecontainer/internal/docker"
+	"github.com/openhoo/vibecontainer/internal/domain"
+)
+
+// engineBackend is the Backend selected by --engine. Commands are built
+// before flags are parsed, so the backend is filled in by resolve from the
+// root command's PersistentPreRunE.
+type engineBackend struct {
+	docker.Backend
+	dialect docker.Dialect
+}
+
+func (b *engineBackend) resolve(engine string, runner docker.Runner) error {
+	dialect, err := docker.DialectFor(engine)
+	if err != nil {
+		return err
+	}
+	backend, err := docker.NewBackend(engine, runner)
+	if err != nil {
+		return err
+	}
+	b.Backend, b.dialect = backend, dialect
+	return nil
+}
+
+// warnFirewall warns when opts enable the firewall but the engine is
+// rootless, where NET_ADMIN may not be enough for ufw to apply its rules.
+// Engines that can't be asked are given the benefit of the doubt.
+func warnFirewall(ctx context.Context, compose docker.Backend, opts domain.CreateOptions) {
+	if !opts.FirewallEnable {
+		return
+	}
+	caps, err := compose.Capabilities(ctx)
+	if err != nil || !caps.Rootless {
+		return
+	}
+	fmt.Fprintln(os.Stderr, "Warning: the container engine is rootless, so NET_ADMIN may be unavailable and the firewall may fail to apply; if it does, disable the firewall for this stack")
+}
@@ -1,10 +1,17 @@
 package app
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"os/exec"
 	"runtime"
+	"sort"
 	"strings"
+	"time"
+
+	"github.com/openhoo/vibecontainer/internal/docker"
+	"github.com/spf13/cobra"
 )
 
 func requireStackName(name string) error {
@@ -14,6 +21,28 @@ func requireStackName(name string) error {
 	return nil
 }
 
+// waitOptions are the --wait flags of commands that start services.
+type waitOptions struct {
+	enabled bool
+	timeout time.Duration
+}
+
+func (w *waitOptions) bind(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&w.enabled, "wait", false, "wait until the services are healthy; fail if they don't become so")
+	cmd.Flags().DurationVar(&w.timeout, "wait-timeout", 2*time.Minute, "how long --wait waits")
+}
+
+// wait blocks until the services of stack are healthy when --wait is set.
+func (w waitOptions) wait(ctx context.Context, compose docker.Backend, stack string) error {
+	if !w.enabled {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+	fmt.Fprintf(os.Stderr, "Waiting for stack %s to become healthy...\n", stack)
+	return docker.WaitHealthy(ctx, compose, stack, time.Second)
+}
+
 func openBrowser(url string) error {
 	switch runtime.GOOS {
 	case "darwin":
@@ -26,3 +55,20 @@ func openBrowser(url string) error {
 		return fmt.Errorf("unsupported platform")
 	}
 }
+
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,192 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/openhoo/vibecontainer/internal/config"
+	"github.com/openhoo/vibecontainer/internal/docker"
+	"github.com/openhoo/vibecontainer/internal/domain"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// outputFormat is the value of the global --output flag.
+type outputFormat string
+
+const (
+	outputTable outputFormat = "table"
+	outputWide  outputFormat = "wide"
+	outputJSON  outputFormat = "json"
+	outputYAML  outputFormat = "yaml"
+)
+
+func (f *outputFormat) String() string {
+	if f == nil || *f == "" {
+		return string(outputTable)
+	}
+	return string(*f)
+}
+
+func (f *outputFormat) Set(v string) error {
+	switch format := outputFormat(strings.ToLower(strings.TrimSpace(v))); format {
+	case outputTable, outputWide, outputJSON, outputYAML:
+		*f = format
+		return nil
+	}
+	return fmt.Errorf("invalid output format %q (want table, wide, json or yaml)", v)
+}
+
+func (f *outputFormat) Type() string { return "format" }
+
+// structured reports whether results are encoded for scripts rather than
+// printed for people.
+func (f outputFormat) structured() bool {
+	return f == outputJSON || f == outputYAML
+}
+
+// outputOf returns the --output format in effect for cmd.
+func outputOf(cmd *cobra.Command) outputFormat {
+	if fl := cmd.Flags().Lookup("output"); fl != nil {
+		return outputFormat(fl.Value.String())
+	}
+	return outputTable
+}
+
+// writeOutput encodes v as JSON or YAML.
+func writeOutput(w io.Writer, format outputFormat, v any) error {
+	if format == outputYAML {
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// stackOutput is the machine-readable description of a stack printed by
+// list, status and create.
+type stackOutput struct {
+	Name       string          `json:"name" yaml:"name"`
+	Provider   domain.Provider `json:"provider" yaml:"provider"`
+	Image      string          `json:"image" yaml:"image"`
+	Workspace  string          `json:"workspace" yaml:"workspace"`
+	RunDir     string          `json:"run_dir" yaml:"run_dir"`
+	State      string          `json:"state" yaml:"state"`
+	TmuxAccess string          `json:"tmux_access" yaml:"tmux_access"`
+	Ports      portsOutput     `json:"ports" yaml:"ports"`
+	URLs       urlsOutput      `json:"urls" yaml:"urls"`
+	Firewall   bool            `json:"firewall" yaml:"firewall"`
+	Tunnel     bool            `json:"tunnel" yaml:"tunnel"`
+	// AuthProfile is the credential profile the stack was created with.
+	AuthProfile string          `json:"auth_profile" yaml:"auth_profile"`
+	Mounts      []string        `json:"mounts,omitempty" yaml:"mounts,omitempty"`
+	CreatedAt   time.Time       `json:"created_at" yaml:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at" yaml:"updated_at"`
+	Services    []serviceOutput `json:"services" yaml:"services"`
+}
+
+type portsOutput struct {
+	ReadOnly    int `json:"readonly,omitempty" yaml:"readonly,omitempty"`
+	Interactive int `json:"interactive,omitempty" yaml:"interactive,omitempty"`
+}
+
+type urlsOutput struct {
+	ReadOnly    string `json:"readonly,omitempty" yaml:"readonly,omitempty"`
+	Interactive string `json:"interactive,omitempty" yaml:"interactive,omitempty"`
+}
+
+type serviceOutput struct {
+	Name      string       `json:"name" yaml:"name"`
+	Container string       `json:"container" yaml:"container"`
+	Image     string       `json:"image,omitempty" yaml:"image,omitempty"`
+	State     string       `json:"state" yaml:"state"`
+	Health    string       `json:"health,omitempty" yaml:"health,omitempty"`
+	Ports     []portOutput `json:"ports,omitempty" yaml:"ports,omitempty"`
+}
+
+type portOutput struct {
+	HostIP        string `json:"host_ip" yaml:"host_ip"`
+	HostPort      int    `json:"host_port" yaml:"host_port"`
+	ContainerPort int    `json:"container_port" yaml:"container_port"`
+	Protocol      string `json:"protocol" yaml:"protocol"`
+}
+
+func newStackOutput(meta domain.RunMetadata, services []serviceOutput) stackOutput {
+	out := stackOutput{
+		Name:       meta.Name,
+		Provider:   meta.Provider,
+		Image:      meta.Image,
+		Workspace:  meta.Workspace,
+		RunDir:     config.RunDir(meta.Name),
+		State:      stackState(services),
+		TmuxAccess: meta.Spec.TmuxAccess,
+		URLs: urlsOutput{
+			ReadOnly:    meta.Spec.ReadOnlyURL(),
+			Interactive: meta.Spec.InteractiveURL(),
+		},
+		Firewall:    meta.Spec.FirewallEnable,
+		Tunnel:      meta.Spec.TunnelEnable,
+		AuthProfile: domain.CredentialProfile(meta.Spec.AuthProfile),
+		Mounts:      meta.Spec.Mounts,
+		CreatedAt:   meta.CreatedAt,
+		UpdatedAt:   meta.UpdatedAt,
+		Services:    services,
+	}
+	if out.URLs.ReadOnly != "" {
+		out.Ports.ReadOnly = meta.Spec.ReadOnlyPort
+	}
+	if out.URLs.Interactive != "" {
+		out.Ports.Interactive = meta.Spec.InteractivePort
+	}
+	if out.Services == nil {
+		out.Services = []serviceOutput{}
+	}
+	return out
+}
+
+// stackState summarizes service states the way the list table does.
+func stackState(services []serviceOutput) string {
+	if len(services) == 0 {
+		return "not-created"
+	}
+	states := make([]string, 0, len(services))
+	for _, s := range services {
+		states = append(states, s.State)
+	}
+	sort.Strings(states)
+	return strings.Join(states, ",")
+}
+
+func servicesFromContainers(containers []docker.ManagedContainer) []serviceOutput {
+	out := make([]serviceOutput, 0, len(containers))
+	for _, c := range containers {
+		out = append(out, serviceOutput{Name: c.Service, Container: c.Name, State: c.State, Health: c.Health})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func servicesFromStatus(statuses []domain.ServiceStatus) []serviceOutput {
+	out := make([]serviceOutput, 0, len(statuses))
+	for _, s := range statuses {
+		svc := serviceOutput{Name: s.Service, Container: s.Name, Image: s.Image, State: s.State, Health: s.Health}
+		for _, p := range s.Publishers {
+			if p.PublishedPort == 0 {
+				continue
+			}
+			svc.Ports = append(svc.Ports, portOutput{HostIP: p.URL, HostPort: p.PublishedPort, ContainerPort: p.TargetPort, Protocol: p.Protocol})
+		}
+		out = append(out, svc)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
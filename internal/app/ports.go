@@ -0,0 +1,122 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/openhoo/vibecontainer/internal/domain"
+	"github.com/openhoo/vibecontainer/internal/stack"
+)
+
+// portValue is a port flag that also accepts "auto".
+type portValue int
+
+func (p *portValue) String() string {
+	if p == nil {
+		return "0"
+	}
+	if int(*p) == domain.AutoPort {
+		return "auto"
+	}
+	return strconv.Itoa(int(*p))
+}
+
+func (p *portValue) Set(v string) error {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if v == "auto" {
+		*p = portValue(domain.AutoPort)
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("must be a port between 1 and 65535 or auto")
+	}
+	*p = portValue(n)
+	return nil
+}
+
+func (p *portValue) Type() string { return "port" }
+
+// portAllocator returns an allocator over the ports on bind of every stack
+// but self, picking from the range in def.
+func portAllocator(runs *stack.RunStore, def domain.Defaults, self, bind string) (*stack.PortAllocator, error) {
+	rng, err := stack.ParsePortRange(def.PortRange)
+	if err != nil {
+		return nil, fmt.Errorf("config.json: %w", err)
+	}
+	// Stacks whose run.json can't be read reserve no ports; list names them.
+	metas, _, err := runs.List()
+	if err != nil {
+		return nil, fmt.Errorf("list stacks: %w", err)
+	}
+	return stack.NewPortAllocator(metas, self, bind, rng), nil
+}
+
+// portField is a port setting of CreateOptions and the flag that sets it.
+type portField struct {
+	port      *int
+	flag      string
+	title     string
+	published bool
+}
+
+func portFields(opts *domain.CreateOptions) []portField {
+	return []portField{
+		{&opts.ReadOnlyPort, "readonly-port", "read-only port", opts.TmuxAccess == "read" || opts.TmuxAccess == "write"},
+		{&opts.InteractivePort, "interactive-port", "interactive port", opts.TmuxAccess == "write"},
+	}
+}
+
+// assignPorts resolves the host ports opts publishes. Ports prev already
+// published are kept as they are. Pinned ports must be free; others move to
+// a free port when taken. Auto ports that aren't published are cleared.
+func assignPorts(alloc *stack.PortAllocator, opts *domain.CreateOptions, prev domain.StackSpec, pinned func(flag string) bool) error {
+	kept := stack.PublishedPorts(prev)
+	for _, f := range portFields(opts) {
+		if !f.published {
+			if *f.port == domain.AutoPort {
+				*f.port = 0
+			}
+			continue
+		}
+		if slices.Contains(kept, *f.port) {
+			continue
+		}
+		want := *f.port
+		got, err := alloc.Resolve(want, pinned(f.flag))
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.title, err)
+		}
+		if want != domain.AutoPort && got != want {
+			fmt.Fprintf(os.Stderr, "Port %d is taken; using %d as the %s\n", want, got, f.title)
+		}
+		*f.port = got
+	}
+	return nil
+}
+
+// suggestPorts replaces auto ports, and default ports that are taken, with
+// free ones for the wizard to offer. Both ports are filled since the wizard
+// may turn on the one the stack doesn't publish yet.
+func suggestPorts(alloc *stack.PortAllocator, opts *domain.CreateOptions, pinned func(flag string) bool) {
+	for _, f := range portFields(opts) {
+		if got, err := alloc.Resolve(*f.port, pinned(f.flag)); err == nil {
+			*f.port = got
+		}
+	}
+}
+
+// checkPortKeeping checks ports typed in the wizard, allowing the ports the
+// stack already publishes.
+func checkPortKeeping(alloc *stack.PortAllocator, prev domain.StackSpec) func(int) error {
+	kept := stack.PublishedPorts(prev)
+	return func(port int) error {
+		if slices.Contains(kept, port) {
+			return nil
+		}
+		return alloc.Check(port)
+	}
+}
@@ -0,0 +1,150 @@
+package app
+
+import (
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/openhoo/vibecontainer/internal/domain"
+	"github.com/openhoo/vibecontainer/internal/stack"
+)
+
+// freePorts returns n consecutive loopback ports that were free a moment
+// ago, so the allocator's check of the host passes for them.
+func freePorts(t *testing.T, n int) []int {
+	t.Helper()
+	for tries := 0; tries < 20; tries++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		first := l.Addr().(*net.TCPAddr).Port
+		l.Close()
+		var ports []int
+		for p := first; p < first+n && p <= 65535; p++ {
+			l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(p)))
+			if err != nil {
+				break
+			}
+			l.Close()
+			ports = append(ports, p)
+		}
+		if len(ports) == n {
+			return ports
+		}
+	}
+	t.Skip("no run of free loopback ports")
+	return nil
+}
+
+// testAllocator returns an allocator over ports where another stack
+// publishes ports[0].
+func testAllocator(ports []int) *stack.PortAllocator {
+	others := []domain.RunMetadata{{Name: "one", Spec: domain.StackSpec{TmuxAccess: "read", ReadOnlyPort: ports[0]}}}
+	return stack.NewPortAllocator(others, "demo", "", stack.PortRange{First: ports[0], Last: ports[len(ports)-1]})
+}
+
+func TestAssignPorts(t *testing.T) {
+	p := freePorts(t, 4)
+	all := func(string) bool { return true }
+	none := func(string) bool { return false }
+	tests := []struct {
+		name        string
+		opts        domain.CreateOptions
+		prev        domain.StackSpec
+		pinned      func(string) bool
+		readOnly    int
+		interactive int
+		err         bool
+	}{
+		{
+			name:        "free ports kept",
+			opts:        domain.CreateOptions{TmuxAccess: "write", ReadOnlyPort: p[1], InteractivePort: p[2]},
+			pinned:      all,
+			readOnly:    p[1],
+			interactive: p[2],
+		},
+		{
+			name:     "taken port moves",
+			opts:     domain.CreateOptions{TmuxAccess: "read", ReadOnlyPort: p[0]},
+			pinned:   none,
+			readOnly: p[1],
+		},
+		{
+			name:   "taken pinned port fails",
+			opts:   domain.CreateOptions{TmuxAccess: "read", ReadOnlyPort: p[0]},
+			pinned: all,
+			err:    true,
+		},
+		{
+			name:        "auto ports picked",
+			opts:        domain.CreateOptions{TmuxAccess: "write", ReadOnlyPort: domain.AutoPort, InteractivePort: domain.AutoPort},
+			pinned:      none,
+			readOnly:    p[1],
+			interactive: p[2],
+		},
+		{
+			name:     "unpublished auto port cleared",
+			opts:     domain.CreateOptions{TmuxAccess: "read", ReadOnlyPort: p[3], InteractivePort: domain.AutoPort},
+			pinned:   all,
+			readOnly: p[3],
+		},
+		{
+			name:     "published port kept on update",
+			opts:     domain.CreateOptions{TmuxAccess: "read", ReadOnlyPort: p[0]},
+			prev:     domain.StackSpec{TmuxAccess: "read", ReadOnlyPort: p[0]},
+			pinned:   all,
+			readOnly: p[0],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			err := assignPorts(testAllocator(p), &opts, tt.prev, tt.pinned)
+			if (err != nil) != tt.err {
+				t.Fatalf("assignPorts returned %v", err)
+			}
+			if tt.err {
+				return
+			}
+			if opts.ReadOnlyPort != tt.readOnly || opts.InteractivePort != tt.interactive {
+				t.Fatalf("ports = %d, %d; want %d, %d", opts.ReadOnlyPort, opts.InteractivePort, tt.readOnly, tt.interactive)
+			}
+		})
+	}
+}
+
+func TestSuggestPorts(t *testing.T) {
+	p := freePorts(t, 4)
+	tests := []struct {
+		name        string
+		opts        domain.CreateOptions
+		pinned      func(string) bool
+		readOnly    int
+		interactive int
+	}{
+		{
+			name:        "both ports filled",
+			opts:        domain.CreateOptions{TmuxAccess: "none", ReadOnlyPort: p[0], InteractivePort: domain.AutoPort},
+			pinned:      func(string) bool { return false },
+			readOnly:    p[1],
+			interactive: p[2],
+		},
+		{
+			name:        "taken pinned port left for the wizard to flag",
+			opts:        domain.CreateOptions{TmuxAccess: "read", ReadOnlyPort: p[0], InteractivePort: p[3]},
+			pinned:      func(flag string) bool { return flag == "readonly-port" },
+			readOnly:    p[0],
+			interactive: p[3],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			suggestPorts(testAllocator(p), &opts, tt.pinned)
+			if opts.ReadOnlyPort != tt.readOnly || opts.InteractivePort != tt.interactive {
+				t.Fatalf("ports = %d, %d; want %d, %d", opts.ReadOnlyPort, opts.InteractivePort, tt.readOnly, tt.interactive)
+			}
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package app
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/openhoo/vibecontainer/internal/config"
 	"github.com/openhoo/vibecontainer/internal/docker"
@@ -23,31 +25,69 @@ func New(version, commit, date string) *App {
 func (a *App) Execute() error {
 	store := config.NewDefaultsStore()
 	runs := stack.NewRunStore()
-	compose := docker.NewCompose(docker.NewExecRunner())
+	runner := docker.NewExecRunner()
+	compose := &engineBackend{}
+	engine := ""
 
 	root := &cobra.Command{
 		Use:           "vibecontainer",
 		Short:         "Manage vibecontainer stacks",
 		SilenceUsage:  true,
 		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			def, err := store.Load()
+			if err != nil {
+				return fmt.Errorf("load defaults: %w", err)
+			}
+			return compose.resolve(docker.SelectEngine(engine, def.Engine), runner)
+		},
 	}
 
 	root.Version = fmt.Sprintf("%s (commit=%s date=%s)", a.version, a.commit, a.date)
 	root.SetVersionTemplate("{{.Version}}\n")
+	output := outputTable
+	root.PersistentFlags().VarP(&output, "output", "o", "output format: table|wide|json|yaml")
+	root.PersistentFlags().StringVar(&engine, "engine", "", "container engine: "+strings.Join(docker.Engines, "|")+" (default from $"+docker.EngineEnv+", config.json, or docker)")
 
 	root.AddCommand(newCreateCmd(store, runs, compose))
+	root.AddCommand(newUpdateCmd(store, runs, compose))
+	root.AddCommand(newUpCmd(store, runs, compose))
+	root.AddCommand(newDownCmd(runs, compose))
 	root.AddCommand(newListCmd(runs, compose))
 	root.AddCommand(newStatusCmd(runs, compose))
+	root.AddCommand(newUICmd(runs, compose))
 	root.AddCommand(newStartCmd(runs, compose))
 	root.AddCommand(newStopCmd(runs, compose))
 	root.AddCommand(newRestartCmd(runs, compose))
 	root.AddCommand(newLogsCmd(runs, compose))
+	root.AddCommand(newAttachCmd(runs, compose))
+	root.AddCommand(newExecCmd(runs, compose))
 	root.AddCommand(newRemoveCmd(runs, compose))
-	root.AddCommand(newCredentialsCmd())
+	root.AddCommand(newPruneCmd(runs, compose))
+	root.AddCommand(newAdoptCmd(runs, compose))
+	root.AddCommand(newRenderCmd(runs))
+	root.AddCommand(newCredentialsCmd(runs, compose))
+	root.AddCommand(newDoctorCmd(store, runs, runner, compose))
 
 	if err := root.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, "Error:", err)
+		var exitErr *docker.ExitError
+		if !errors.As(err, &exitErr) {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
 		return err
 	}
 	return nil
 }
+
+// ExitCode maps an error returned by Execute to a process exit status.
+// Commands run inside a container keep their own status.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *docker.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return 1
+}
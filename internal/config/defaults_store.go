@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 
 	"github.com/openhoo/vibecontainer/internal/domain"
+	"github.com/openhoo/vibecontainer/internal/fsutil"
 )
 
 type DefaultsStore struct {
@@ -41,6 +42,9 @@ func (s *DefaultsStore) Load() (domain.Defaults, error) {
 	if defaults.TmuxAccess == "" {
 		defaults.TmuxAccess = "read"
 	}
+	if defaults.PortRange == "" {
+		defaults.PortRange = domain.DefaultDefaults().PortRange
+	}
 	return defaults, nil
 }
 
@@ -52,5 +56,5 @@ func (s *DefaultsStore) Save(defaults domain.Defaults) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(s.path, append(b, '\n'), 0o600)
+	return fsutil.WriteFile(s.path, append(b, '\n'), 0o600)
 }
@@ -37,3 +37,32 @@ func RunEnvPath(name string) string {
 func RunMetadataPath(name string) string {
 	return filepath.Join(RunDir(name), "run.json")
 }
+
+// LocksDir holds the lock files of stacks. They live outside RunsDir since a
+// stack is locked before its run dir exists and while it is deleted.
+func LocksDir() string {
+	return filepath.Join(DataDir(), "locks")
+}
+
+func StackLockPath(name string) string {
+	return filepath.Join(LocksDir(), name+".lock")
+}
+
+// GlobalLockPath is locked shared by commands that change one stack and
+// exclusively by those that change several. Stack names can't start with an
+// underscore, so it can't collide with a stack's lock.
+func GlobalLockPath() string {
+	return filepath.Join(LocksDir(), "_all.lock")
+}
+
+// PortsLockPath is held while ports are picked for a stack and saved, so
+// two stacks created at once can't pick the same port.
+func PortsLockPath() string {
+	return filepath.Join(LocksDir(), "_ports.lock")
+}
+
+// SecretsPath is the encrypted credential file used when no system keychain
+// is available.
+func SecretsPath() string {
+	return filepath.Join(ConfigDir(), "secrets.enc")
+}
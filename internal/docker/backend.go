@@ -0,0 +1,132 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/openhoo/vibecontainer/internal/domain"
+)
+
+// Backend runs stacks from the compose files in their run dirs. Compose
+// drives the docker, podman or nerdctl CLI; Engine talks to the Docker
+// Engine API directly.
+type Backend interface {
+	// Up creates or reconciles the stack's containers, only those of
+	// services when any are named.
+	Up(ctx context.Context, stack string, services ...string) error
+	Stop(ctx context.Context, stack string) error
+	Restart(ctx context.Context, stack string) error
+	Down(ctx context.Context, stack string) error
+	Logs(ctx context.Context, stack string, opts LogsOptions, stdout, stderr io.Writer) error
+	Status(ctx context.Context, stack string) ([]domain.ServiceStatus, error)
+	ListManagedContainers(ctx context.Context) ([]ManagedContainer, error)
+	RemoveStackContainers(ctx context.Context, stack string, containers []string) error
+	ContainerFor(ctx context.Context, stack, service string) (string, error)
+	Attach(ctx context.Context, container string, opts AttachOptions) error
+	Exec(ctx context.Context, container string, opts ExecOptions) error
+	Inspect(ctx context.Context, container string) (domain.ContainerInfo, error)
+	ImageEnv(ctx context.Context, image string) (map[string]string, error)
+	RemoveContainer(ctx context.Context, container string) error
+	StopContainer(ctx context.Context, container string) error
+	StartContainer(ctx context.Context, container string) error
+	// Events calls fn for every lifecycle event of a managed container
+	// until ctx is canceled.
+	Events(ctx context.Context, fn func(Event)) error
+	// Capabilities reports what the engine can grant containers.
+	Capabilities(ctx context.Context) (Capabilities, error)
+}
+
+var (
+	_ Backend = (*Compose)(nil)
+	_ Backend = (*Engine)(nil)
+)
+
+// NewBackend returns the backend for engine, one of Engines. docker-api
+// connects to the daemon the docker CLI would and needs no CLI.
+func NewBackend(engine string, runner Runner) (Backend, error) {
+	if engine == "docker-api" {
+		ep, err := EngineEndpoint()
+		if err != nil {
+			return nil, err
+		}
+		return NewEngine(ep)
+	}
+	dialect, err := DialectFor(engine)
+	if err != nil {
+		return nil, err
+	}
+	return NewComposeFor(runner, dialect), nil
+}
+
+// Event is a lifecycle change of a managed container, such as "start" or
+// "die".
+type Event struct {
+	Stack     string
+	Service   string
+	Container string
+	Action    string
+	Time      time.Time
+}
+
+// eventMessage is an event as printed by `docker events --format {{json .}}`
+// and streamed by the engine's /events endpoint. `podman events --format
+// json` prints a flatter event, read into the remaining fields.
+type eventMessage struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+	Actor  struct {
+		ID         string            `json:"ID"`
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+	TimeNano int64 `json:"timeNano"`
+
+	Status     string            `json:"Status"`
+	Name       string            `json:"Name"`
+	Attributes map[string]string `json:"Attributes"`
+	// Time is unix seconds from docker and RFC 3339 from podman.
+	Time json.RawMessage `json:"time"`
+}
+
+func (m eventMessage) event() Event {
+	attrs := m.Actor.Attributes
+	if attrs == nil {
+		attrs = m.Attributes
+	}
+	name := attrs["name"]
+	if name == "" {
+		name = m.Name
+	}
+	if name == "" {
+		name = m.Actor.ID
+	}
+	action := m.Action
+	if action == "" {
+		action = m.Status
+	}
+	return Event{
+		Stack:     attrs[stackLabel],
+		Service:   attrs[serviceLabel],
+		Container: name,
+		Action:    action,
+		Time:      m.time(),
+	}
+}
+
+func (m eventMessage) time() time.Time {
+	if m.TimeNano != 0 {
+		return time.Unix(0, m.TimeNano)
+	}
+	var secs int64
+	if json.Unmarshal(m.Time, &secs) == nil {
+		return time.Unix(secs, 0)
+	}
+	var stamp string
+	if json.Unmarshal(m.Time, &stamp) == nil {
+		if t, err := time.Parse(time.RFC3339Nano, stamp); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -1,27 +1,58 @@
 package docker
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os/exec"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/openhoo/vibecontainer/internal/config"
 	"github.com/openhoo/vibecontainer/internal/domain"
 )
 
-const managedLabel = "com.openhoo.vibecontainer.managed=true"
+const (
+	managedLabel = "com.openhoo.vibecontainer.managed=true"
+	stackLabel   = "com.openhoo.vibecontainer.stack"
+	serviceLabel = "com.openhoo.vibecontainer.service"
 
+	composeProjectLabel = "com.docker.compose.project"
+)
+
+// DefaultTmuxSession is the tmux session entrypoint.sh starts when
+// TMUX_SESSION_NAME is not set in the container.
+const DefaultTmuxSession = "vibe"
+
+// Compose runs stacks with a compose-capable container CLI: docker, podman
+// or nerdctl.
 type Compose struct {
-	runner Runner
+	runner  Runner
+	dialect Dialect
 }
 
+// NewCompose drives the docker CLI.
 func NewCompose(r Runner) *Compose {
-	return &Compose{runner: r}
+	return NewComposeFor(r, DockerCLI)
 }
 
-func (c *Compose) Up(ctx context.Context, stack string) error {
-	_, stderr, err := c.runner.Run(ctx, "docker", c.args(stack, "up", "-d")...)
+// NewComposeFor drives the CLI described by d.
+func NewComposeFor(r Runner, d Dialect) *Compose {
+	return &Compose{runner: r, dialect: d}
+}
+
+func (c *Compose) Up(ctx context.Context, stack string, services ...string) error {
+	args := []string{"up", "-d", "--remove-orphans"}
+	if len(services) > 0 {
+		// Leave the other services, and any orphans, alone.
+		args = append([]string{"up", "-d", "--no-deps"}, services...)
+	}
+	_, stderr, err := c.runner.Run(ctx, c.dialect.Binary, c.args(stack, args...)...)
 	if err != nil {
 		return fmt.Errorf("compose up failed: %w\n%s", err, strings.TrimSpace(stderr))
 	}
@@ -29,7 +60,7 @@ func (c *Compose) Up(ctx context.Context, stack string) error {
 }
 
 func (c *Compose) Stop(ctx context.Context, stack string) error {
-	_, stderr, err := c.runner.Run(ctx, "docker", c.args(stack, "stop")...)
+	_, stderr, err := c.runner.Run(ctx, c.dialect.Binary, c.args(stack, "stop")...)
 	if err != nil {
 		return fmt.Errorf("compose stop failed: %w\n%s", err, strings.TrimSpace(stderr))
 	}
@@ -37,7 +68,7 @@ func (c *Compose) Stop(ctx context.Context, stack string) error {
 }
 
 func (c *Compose) Restart(ctx context.Context, stack string) error {
-	_, stderr, err := c.runner.Run(ctx, "docker", c.args(stack, "restart")...)
+	_, stderr, err := c.runner.Run(ctx, c.dialect.Binary, c.args(stack, "restart")...)
 	if err != nil {
 		return fmt.Errorf("compose restart failed: %w\n%s", err, strings.TrimSpace(stderr))
 	}
@@ -45,30 +76,119 @@ func (c *Compose) Restart(ctx context.Context, stack string) error {
 }
 
 func (c *Compose) Down(ctx context.Context, stack string) error {
-	_, stderr, err := c.runner.Run(ctx, "docker", c.args(stack, "down", "--remove-orphans")...)
+	_, stderr, err := c.runner.Run(ctx, c.dialect.Binary, c.args(stack, "down", "--remove-orphans")...)
 	if err != nil {
 		return fmt.Errorf("compose down failed: %w\n%s", err, strings.TrimSpace(stderr))
 	}
 	return nil
 }
 
-func (c *Compose) Logs(ctx context.Context, stack, service string, follow bool) (string, error) {
+// LogsOptions controls which log lines Logs emits and how they are formatted.
+type LogsOptions struct {
+	Services   []string
+	Follow     bool
+	Tail       string
+	Since      string
+	Timestamps bool
+	// Prefix labels every line with the name of the service that wrote it.
+	Prefix bool
+}
+
+// Logs streams the logs of a stack to stdout until the underlying command
+// exits or ctx is canceled.
+func (c *Compose) Logs(ctx context.Context, stack string, opts LogsOptions, stdout, stderr io.Writer) error {
+	if !opts.Prefix {
+		args := c.logsArgs(stack, opts)
+		args = append(args, opts.Services...)
+		if err := c.runner.Stream(ctx, stdout, stderr, c.dialect.Binary, args...); err != nil {
+			return fmt.Errorf("compose logs failed: %w", err)
+		}
+		return nil
+	}
+
+	services := opts.Services
+	if len(services) == 0 {
+		var err error
+		services, err = c.Services(ctx, stack)
+		if err != nil {
+			return err
+		}
+	}
+	if len(services) == 0 {
+		return nil
+	}
+
+	// Compose prefixes lines with container names, which are derived from the
+	// stack name. Run one stream per service so lines carry the short
+	// service name instead, sharing a lock so lines never interleave.
+	width := 0
+	for _, s := range services {
+		width = max(width, len(s))
+	}
+	var mu sync.Mutex
+	errs := make(chan error, len(services))
+	for _, svc := range services {
+		go func(svc string) {
+			out := newPrefixWriter(stdout, &mu, svc, width)
+			args := append(c.logsArgs(stack, opts), svc)
+			err := c.runner.Stream(ctx, out, stderr, c.dialect.Binary, args...)
+			out.Flush()
+			errs <- err
+		}(svc)
+	}
+	var firstErr error
+	for range services {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return fmt.Errorf("compose logs failed: %w", firstErr)
+	}
+	return nil
+}
+
+// Services returns the service names defined in the stack's compose file.
+func (c *Compose) Services(ctx context.Context, stack string) ([]string, error) {
+	stdout, stderr, err := c.runner.Run(ctx, c.dialect.Binary, c.args(stack, "config", "--services")...)
+	if err != nil {
+		return nil, fmt.Errorf("compose config failed: %w\n%s", err, strings.TrimSpace(stderr))
+	}
+	var services []string
+	for _, line := range strings.Split(stdout, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			services = append(services, line)
+		}
+	}
+	sort.Strings(services)
+	return services, nil
+}
+
+func (c *Compose) logsArgs(stack string, opts LogsOptions) []string {
 	args := c.args(stack, "logs")
-	if follow {
+	if opts.Prefix {
+		args = append(args, "--no-log-prefix")
+	}
+	if opts.Follow {
 		args = append(args, "--follow")
 	}
-	if service != "" {
-		args = append(args, service)
+	if opts.Tail != "" {
+		args = append(args, "--tail", opts.Tail)
 	}
-	stdout, stderr, err := c.runner.Run(ctx, "docker", args...)
-	if err != nil {
-		return "", fmt.Errorf("compose logs failed: %w\n%s", err, strings.TrimSpace(stderr))
+	if opts.Since != "" {
+		args = append(args, "--since", opts.Since)
 	}
-	return stdout, nil
+	if opts.Timestamps {
+		args = append(args, "--timestamps")
+	}
+	return args
 }
 
 func (c *Compose) Status(ctx context.Context, stack string) ([]domain.ServiceStatus, error) {
-	stdout, stderr, err := c.runner.Run(ctx, "docker", c.args(stack, "ps", "--format", "json")...)
+	if !c.dialect.composePS {
+		return c.psStatus(ctx, stack)
+	}
+	stdout, stderr, err := c.runner.Run(ctx, c.dialect.Binary, c.args(stack, "ps", "--format", "json")...)
 	if err != nil {
 		return nil, fmt.Errorf("compose status failed: %w\n%s", err, strings.TrimSpace(stderr))
 	}
@@ -89,49 +209,198 @@ func (c *Compose) Status(ctx context.Context, stack string) ([]domain.ServiceSta
 }
 
 type ManagedContainer struct {
-	Name   string
-	State  string
-	Labels map[string]string
+	Name    string
+	Service string
+	State   string
+	Health  string
+	Labels  map[string]string
 }
 
 func (c *Compose) ListManagedContainers(ctx context.Context) ([]ManagedContainer, error) {
-	stdout, stderr, err := c.runner.Run(
-		ctx,
-		"docker",
-		"ps",
-		"-a",
-		"--filter",
-		"label="+managedLabel,
-		"--format",
-		"{{json .}}",
-	)
+	items, err := c.ps(ctx, managedLabel)
 	if err != nil {
-		return nil, fmt.Errorf("docker ps failed: %w\n%s", err, strings.TrimSpace(stderr))
-	}
-	stdout = strings.TrimSpace(stdout)
-	if stdout == "" {
-		return []ManagedContainer{}, nil
+		return nil, err
 	}
-	lines := strings.Split(stdout, "\n")
-	out := make([]ManagedContainer, 0, len(lines))
-	for _, line := range lines {
-		var item struct {
-			Names  string `json:"Names"`
-			State  string `json:"State"`
-			Labels string `json:"Labels"`
-		}
-		if err := json.Unmarshal([]byte(line), &item); err != nil {
-			return nil, fmt.Errorf("parse docker ps output: %w", err)
-		}
+	out := make([]ManagedContainer, 0, len(items))
+	for _, item := range items {
+		labels := item.labels()
 		out = append(out, ManagedContainer{
-			Name:   item.Names,
-			State:  item.State,
-			Labels: parseLabelString(item.Labels),
+			Name:    item.name(),
+			Service: labels[serviceLabel],
+			State:   item.state(),
+			Health:  healthFromStatus(item.Status),
+			Labels:  labels,
 		})
 	}
 	return out, nil
 }
 
+// RemoveStackContainers force-removes the given containers of a stack that
+// has no compose file any more, along with the networks compose created for
+// it. Networks are removed best effort.
+func (c *Compose) RemoveStackContainers(ctx context.Context, stack string, containers []string) error {
+	if len(containers) > 0 {
+		args := append([]string{"rm", "-f"}, containers...)
+		if _, stderr, err := c.runner.Run(ctx, c.dialect.Binary, args...); err != nil {
+			return fmt.Errorf("%s rm failed: %w\n%s", c.dialect.Binary, err, strings.TrimSpace(stderr))
+		}
+	}
+	stdout, _, err := c.runner.Run(ctx, c.dialect.Binary, "network", "ls", "-q", "--filter", "label="+composeProjectLabel+"="+stack)
+	if err != nil {
+		return nil
+	}
+	for _, id := range strings.Fields(stdout) {
+		_, _, _ = c.runner.Run(ctx, c.dialect.Binary, "network", "rm", id)
+	}
+	return nil
+}
+
+// ContainerFor returns the name of the container running service in stack.
+// It prefers the managed labels and falls back to the container_name that
+// stack.ComposeYAML assigns.
+func (c *Compose) ContainerFor(ctx context.Context, stack, service string) (string, error) {
+	managed, err := c.ListManagedContainers(ctx)
+	if err != nil {
+		return "", err
+	}
+	return runningContainer(managed, stack, service)
+}
+
+// runningContainer picks the running container of service in stack.
+func runningContainer(managed []ManagedContainer, stack, service string) (string, error) {
+	match := func(m ManagedContainer) bool {
+		return m.Labels[stackLabel] == stack && m.Labels[serviceLabel] == service
+	}
+	if !slices.ContainsFunc(managed, match) {
+		// Containers created without the stack labels still carry the
+		// container_name stack.ComposeYAML assigns.
+		match = func(m ManagedContainer) bool { return m.Name == stack+"-"+service }
+	}
+	for _, m := range managed {
+		if match(m) {
+			if m.State != "running" {
+				return "", fmt.Errorf("container %s is %s; start the stack first", m.Name, m.State)
+			}
+			return m.Name, nil
+		}
+	}
+	return "", fmt.Errorf("no %s container found for stack %q; start the stack first", service, stack)
+}
+
+// AttachOptions controls how Attach connects to the tmux session.
+type AttachOptions struct {
+	ReadOnly bool
+	Stdin    io.Reader
+	Stdout   io.Writer
+	Stderr   io.Writer
+}
+
+// Attach connects the caller's terminal to the tmux session inside container.
+// The session name is resolved inside the container so TMUX_SESSION_NAME
+// overrides are honored.
+func (c *Compose) Attach(ctx context.Context, container string, opts AttachOptions) error {
+	args := append([]string{"exec", "-it", "-u", "dev", container}, attachCommand(opts.ReadOnly)...)
+	if err := c.runner.Interactive(ctx, opts.Stdin, opts.Stdout, opts.Stderr, c.dialect.Binary, args...); err != nil {
+		return fmt.Errorf("attach failed: %w", err)
+	}
+	return nil
+}
+
+// attachCommand attaches to the tmux session, resolving its name inside
+// the container so TMUX_SESSION_NAME overrides are honored.
+func attachCommand(readOnly bool) []string {
+	attach := "tmux attach-session"
+	if readOnly {
+		attach += " -r"
+	}
+	return []string{"sh", "-c", fmt.Sprintf(`exec %s -t "${TMUX_SESSION_NAME:-%s}"`, attach, DefaultTmuxSession)}
+}
+
+// ExecOptions describes a one-off command to run inside a container.
+type ExecOptions struct {
+	Command []string
+	User    string
+	Workdir string
+	Env     []string
+	TTY     bool
+	Stdin   io.Reader
+	Stdout  io.Writer
+	Stderr  io.Writer
+}
+
+// ExitError reports that a command run inside a container exited non-zero.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("command exited with status %d", e.Code)
+}
+
+// Exec runs a command inside container. A non-zero exit status from the
+// command is returned as *ExitError.
+func (c *Compose) Exec(ctx context.Context, container string, opts ExecOptions) error {
+	args := []string{"exec", "-i"}
+	if opts.TTY {
+		args = append(args, "-t")
+	}
+	if opts.User != "" {
+		args = append(args, "-u", opts.User)
+	}
+	if opts.Workdir != "" {
+		args = append(args, "-w", opts.Workdir)
+	}
+	for _, e := range opts.Env {
+		args = append(args, "-e", e)
+	}
+	args = append(args, container)
+	args = append(args, opts.Command...)
+	err := c.runner.Interactive(ctx, opts.Stdin, opts.Stdout, opts.Stderr, c.dialect.Binary, args...)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+			return &ExitError{Code: exitErr.ExitCode()}
+		}
+		return fmt.Errorf("exec failed: %w", err)
+	}
+	return nil
+}
+
+// Events runs `docker events` (or `podman events`) and passes on the
+// container events of managed stacks. nerdctl can't filter events by label,
+// so it is not supported there.
+func (c *Compose) Events(ctx context.Context, fn func(Event)) error {
+	if !c.dialect.events {
+		return fmt.Errorf("%s does not support container events", c.dialect.Title)
+	}
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := c.runner.Stream(ctx, pw, io.Discard, c.dialect.Binary, "events",
+			"--filter", "type=container",
+			"--filter", "label="+managedLabel,
+			"--format", c.dialect.psFormat)
+		pw.CloseWithError(err)
+		done <- err
+	}()
+	sc := bufio.NewScanner(pr)
+	for sc.Scan() {
+		var msg eventMessage
+		if err := json.Unmarshal(sc.Bytes(), &msg); err != nil {
+			continue
+		}
+		fn(msg.event())
+	}
+	pr.Close()
+	if err := <-done; err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%s events failed: %w", c.dialect.Binary, err)
+	}
+	return nil
+}
+
 func (c *Compose) args(stack string, cmd ...string) []string {
 	args := []string{
 		"compose",
@@ -146,6 +415,21 @@ func (c *Compose) args(stack string, cmd ...string) []string {
 	return args
 }
 
+// healthFromStatus extracts the healthcheck state from the Status column
+// of docker ps, e.g. "Up 2 minutes (healthy)".
+func healthFromStatus(status string) string {
+	start := strings.LastIndex(status, "(")
+	if start < 0 || !strings.HasSuffix(status, ")") {
+		return ""
+	}
+	health := strings.TrimPrefix(status[start+1:len(status)-1], "health: ")
+	switch health {
+	case "healthy", "unhealthy", "starting":
+		return health
+	}
+	return ""
+}
+
 func parseLabelString(s string) map[string]string {
 	labels := map[string]string{}
 	if strings.TrimSpace(s) == "" {
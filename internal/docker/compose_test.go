@@ -0,0 +1,271 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/openhoo/vibecontainer/internal/domain"
+)
+
+// fakeRunner records invocations and answers them from canned output keyed
+// by the docker subcommand that follows the compose flags.
+type fakeRunner struct {
+	mu     sync.Mutex
+	calls  [][]string
+	stdout map[string]string
+	err    error
+}
+
+func (f *fakeRunner) record(cmd string, args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, append([]string{cmd}, args...))
+	return subcommand(args)
+}
+
+func (f *fakeRunner) Run(ctx context.Context, cmd string, args ...string) (string, string, error) {
+	key := f.record(cmd, args)
+	return f.stdout[key], "", f.err
+}
+
+func (f *fakeRunner) Stream(ctx context.Context, stdout, stderr io.Writer, cmd string, args ...string) error {
+	f.record(cmd, args)
+	svc := args[len(args)-1]
+	fmt.Fprintf(stdout, "hello from %s\nsecond", svc)
+	return f.err
+}
+
+func (f *fakeRunner) Interactive(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, cmd string, args ...string) error {
+	f.record(cmd, args)
+	return f.err
+}
+
+// subcommand skips the "compose -p x -f y --env-file z" preamble.
+func subcommand(args []string) string {
+	if len(args) > 7 && args[0] == "compose" {
+		return strings.Join(args[7:9], " ")
+	}
+	if len(args) > 0 {
+		return args[0]
+	}
+	return ""
+}
+
+func (f *fakeRunner) find(prefix string) []string {
+	for _, c := range f.calls {
+		if strings.Contains(strings.Join(c, " "), prefix) {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestLogsPrefixesEachService(t *testing.T) {
+	r := &fakeRunner{stdout: map[string]string{"config --services": "vibecontainer\ncloudflared\n"}}
+	c := NewCompose(r)
+
+	var out bytes.Buffer
+	err := c.Logs(context.Background(), "demo", LogsOptions{Prefix: true, Follow: true, Tail: "10"}, &out, io.Discard)
+	if err != nil {
+		t.Fatalf("Logs failed: %v", err)
+	}
+	s := out.String()
+	for _, want := range []string{
+		"vibecontainer | hello from vibecontainer\n",
+		"cloudflared   | hello from cloudflared\n",
+		"cloudflared   | second\n",
+	} {
+		if !strings.Contains(s, want) {
+			t.Fatalf("expected output to contain %q, got:\n%s", want, s)
+		}
+	}
+	call := r.find("logs --no-log-prefix")
+	if call == nil {
+		t.Fatalf("expected a logs call, got %v", r.calls)
+	}
+	joined := strings.Join(call, " ")
+	if !strings.Contains(joined, "--follow") || !strings.Contains(joined, "--tail 10") {
+		t.Fatalf("expected follow and tail flags, got %q", joined)
+	}
+}
+
+func TestLogsWithoutPrefixStreamsOnce(t *testing.T) {
+	r := &fakeRunner{}
+	c := NewCompose(r)
+
+	var out bytes.Buffer
+	opts := LogsOptions{Services: []string{"vibecontainer"}, Since: "5m", Timestamps: true}
+	if err := c.Logs(context.Background(), "demo", opts, &out, io.Discard); err != nil {
+		t.Fatalf("Logs failed: %v", err)
+	}
+	if len(r.calls) != 1 {
+		t.Fatalf("expected a single docker call, got %v", r.calls)
+	}
+	joined := strings.Join(r.calls[0], " ")
+	if strings.Contains(joined, "--no-log-prefix") {
+		t.Fatalf("did not expect --no-log-prefix, got %q", joined)
+	}
+	if !strings.Contains(joined, "--since 5m") || !strings.Contains(joined, "--timestamps") {
+		t.Fatalf("expected since and timestamps flags, got %q", joined)
+	}
+	if out.String() != "hello from vibecontainer\nsecond" {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+}
+
+func TestPrefixWriterBuffersPartialLines(t *testing.T) {
+	var out bytes.Buffer
+	var mu sync.Mutex
+	w := newPrefixWriter(&out, &mu, "svc", 5)
+	_, _ = w.Write([]byte("par"))
+	_, _ = w.Write([]byte("tial\nnext"))
+	if out.String() != "svc   | partial\n" {
+		t.Fatalf("unexpected output before flush %q", out.String())
+	}
+	w.Flush()
+	if out.String() != "svc   | partial\nsvc   | next\n" {
+		t.Fatalf("unexpected output after flush %q", out.String())
+	}
+}
+
+const psOutput = `{"Names":"demo-vibecontainer","State":"running","Labels":"com.openhoo.vibecontainer.managed=true,com.openhoo.vibecontainer.stack=demo,com.openhoo.vibecontainer.service=vibecontainer"}
+{"Names":"demo-cloudflared","State":"running","Labels":"com.openhoo.vibecontainer.managed=true,com.openhoo.vibecontainer.stack=demo,com.openhoo.vibecontainer.service=cloudflared"}
+{"Names":"idle-vibecontainer","State":"exited","Labels":"com.openhoo.vibecontainer.managed=true,com.openhoo.vibecontainer.stack=idle,com.openhoo.vibecontainer.service=vibecontainer"}`
+
+func TestContainerForUsesLabels(t *testing.T) {
+	c := NewCompose(&fakeRunner{stdout: map[string]string{"ps": psOutput}})
+
+	name, err := c.ContainerFor(context.Background(), "demo", "vibecontainer")
+	if err != nil {
+		t.Fatalf("ContainerFor failed: %v", err)
+	}
+	if name != "demo-vibecontainer" {
+		t.Fatalf("expected demo-vibecontainer, got %q", name)
+	}
+	if _, err := c.ContainerFor(context.Background(), "idle", "vibecontainer"); err == nil {
+		t.Fatal("expected error for stopped container")
+	}
+	if _, err := c.ContainerFor(context.Background(), "missing", "vibecontainer"); err == nil {
+		t.Fatal("expected error for unknown stack")
+	}
+}
+
+func TestRunningContainerFallsBackToName(t *testing.T) {
+	managed := []ManagedContainer{{Name: "demo-vibecontainer", State: "running", Labels: map[string]string{}}}
+	if name, err := runningContainer(managed, "demo", "vibecontainer"); err != nil || name != "demo-vibecontainer" {
+		t.Fatalf("got %q, %v", name, err)
+	}
+	if _, err := runningContainer(managed, "demo", "cloudflared"); err == nil {
+		t.Fatal("expected no cloudflared container")
+	}
+}
+
+func TestAttachReadOnly(t *testing.T) {
+	r := &fakeRunner{}
+	c := NewCompose(r)
+
+	if err := c.Attach(context.Background(), "demo-vibecontainer", AttachOptions{ReadOnly: true}); err != nil {
+		t.Fatalf("Attach failed: %v", err)
+	}
+	joined := strings.Join(r.calls[0], " ")
+	for _, want := range []string{"docker exec -it -u dev demo-vibecontainer", "tmux attach-session -r", "${TMUX_SESSION_NAME:-vibe}"} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("expected %q in %q", want, joined)
+		}
+	}
+}
+
+func TestExecBuildsDockerExecArgs(t *testing.T) {
+	r := &fakeRunner{}
+	c := NewCompose(r)
+
+	err := c.Exec(context.Background(), "demo-vibecontainer", ExecOptions{
+		Command: []string{"git", "status"},
+		User:    "dev",
+		Workdir: "/workspace",
+		Env:     []string{"FOO=bar"},
+	})
+	if err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	want := "docker exec -i -u dev -w /workspace -e FOO=bar demo-vibecontainer git status"
+	if got := strings.Join(r.calls[0], " "); got != want {
+		t.Fatalf("unexpected command\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestRemoveStackContainersRemovesNetworks(t *testing.T) {
+	r := &fakeRunner{stdout: map[string]string{"network": "net1\n"}}
+	c := NewCompose(r)
+
+	if err := c.RemoveStackContainers(context.Background(), "orphan", []string{"orphan-vibecontainer"}); err != nil {
+		t.Fatalf("RemoveStackContainers failed: %v", err)
+	}
+	want := []string{
+		"docker rm -f orphan-vibecontainer",
+		"docker network ls -q --filter label=com.docker.compose.project=orphan",
+		"docker network rm net1",
+	}
+	for i, w := range want {
+		if got := strings.Join(r.calls[i], " "); got != w {
+			t.Fatalf("call %d: got %q, want %q", i, got, w)
+		}
+	}
+}
+
+func TestInspectParsesContainer(t *testing.T) {
+	r := &fakeRunner{stdout: map[string]string{"inspect": `[{
+		"Name": "/dev-box",
+		"Config": {
+			"Image": "ghcr.io/openhoo/vibecontainer:claude",
+			"Env": ["TMUX_WEB_ENABLE=1", "GREETING=a=b"],
+			"Labels": null
+		},
+		"State": {"Status": "running"},
+		"HostConfig": {
+			"CapAdd": ["NET_ADMIN"],
+			"PortBindings": {"7682/tcp": [{"HostIp": "", "HostPort": "9002"}], "7681/tcp": [{"HostIp": "127.0.0.1", "HostPort": "9001"}]}
+		},
+		"Mounts": [{"Type": "bind", "Source": "/src", "Destination": "/workspace", "RW": true}]
+	}]`}}
+	info, err := NewCompose(r).Inspect(context.Background(), "dev-box")
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if info.Name != "dev-box" || info.State != "running" || info.Labels == nil {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+	if info.Env["GREETING"] != "a=b" {
+		t.Fatalf("env not split on first '=': %v", info.Env)
+	}
+	if len(info.Ports) != 2 || info.Ports[0] != (domain.PortBinding{HostIP: "127.0.0.1", HostPort: 9001, ContainerPort: 7681}) {
+		t.Fatalf("unexpected ports: %+v", info.Ports)
+	}
+	if len(info.Mounts) != 1 || info.Mounts[0].ReadOnly || info.Mounts[0].Destination != "/workspace" {
+		t.Fatalf("unexpected mounts: %+v", info.Mounts)
+	}
+}
+
+func TestListManagedContainersReadsServiceAndHealth(t *testing.T) {
+	r := &fakeRunner{stdout: map[string]string{"ps": `{"Names":"demo-vibecontainer","State":"running","Status":"Up 2 minutes (healthy)","Labels":"com.openhoo.vibecontainer.stack=demo,com.openhoo.vibecontainer.service=vibecontainer"}
+{"Names":"demo-cloudflared","State":"running","Status":"Up 2 minutes (health: starting)","Labels":"com.openhoo.vibecontainer.service=cloudflared"}
+{"Names":"other","State":"exited","Status":"Exited (1) 3 hours ago","Labels":""}`}}
+	got, err := NewCompose(r).ListManagedContainers(context.Background())
+	if err != nil {
+		t.Fatalf("ListManagedContainers failed: %v", err)
+	}
+	want := []struct{ service, health string }{{"vibecontainer", "healthy"}, {"cloudflared", "starting"}, {"", ""}}
+	if len(got) != len(want) {
+		t.Fatalf("got %d containers, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].Service != w.service || got[i].Health != w.health {
+			t.Errorf("container %d: got service %q health %q, want %q %q", i, got[i].Service, got[i].Health, w.service, w.health)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/openhoo/vibecontainer/internal/domain"
+)
+
+// Dialect describes a container CLI that Compose can drive. The compose
+// subcommands take the same arguments everywhere; ps, info and events print
+// differently shaped JSON.
+type Dialect struct {
+	// Name is the engine name accepted by --engine.
+	Name string
+	// Binary is the command that is run.
+	Binary string
+	// Title names the engine in messages.
+	Title string
+	// InstallURL explains how to install the engine.
+	InstallURL string
+
+	// psFormat is the --format value that makes ps and info print JSON.
+	psFormat string
+	// composePS is set when `compose ps --format json` prints the fields of
+	// domain.ServiceStatus. Other compose implementations are read with ps
+	// and the compose project label instead.
+	composePS bool
+	// events is set when `events` can filter by label and print JSON.
+	events bool
+}
+
+var (
+	DockerCLI = Dialect{
+		Name:       "docker",
+		Binary:     "docker",
+		Title:      "Docker",
+		InstallURL: "https://docs.docker.com/get-docker/",
+		psFormat:   "{{json .}}",
+		composePS:  true,
+		events:     true,
+	}
+	PodmanCLI = Dialect{
+		Name:       "podman",
+		Binary:     "podman",
+		Title:      "Podman",
+		InstallURL: "https://podman.io/docs/installation",
+		psFormat:   "json",
+		events:     true,
+	}
+	NerdctlCLI = Dialect{
+		Name:       "nerdctl",
+		Binary:     "nerdctl",
+		Title:      "nerdctl",
+		InstallURL: "https://github.com/containerd/nerdctl#install",
+		psFormat:   "{{json .}}",
+	}
+)
+
+// EngineEnv selects the engine when --engine is not given. It overrides the
+// engine saved in config.json.
+const EngineEnv = "VIBECONTAINER_ENGINE"
+
+// Engines lists the values accepted for --engine. docker-api talks to the
+// Docker Engine API instead of running the docker CLI.
+var Engines = []string{"docker", "docker-api", "podman", "nerdctl"}
+
+// DialectFor returns the CLI dialect used by engine. docker-api doesn't
+// run a CLI; it is reported as docker, which doctor checks.
+func DialectFor(engine string) (Dialect, error) {
+	switch engine {
+	case "", "docker", "docker-api":
+		return DockerCLI, nil
+	case "podman":
+		return PodmanCLI, nil
+	case "nerdctl":
+		return NerdctlCLI, nil
+	}
+	return Dialect{}, fmt.Errorf("unknown engine %q (want %s)", engine, strings.Join(Engines, "|"))
+}
+
+// SelectEngine picks the engine to use: flag when set, then EngineEnv, then
+// the saved default, then docker.
+func SelectEngine(flag, saved string) string {
+	for _, engine := range []string{flag, os.Getenv(EngineEnv), saved} {
+		if engine != "" {
+			return engine
+		}
+	}
+	return "docker"
+}
+
+// Capabilities are what the engine can grant the containers of a stack.
+type Capabilities struct {
+	// ServerVersion is the version of the daemon or, for daemonless
+	// engines, of the engine itself.
+	ServerVersion string
+	// Rootless engines run containers in a user namespace, where NET_ADMIN
+	// and NET_RAW may not let ufw load its rules. Whether they do depends on
+	// the host's kernel and modules, which info doesn't report.
+	Rootless bool
+}
+
+// engineInfo holds the fields of `info` that Capabilities reads. docker and
+// nerdctl print ServerVersion and SecurityOptions; podman nests them under
+// host and version.
+type engineInfo struct {
+	ServerVersion   string   `json:"ServerVersion"`
+	SecurityOptions []string `json:"SecurityOptions"`
+	Host            struct {
+		Security struct {
+			Rootless bool `json:"rootless"`
+		} `json:"security"`
+	} `json:"host"`
+	Version struct {
+		Version string `json:"Version"`
+	} `json:"version"`
+}
+
+func (i engineInfo) capabilities() Capabilities {
+	caps := Capabilities{ServerVersion: i.ServerVersion, Rootless: i.Host.Security.Rootless}
+	if caps.ServerVersion == "" {
+		caps.ServerVersion = i.Version.Version
+	}
+	for _, opt := range i.SecurityOptions {
+		if strings.Contains(opt, "name=rootless") {
+			caps.Rootless = true
+		}
+	}
+	return caps
+}
+
+// Capabilities asks the engine whether it runs rootless and so whether the
+// container firewall may not work.
+func (c *Compose) Capabilities(ctx context.Context) (Capabilities, error) {
+	stdout, stderr, err := c.runner.Run(ctx, c.dialect.Binary, "info", "--format", c.dialect.psFormat)
+	if err != nil {
+		return Capabilities{}, fmt.Errorf("%s info failed: %w\n%s", c.dialect.Binary, err, strings.TrimSpace(stderr))
+	}
+	var info engineInfo
+	if err := json.Unmarshal([]byte(strings.TrimSpace(stdout)), &info); err != nil {
+		return Capabilities{}, fmt.Errorf("parse %s info output: %w", c.dialect.Binary, err)
+	}
+	caps := info.capabilities()
+	if caps.ServerVersion == "" {
+		return Capabilities{}, fmt.Errorf("%s info did not report a server version", c.dialect.Binary)
+	}
+	return caps, nil
+}
+
+// psItem is one container as printed by ps in JSON. docker and nerdctl print
+// one object per line with Names, Labels and Ports as strings; podman prints
+// an array with Names as a list and Labels and Ports as objects. nerdctl has
+// no State column.
+type psItem struct {
+	Names  json.RawMessage `json:"Names"`
+	Image  string          `json:"Image"`
+	State  string          `json:"State"`
+	Status string          `json:"Status"`
+	Labels json.RawMessage `json:"Labels"`
+	Ports  json.RawMessage `json:"Ports"`
+}
+
+// decodePS parses ps output that is either a JSON array or JSON lines.
+func decodePS(stdout string) ([]psItem, error) {
+	stdout = strings.TrimSpace(stdout)
+	if stdout == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(stdout, "[") {
+		var items []psItem
+		if err := json.Unmarshal([]byte(stdout), &items); err != nil {
+			return nil, err
+		}
+		return items, nil
+	}
+	lines := strings.Split(stdout, "\n")
+	items := make([]psItem, 0, len(lines))
+	for _, line := range lines {
+		var item psItem
+		if err := json.Unmarshal([]byte(line), &item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (p psItem) name() string {
+	var name string
+	if json.Unmarshal(p.Names, &name) == nil {
+		return name
+	}
+	var names []string
+	if json.Unmarshal(p.Names, &names) == nil && len(names) > 0 {
+		return names[0]
+	}
+	return ""
+}
+
+func (p psItem) labels() map[string]string {
+	var s string
+	if json.Unmarshal(p.Labels, &s) == nil {
+		return parseLabelString(s)
+	}
+	labels := map[string]string{}
+	_ = json.Unmarshal(p.Labels, &labels)
+	return labels
+}
+
+// state returns the container state, deriving it from the Status column
+// when the engine doesn't print one.
+func (p psItem) state() string {
+	if p.State != "" {
+		return strings.ToLower(p.State)
+	}
+	status := strings.ToLower(p.Status)
+	switch {
+	case strings.HasPrefix(status, "up"):
+		if strings.Contains(status, "paused") {
+			return "paused"
+		}
+		return "running"
+	case strings.HasPrefix(status, "exited"):
+		return "exited"
+	case strings.HasPrefix(status, "created"):
+		return "created"
+	case strings.HasPrefix(status, "restarting"):
+		return "restarting"
+	case strings.HasPrefix(status, "paused"):
+		return "paused"
+	}
+	return status
+}
+
+// publishers reads Ports, either docker's "127.0.0.1:7681->7681/tcp, ..."
+// string or podman's list of mappings.
+func (p psItem) publishers() []domain.Publisher {
+	var s string
+	if json.Unmarshal(p.Ports, &s) == nil {
+		var out []domain.Publisher
+		for _, spec := range strings.Split(s, ",") {
+			host, target, ok := strings.Cut(strings.TrimSpace(spec), "->")
+			if !ok {
+				continue
+			}
+			pub := domain.Publisher{Protocol: "tcp"}
+			if i := strings.LastIndex(host, ":"); i >= 0 {
+				pub.URL = host[:i]
+				pub.PublishedPort, _ = strconv.Atoi(host[i+1:])
+			}
+			port, proto, ok := strings.Cut(target, "/")
+			if ok {
+				pub.Protocol = proto
+			}
+			pub.TargetPort, _ = strconv.Atoi(port)
+			out = append(out, pub)
+		}
+		return out
+	}
+	var mappings []struct {
+		HostIP        string `json:"host_ip"`
+		ContainerPort int    `json:"container_port"`
+		HostPort      int    `json:"host_port"`
+		Protocol      string `json:"protocol"`
+	}
+	if json.Unmarshal(p.Ports, &mappings) != nil {
+		return nil
+	}
+	out := make([]domain.Publisher, 0, len(mappings))
+	for _, m := range mappings {
+		out = append(out, domain.Publisher{URL: m.HostIP, TargetPort: m.ContainerPort, PublishedPort: m.HostPort, Protocol: m.Protocol})
+	}
+	return out
+}
+
+// ps lists all containers carrying label.
+func (c *Compose) ps(ctx context.Context, label string) ([]psItem, error) {
+	stdout, stderr, err := c.runner.Run(ctx, c.dialect.Binary, "ps", "-a", "--filter", "label="+label, "--format", c.dialect.psFormat)
+	if err != nil {
+		return nil, fmt.Errorf("%s ps failed: %w\n%s", c.dialect.Binary, err, strings.TrimSpace(stderr))
+	}
+	items, err := decodePS(stdout)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s ps output: %w", c.dialect.Binary, err)
+	}
+	return items, nil
+}
+
+// psStatus reads the services of a stack from ps, for compose
+// implementations whose own ps output differs from docker compose's.
+func (c *Compose) psStatus(ctx context.Context, stack string) ([]domain.ServiceStatus, error) {
+	items, err := c.ps(ctx, composeProjectLabel+"="+stack)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]domain.ServiceStatus, 0, len(items))
+	for _, item := range items {
+		statuses = append(statuses, domain.ServiceStatus{
+			Name:       item.name(),
+			Service:    item.labels()[composeServiceLabel],
+			Image:      item.Image,
+			State:      item.state(),
+			Health:     healthFromStatus(item.Status),
+			Project:    stack,
+			Publishers: item.publishers(),
+		})
+	}
+	return statuses, nil
+}
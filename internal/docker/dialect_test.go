@@ -0,0 +1,125 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openhoo/vibecontainer/internal/domain"
+)
+
+func TestPodmanListsManagedContainers(t *testing.T) {
+	r := &fakeRunner{stdout: map[string]string{"ps": `[
+		{"Names": ["demo-vibecontainer"], "State": "running", "Status": "Up 2 minutes (healthy)",
+		 "Labels": {"com.openhoo.vibecontainer.stack": "demo", "com.openhoo.vibecontainer.service": "vibecontainer"}},
+		{"Names": ["idle-vibecontainer"], "State": "exited", "Status": "Exited (0) 1 hour ago", "Labels": null}
+	]`}}
+	got, err := NewComposeFor(r, PodmanCLI).ListManagedContainers(context.Background())
+	if err != nil {
+		t.Fatalf("ListManagedContainers failed: %v", err)
+	}
+	want := "podman ps -a --filter label=" + managedLabel + " --format json"
+	if call := strings.Join(r.calls[0], " "); call != want {
+		t.Fatalf("unexpected command\n got: %s\nwant: %s", call, want)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d containers, want 2", len(got))
+	}
+	if got[0].Name != "demo-vibecontainer" || got[0].Service != "vibecontainer" || got[0].State != "running" || got[0].Health != "healthy" {
+		t.Fatalf("unexpected container: %+v", got[0])
+	}
+	if got[1].State != "exited" || len(got[1].Labels) != 0 {
+		t.Fatalf("unexpected container: %+v", got[1])
+	}
+}
+
+func TestNerdctlStatusReadsPS(t *testing.T) {
+	r := &fakeRunner{stdout: map[string]string{"ps": `{"Names":"demo-vibecontainer","Image":"ghcr.io/openhoo/vibecontainer:codex","Status":"Up 5 minutes","Ports":"127.0.0.1:7681->7681/tcp","Labels":"com.docker.compose.project=demo,com.docker.compose.service=vibecontainer"}
+{"Names":"demo-cloudflared","Image":"cloudflare/cloudflared","Status":"Created","Ports":"","Labels":"com.docker.compose.service=cloudflared"}`}}
+	got, err := NewComposeFor(r, NerdctlCLI).Status(context.Background(), "demo")
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	want := "nerdctl ps -a --filter label=com.docker.compose.project=demo --format {{json .}}"
+	if call := strings.Join(r.calls[0], " "); call != want {
+		t.Fatalf("unexpected command\n got: %s\nwant: %s", call, want)
+	}
+	wantStatus := []domain.ServiceStatus{
+		{
+			Name: "demo-vibecontainer", Service: "vibecontainer", Image: "ghcr.io/openhoo/vibecontainer:codex",
+			State: "running", Project: "demo",
+			Publishers: []domain.Publisher{{URL: "127.0.0.1", TargetPort: 7681, PublishedPort: 7681, Protocol: "tcp"}},
+		},
+		{Name: "demo-cloudflared", Service: "cloudflared", Image: "cloudflare/cloudflared", State: "created", Project: "demo"},
+	}
+	if !reflect.DeepEqual(got, wantStatus) {
+		t.Fatalf("unexpected status\n got: %+v\nwant: %+v", got, wantStatus)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	cases := []struct {
+		name     string
+		dialect  Dialect
+		info     string
+		rootless bool
+	}{
+		{"docker", DockerCLI, `{"ServerVersion":"27.3.1","SecurityOptions":["name=seccomp,profile=builtin"]}`, false},
+		{"docker rootless", DockerCLI, `{"ServerVersion":"27.3.1","SecurityOptions":["name=seccomp,profile=builtin","name=rootless"]}`, true},
+		{"podman rootless", PodmanCLI, `{"host":{"security":{"rootless":true}},"version":{"Version":"5.2.2"}}`, true},
+		{"podman rootful", PodmanCLI, `{"host":{"security":{"rootless":false}},"version":{"Version":"5.2.2"}}`, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &fakeRunner{stdout: map[string]string{"info": tc.info}}
+			caps, err := NewComposeFor(r, tc.dialect).Capabilities(context.Background())
+			if err != nil {
+				t.Fatalf("Capabilities failed: %v", err)
+			}
+			if caps.ServerVersion == "" || caps.Rootless != tc.rootless {
+				t.Fatalf("unexpected capabilities: %+v", caps)
+			}
+			if r.calls[0][0] != tc.dialect.Binary {
+				t.Fatalf("ran %q, want %q", r.calls[0][0], tc.dialect.Binary)
+			}
+		})
+	}
+}
+
+func TestPodmanEventMessage(t *testing.T) {
+	var msg eventMessage
+	line := `{"ID":"abc","Image":"ghcr.io/openhoo/vibecontainer:codex","Name":"demo-vibecontainer","Status":"died","Time":"2026-01-02T13:00:00Z","Type":"container","Attributes":{"com.openhoo.vibecontainer.stack":"demo","com.openhoo.vibecontainer.service":"vibecontainer"}}`
+	if err := json.Unmarshal([]byte(line), &msg); err != nil {
+		t.Fatal(err)
+	}
+	want := Event{Stack: "demo", Service: "vibecontainer", Container: "demo-vibecontainer", Action: "died", Time: mustTime(t, "2026-01-02T13:00:00Z")}
+	if got := msg.event(); !got.Time.Equal(want.Time) || got.Stack != want.Stack || got.Service != want.Service || got.Container != want.Container || got.Action != want.Action {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	if err := NewComposeFor(&fakeRunner{}, NerdctlCLI).Events(context.Background(), func(Event) {}); err == nil {
+		t.Fatal("expected nerdctl events to be unsupported")
+	}
+}
+
+func TestSelectEngine(t *testing.T) {
+	t.Setenv(EngineEnv, "")
+	if got := SelectEngine("", ""); got != "docker" {
+		t.Fatalf("default engine: got %q", got)
+	}
+	if got := SelectEngine("", "podman"); got != "podman" {
+		t.Fatalf("saved engine: got %q", got)
+	}
+	t.Setenv(EngineEnv, "nerdctl")
+	if got := SelectEngine("", "podman"); got != "nerdctl" {
+		t.Fatalf("env engine: got %q", got)
+	}
+	if got := SelectEngine("docker-api", "podman"); got != "docker-api" {
+		t.Fatalf("flag engine: got %q", got)
+	}
+	if _, err := NewBackend("lxc", &fakeRunner{}); err == nil {
+		t.Fatal("expected unknown engine to fail")
+	}
+}
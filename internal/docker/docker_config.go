@@ -0,0 +1,54 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// dockerConfig is the part of the docker CLI's config.json that Engine
+// reads: registry credentials and the current context.
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth          string `json:"auth"`
+		IdentityToken string `json:"identitytoken"`
+	} `json:"auths"`
+	CredsStore     string            `json:"credsStore"`
+	CredHelpers    map[string]string `json:"credHelpers"`
+	CurrentContext string            `json:"currentContext"`
+}
+
+// dockerConfigDir returns the docker CLI's config dir: DOCKER_CONFIG, or
+// ~/.docker.
+func dockerConfigDir() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".docker")
+}
+
+// loadDockerConfig reads config.json. A missing file is an empty config.
+func loadDockerConfig() (dockerConfig, error) {
+	var cfg dockerConfig
+	dir := dockerConfigDir()
+	if dir == "" {
+		return cfg, nil
+	}
+	path := filepath.Join(dir, "config.json")
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,128 @@
+package docker
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultEngineHost is the daemon socket used without DOCKER_HOST or a
+// docker context.
+const defaultEngineHost = "unix:///var/run/docker.sock"
+
+// Endpoint is a daemon address and, when the daemon is reached over TLS,
+// the TLS settings to reach it with.
+type Endpoint struct {
+	Host string
+	TLS  *tls.Config
+}
+
+// EngineEndpoint returns the daemon the docker CLI would talk to:
+// DOCKER_HOST with the TLS settings of DOCKER_TLS_VERIFY and
+// DOCKER_CERT_PATH, else the endpoint of the docker context named by
+// DOCKER_CONTEXT or selected with `docker context use`, else the default
+// socket.
+func EngineEndpoint() (Endpoint, error) {
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		ep := Endpoint{Host: host}
+		verify := os.Getenv("DOCKER_TLS_VERIFY") != ""
+		if strings.HasPrefix(host, "tcp://") && (verify || os.Getenv("DOCKER_TLS") != "") {
+			dir := os.Getenv("DOCKER_CERT_PATH")
+			if dir == "" {
+				dir = dockerConfigDir()
+			}
+			cfg, err := loadTLSConfig(dir, verify)
+			if err != nil {
+				return Endpoint{}, fmt.Errorf("DOCKER_CERT_PATH: %w", err)
+			}
+			ep.TLS = cfg
+		}
+		return ep, nil
+	}
+	name := os.Getenv("DOCKER_CONTEXT")
+	if name == "" {
+		cfg, err := loadDockerConfig()
+		if err != nil {
+			return Endpoint{}, err
+		}
+		name = cfg.CurrentContext
+	}
+	if name == "" || name == "default" {
+		return Endpoint{Host: defaultEngineHost}, nil
+	}
+	ep, err := contextEndpoint(name)
+	if err != nil {
+		return Endpoint{}, fmt.Errorf("docker context %q: %w", name, err)
+	}
+	return ep, nil
+}
+
+// contextEndpoint reads a docker context from the CLI's context store,
+// where it is kept under the SHA-256 of its name.
+func contextEndpoint(name string) (Endpoint, error) {
+	sum := sha256.Sum256([]byte(name))
+	id := hex.EncodeToString(sum[:])
+	dir := dockerConfigDir()
+	b, err := os.ReadFile(filepath.Join(dir, "contexts", "meta", id, "meta.json"))
+	if os.IsNotExist(err) {
+		return Endpoint{}, errors.New("not found; list contexts with `docker context ls`")
+	}
+	if err != nil {
+		return Endpoint{}, err
+	}
+	var meta struct {
+		Endpoints struct {
+			Docker struct {
+				Host          string `json:"Host"`
+				SkipTLSVerify bool   `json:"SkipTLSVerify"`
+			} `json:"docker"`
+		} `json:"Endpoints"`
+	}
+	if err := json.Unmarshal(b, &meta); err != nil {
+		return Endpoint{}, fmt.Errorf("parse context metadata: %w", err)
+	}
+	ep := Endpoint{Host: meta.Endpoints.Docker.Host}
+	if ep.Host == "" {
+		ep.Host = defaultEngineHost
+	}
+	tlsDir := filepath.Join(dir, "contexts", "tls", id, "docker")
+	if _, err := os.Stat(tlsDir); err == nil && strings.HasPrefix(ep.Host, "tcp://") {
+		if ep.TLS, err = loadTLSConfig(tlsDir, !meta.Endpoints.Docker.SkipTLSVerify); err != nil {
+			return Endpoint{}, err
+		}
+	}
+	return ep, nil
+}
+
+// loadTLSConfig builds the TLS settings for a daemon from the ca.pem,
+// cert.pem and key.pem in dir, those that exist. Without verify the
+// daemon's certificate isn't checked.
+func loadTLSConfig(dir string, verify bool) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: !verify}
+	ca, err := os.ReadFile(filepath.Join(dir, "ca.pem"))
+	switch {
+	case err == nil:
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates in %s", filepath.Join(dir, "ca.pem"))
+		}
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if _, err := os.Stat(certFile); err == nil {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
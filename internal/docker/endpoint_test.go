@@ -0,0 +1,93 @@
+package docker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFile writes content to path, creating its directory.
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEngineEndpointFromEnv(t *testing.T) {
+	srv := httptest.NewTLSServer(newFakeEngine())
+	t.Cleanup(srv.Close)
+	certs := t.TempDir()
+	writeFile(t, filepath.Join(certs, "ca.pem"), string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})))
+
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+	t.Setenv("DOCKER_HOST", "tcp://"+srv.Listener.Addr().String())
+	t.Setenv("DOCKER_TLS_VERIFY", "1")
+	t.Setenv("DOCKER_CERT_PATH", certs)
+	ep, err := EngineEndpoint()
+	if err != nil {
+		t.Fatalf("EngineEndpoint failed: %v", err)
+	}
+	if ep.TLS == nil || ep.TLS.InsecureSkipVerify {
+		t.Fatalf("expected verified TLS, got %+v", ep.TLS)
+	}
+	e, err := NewEngine(ep)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	// The fake answers 404 for unknown containers, which takes a TLS
+	// handshake against the test CA to get.
+	if _, err := e.Inspect(context.Background(), "missing"); !isNotFound(err) {
+		t.Fatalf("expected a not found error over TLS, got %v", err)
+	}
+
+	t.Setenv("DOCKER_HOST", "ssh://dev@build-host")
+	ep, err = EngineEndpoint()
+	if err != nil {
+		t.Fatalf("EngineEndpoint failed: %v", err)
+	}
+	if _, err := NewEngine(ep); err == nil || !strings.Contains(err.Error(), "--engine docker") {
+		t.Fatalf("expected ssh hosts to be refused with a hint, got %v", err)
+	}
+}
+
+func TestEngineEndpointFromContext(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dir)
+	t.Setenv("DOCKER_HOST", "")
+	t.Setenv("DOCKER_CONTEXT", "")
+
+	if ep, err := EngineEndpoint(); err != nil || ep.Host != defaultEngineHost {
+		t.Fatalf("expected the default socket, got %+v, %v", ep, err)
+	}
+
+	sum := sha256.Sum256([]byte("remote"))
+	id := hex.EncodeToString(sum[:])
+	writeFile(t, filepath.Join(dir, "config.json"), `{"currentContext":"remote"}`)
+	writeFile(t, filepath.Join(dir, "contexts", "meta", id, "meta.json"),
+		`{"Name":"remote","Endpoints":{"docker":{"Host":"tcp://10.0.0.5:2376","SkipTLSVerify":true}}}`)
+	if err := os.MkdirAll(filepath.Join(dir, "contexts", "tls", id, "docker"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	ep, err := EngineEndpoint()
+	if err != nil {
+		t.Fatalf("EngineEndpoint failed: %v", err)
+	}
+	if ep.Host != "tcp://10.0.0.5:2376" || ep.TLS == nil || !ep.TLS.InsecureSkipVerify {
+		t.Fatalf("unexpected endpoint %+v", ep)
+	}
+
+	t.Setenv("DOCKER_CONTEXT", "missing")
+	if _, err := EngineEndpoint(); err == nil || !strings.Contains(err.Error(), `docker context "missing"`) {
+		t.Fatalf("expected a missing context to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,873 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openhoo/vibecontainer/internal/domain"
+	"github.com/openhoo/vibecontainer/internal/stack"
+)
+
+// engineAPIVersion is the oldest Docker Engine API version with every
+// endpoint and field Engine uses (Docker 20.10).
+const engineAPIVersion = "v1.41"
+
+// Labels docker compose puts on the objects it creates. Engine sets them
+// too so either backend, and plain `docker compose`, can manage a stack.
+const (
+	composeServiceLabel = "com.docker.compose.service"
+	composeNumberLabel  = "com.docker.compose.container-number"
+	composeOneoffLabel  = "com.docker.compose.oneoff"
+	composeHashLabel    = "com.docker.compose.config-hash"
+	composeNetworkLabel = "com.docker.compose.network"
+)
+
+// Engine runs stacks through the Docker Engine API. It reads the same
+// compose files as Compose and creates the containers and network docker
+// compose would, labelled the same way.
+type Engine struct {
+	client *http.Client
+	base   string
+	// dial opens a connection to the daemon, for requests that take it
+	// over, such as starting an exec session.
+	dial func(ctx context.Context) (net.Conn, error)
+}
+
+// NewEngine connects to the daemon at ep, a unix:// or tcp:// address.
+func NewEngine(ep Endpoint) (*Engine, error) {
+	var dial func(ctx context.Context) (net.Conn, error)
+	base := ""
+	switch scheme, addr, _ := strings.Cut(ep.Host, "://"); scheme {
+	case "unix":
+		dial = func(ctx context.Context) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", addr)
+		}
+		base = "http://docker"
+	case "tcp":
+		dial = func(ctx context.Context) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "tcp", addr)
+		}
+		base = "http://" + addr
+		if ep.TLS != nil {
+			dial = func(ctx context.Context) (net.Conn, error) {
+				d := tls.Dialer{Config: ep.TLS}
+				return d.DialContext(ctx, "tcp", addr)
+			}
+			base = "https://" + addr
+		}
+	case "ssh":
+		return nil, fmt.Errorf("docker host %q is reached over ssh, which --engine docker-api doesn't support; use --engine docker", ep.Host)
+	default:
+		return nil, fmt.Errorf("unsupported docker host %q (want unix:// or tcp://)", ep.Host)
+	}
+	transport := &http.Transport{}
+	if ep.TLS != nil {
+		transport.DialTLSContext = func(ctx context.Context, _, _ string) (net.Conn, error) { return dial(ctx) }
+	} else {
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) { return dial(ctx) }
+	}
+	return &Engine{client: &http.Client{Transport: transport}, base: base + "/" + engineAPIVersion, dial: dial}, nil
+}
+
+// apiError is an error response from the engine.
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("docker engine: %s (HTTP %d)", e.Message, e.Status)
+}
+
+func isNotFound(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound
+}
+
+// do sends a request and returns the response for status codes below 400.
+// The caller closes the body.
+func (e *Engine) do(ctx context.Context, method, path string, query url.Values, body any) (*http.Response, error) {
+	return e.doHeader(ctx, method, path, query, body, nil)
+}
+
+// doHeader is do with extra request headers.
+func (e *Engine) doHeader(ctx context.Context, method, path string, query url.Values, body any, header http.Header) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(b)
+	}
+	u := e.base + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("docker engine: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+	return resp, nil
+}
+
+// responseError reads the message of an error response.
+func responseError(resp *http.Response) error {
+	var msg struct {
+		Message string `json:"message"`
+	}
+	b, _ := io.ReadAll(resp.Body)
+	if json.Unmarshal(b, &msg) != nil || msg.Message == "" {
+		msg.Message = strings.TrimSpace(string(b))
+	}
+	return &apiError{Status: resp.StatusCode, Message: msg.Message}
+}
+
+// call sends a request and decodes a JSON response into out, if non-nil.
+func (e *Engine) call(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	resp, err := e.do(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("docker engine: decode %s response: %w", path, err)
+	}
+	return nil
+}
+
+func labelFilter(labels ...string) url.Values {
+	b, _ := json.Marshal(map[string][]string{"label": labels})
+	return url.Values{"filters": {string(b)}}
+}
+
+// containerSummary is an entry of GET /containers/json.
+type containerSummary struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	Image  string            `json:"Image"`
+	State  string            `json:"State"`
+	Status string            `json:"Status"`
+	Labels map[string]string `json:"Labels"`
+	Ports  []struct {
+		IP          string `json:"IP"`
+		PrivatePort int    `json:"PrivatePort"`
+		PublicPort  int    `json:"PublicPort"`
+		Type        string `json:"Type"`
+	} `json:"Ports"`
+}
+
+func (c containerSummary) name() string {
+	if len(c.Names) == 0 {
+		return c.ID
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+func (e *Engine) listContainers(ctx context.Context, labels ...string) ([]containerSummary, error) {
+	query := labelFilter(labels...)
+	query.Set("all", "1")
+	var out []containerSummary
+	if err := e.call(ctx, http.MethodGet, "/containers/json", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// projectContainers returns the containers of a stack, ordered so that
+// services start after their dependencies when the compose file is
+// readable.
+func (e *Engine) projectContainers(ctx context.Context, project string) ([]containerSummary, error) {
+	containers, err := e.listContainers(ctx, composeProjectLabel+"="+project)
+	if err != nil {
+		return nil, err
+	}
+	rank := map[string]int{}
+	if p, err := stack.LoadProject(project); err == nil {
+		for i, svc := range p.Services {
+			rank[svc.Name] = i + 1
+		}
+	}
+	sort.SliceStable(containers, func(i, j int) bool {
+		ri, rj := rank[containers[i].Labels[composeServiceLabel]], rank[containers[j].Labels[composeServiceLabel]]
+		if ri != rj {
+			return ri < rj
+		}
+		return containers[i].name() < containers[j].name()
+	})
+	return containers, nil
+}
+
+// Up creates or updates the stack's network and containers so they match
+// its compose file, like `docker compose up -d --remove-orphans`.
+// Containers whose configuration is unchanged are only started. Named
+// services limit it to those, plus services sharing the network of one it
+// recreates, and leave orphans alone.
+func (e *Engine) Up(ctx context.Context, project string, services ...string) error {
+	p, err := stack.LoadProject(project)
+	if err != nil {
+		return err
+	}
+	network, err := e.ensureNetwork(ctx, project)
+	if err != nil {
+		return err
+	}
+	existing, err := e.listContainers(ctx, composeProjectLabel+"="+project)
+	if err != nil {
+		return err
+	}
+	byService := map[string]containerSummary{}
+	for _, c := range existing {
+		byService[c.Labels[composeServiceLabel]] = c
+	}
+
+	ids := map[string]string{}
+	recreated := map[string]bool{}
+	for _, svc := range p.Services {
+		hash, err := configHash(svc)
+		if err != nil {
+			return err
+		}
+		parent, sharesNetwork := strings.CutPrefix(svc.NetworkMode, "service:")
+		current, ok := byService[svc.Name]
+		delete(byService, svc.Name)
+		if len(services) > 0 && !slices.Contains(services, svc.Name) && !(sharesNetwork && recreated[parent]) {
+			if ok {
+				ids[svc.Name] = current.ID
+			}
+			continue
+		}
+		if ok && current.Labels[composeHashLabel] == hash && !(sharesNetwork && recreated[parent]) {
+			ids[svc.Name] = current.ID
+			if current.State != "running" {
+				if err := e.call(ctx, http.MethodPost, "/containers/"+current.ID+"/start", nil, nil, nil); err != nil {
+					return fmt.Errorf("start %s: %w", current.name(), err)
+				}
+			}
+			continue
+		}
+		if ok {
+			if err := e.removeContainer(ctx, current.ID); err != nil {
+				return fmt.Errorf("recreate %s: %w", current.name(), err)
+			}
+		}
+		if err := e.ensureImage(ctx, svc.Image); err != nil {
+			return err
+		}
+		body, err := createBody(project, network, svc, hash, ids)
+		if err != nil {
+			return err
+		}
+		name := svc.Container
+		if name == "" {
+			name = fmt.Sprintf("%s-%s-1", project, svc.Name)
+		}
+		var created struct {
+			ID string `json:"Id"`
+		}
+		if err := e.call(ctx, http.MethodPost, "/containers/create", url.Values{"name": {name}}, body, &created); err != nil {
+			return fmt.Errorf("create %s: %w", name, err)
+		}
+		if err := e.call(ctx, http.MethodPost, "/containers/"+created.ID+"/start", nil, nil, nil); err != nil {
+			return fmt.Errorf("start %s: %w", name, err)
+		}
+		ids[svc.Name] = created.ID
+		recreated[svc.Name] = true
+	}
+
+	if len(services) > 0 {
+		return nil
+	}
+	// Whatever is left belongs to services no longer in the compose file.
+	for _, orphan := range byService {
+		if err := e.removeContainer(ctx, orphan.ID); err != nil {
+			return fmt.Errorf("remove orphan %s: %w", orphan.name(), err)
+		}
+	}
+	return nil
+}
+
+// configHash fingerprints a service's configuration so Up can tell whether
+// its container needs to be recreated.
+func configHash(svc stack.ProjectService) (string, error) {
+	b, err := json.Marshal(svc.Service)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+type createRequest struct {
+	Image            string              `json:"Image"`
+	Env              []string            `json:"Env,omitempty"`
+	Cmd              []string            `json:"Cmd,omitempty"`
+	WorkingDir       string              `json:"WorkingDir,omitempty"`
+	Labels           map[string]string   `json:"Labels"`
+	ExposedPorts     map[string]struct{} `json:"ExposedPorts,omitempty"`
+	Healthcheck      *healthConfig       `json:"Healthcheck,omitempty"`
+	HostConfig       hostConfig          `json:"HostConfig"`
+	NetworkingConfig *networkingConfig   `json:"NetworkingConfig,omitempty"`
+}
+
+// healthConfig is a healthcheck as the engine takes it, with durations in
+// nanoseconds.
+type healthConfig struct {
+	Test        []string      `json:"Test"`
+	Interval    time.Duration `json:"Interval,omitempty"`
+	Timeout     time.Duration `json:"Timeout,omitempty"`
+	Retries     int           `json:"Retries,omitempty"`
+	StartPeriod time.Duration `json:"StartPeriod,omitempty"`
+}
+
+func newHealthConfig(hc *stack.Healthcheck) (*healthConfig, error) {
+	if hc == nil {
+		return nil, nil
+	}
+	out := &healthConfig{Test: hc.Test, Retries: hc.Retries}
+	for _, d := range []struct {
+		value string
+		dst   *time.Duration
+	}{{hc.Interval, &out.Interval}, {hc.Timeout, &out.Timeout}, {hc.StartPeriod, &out.StartPeriod}} {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil {
+			return nil, fmt.Errorf("healthcheck: %w", err)
+		}
+		*d.dst = v
+	}
+	return out, nil
+}
+
+type hostConfig struct {
+	Binds         []string                 `json:"Binds,omitempty"`
+	PortBindings  map[string][]hostBinding `json:"PortBindings,omitempty"`
+	CapAdd        []string                 `json:"CapAdd,omitempty"`
+	NetworkMode   string                   `json:"NetworkMode,omitempty"`
+	RestartPolicy struct {
+		Name string `json:"Name,omitempty"`
+	} `json:"RestartPolicy"`
+}
+
+type hostBinding struct {
+	HostIP   string `json:"HostIp"`
+	HostPort string `json:"HostPort"`
+}
+
+type networkingConfig struct {
+	EndpointsConfig map[string]endpointConfig `json:"EndpointsConfig"`
+}
+
+type endpointConfig struct {
+	Aliases []string `json:"Aliases,omitempty"`
+}
+
+// createBody translates a compose service into a container create request.
+// ids holds the containers of services started earlier, for network_mode
+// "service:<name>".
+func createBody(project, network string, svc stack.ProjectService, hash string, ids map[string]string) (createRequest, error) {
+	req := createRequest{
+		Image:      svc.Image,
+		Cmd:        strings.Fields(svc.Command),
+		WorkingDir: svc.WorkingDir,
+		Labels: map[string]string{
+			composeProjectLabel: project,
+			composeServiceLabel: svc.Name,
+			composeNumberLabel:  "1",
+			composeOneoffLabel:  "False",
+			composeHashLabel:    hash,
+		},
+	}
+	for k, v := range svc.Labels {
+		req.Labels[k] = v
+	}
+	for k, v := range svc.Environment {
+		req.Env = append(req.Env, k+"="+v)
+	}
+	sort.Strings(req.Env)
+	health, err := newHealthConfig(svc.Healthcheck)
+	if err != nil {
+		return createRequest{}, fmt.Errorf("service %s: %w", svc.Name, err)
+	}
+	req.Healthcheck = health
+	req.HostConfig.Binds = svc.Volumes
+	req.HostConfig.CapAdd = svc.CapAdd
+	req.HostConfig.RestartPolicy.Name = svc.Restart
+	for _, p := range svc.Ports {
+		ip, host, containerPort, err := parsePortSpec(p)
+		if err != nil {
+			return createRequest{}, fmt.Errorf("service %s: %w", svc.Name, err)
+		}
+		if req.ExposedPorts == nil {
+			req.ExposedPorts = map[string]struct{}{}
+			req.HostConfig.PortBindings = map[string][]hostBinding{}
+		}
+		req.ExposedPorts[containerPort] = struct{}{}
+		req.HostConfig.PortBindings[containerPort] = append(req.HostConfig.PortBindings[containerPort], hostBinding{HostIP: ip, HostPort: host})
+	}
+	if parent, ok := strings.CutPrefix(svc.NetworkMode, "service:"); ok {
+		id, ok := ids[parent]
+		if !ok {
+			return createRequest{}, fmt.Errorf("service %s shares the network of %s, which is not running", svc.Name, parent)
+		}
+		req.HostConfig.NetworkMode = "container:" + id
+		return req, nil
+	}
+	req.HostConfig.NetworkMode = network
+	req.NetworkingConfig = &networkingConfig{EndpointsConfig: map[string]endpointConfig{
+		network: {Aliases: []string{svc.Name}},
+	}}
+	return req, nil
+}
+
+// parsePortSpec splits a compose port mapping "[ip:]host:container[/proto]"
+// into its host side and the engine's "port/proto" key. The ip may be an
+// IPv6 address in brackets, so the ports are taken from the right.
+func parsePortSpec(spec string) (ip, host, containerPort string, err error) {
+	proto := "tcp"
+	if s, p, ok := strings.Cut(spec, "/"); ok {
+		spec, proto = s, p
+	}
+	rest, containerPort, ok := cutLast(spec, ":")
+	if !ok {
+		return "", "", "", fmt.Errorf("invalid port mapping %q", spec)
+	}
+	host = rest
+	if r, h, ok := cutLast(rest, ":"); ok {
+		ip, host = r, h
+		ip = strings.TrimSuffix(strings.TrimPrefix(ip, "["), "]")
+	}
+	if _, err := strconv.Atoi(containerPort); err != nil {
+		return "", "", "", fmt.Errorf("invalid port mapping %q", spec)
+	}
+	return ip, host, containerPort + "/" + proto, nil
+}
+
+// cutLast is strings.Cut at the last occurrence of sep.
+func cutLast(s, sep string) (before, after string, found bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
+
+// ensureNetwork creates the stack's default network if needed and returns
+// its name.
+func (e *Engine) ensureNetwork(ctx context.Context, project string) (string, error) {
+	name := project + "_default"
+	err := e.call(ctx, http.MethodGet, "/networks/"+url.PathEscape(name), nil, nil, nil)
+	if err == nil {
+		return name, nil
+	}
+	if !isNotFound(err) {
+		return "", err
+	}
+	body := map[string]any{
+		"Name":   name,
+		"Driver": "bridge",
+		"Labels": map[string]string{composeProjectLabel: project, composeNetworkLabel: "default"},
+	}
+	if err := e.call(ctx, http.MethodPost, "/networks/create", nil, body, nil); err != nil {
+		return "", fmt.Errorf("create network %s: %w", name, err)
+	}
+	return name, nil
+}
+
+// ensureImage pulls image unless it is already present.
+func (e *Engine) ensureImage(ctx context.Context, image string) error {
+	err := e.call(ctx, http.MethodGet, "/images/"+image+"/json", nil, nil, nil)
+	if err == nil || !isNotFound(err) {
+		return err
+	}
+	repo, tag := splitImageRef(image)
+	auth, err := registryAuthHeader(ctx, repo)
+	if err != nil {
+		return fmt.Errorf("pull %s: registry credentials: %w", image, err)
+	}
+	var header http.Header
+	if auth != "" {
+		header = http.Header{"X-Registry-Auth": {auth}}
+	}
+	resp, err := e.doHeader(ctx, http.MethodPost, "/images/create", url.Values{"fromImage": {repo}, "tag": {tag}}, nil, header)
+	if err != nil {
+		return fmt.Errorf("pull %s: %w", image, err)
+	}
+	defer resp.Body.Close()
+	// The pull reports progress, and failures, as a stream of JSON messages.
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Error string `json:"error"`
+		}
+		if err := dec.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("pull %s: %w", image, err)
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("pull %s: %s", image, msg.Error)
+		}
+	}
+}
+
+func (e *Engine) removeContainer(ctx context.Context, id string) error {
+	err := e.call(ctx, http.MethodDelete, "/containers/"+url.PathEscape(id), url.Values{"force": {"1"}}, nil, nil)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// Stop stops the stack's containers, dependents first.
+func (e *Engine) Stop(ctx context.Context, project string) error {
+	containers, err := e.projectContainers(ctx, project)
+	if err != nil {
+		return err
+	}
+	for i := len(containers) - 1; i >= 0; i-- {
+		c := containers[i]
+		if c.State != "running" {
+			continue
+		}
+		if err := e.call(ctx, http.MethodPost, "/containers/"+c.ID+"/stop", nil, nil, nil); err != nil {
+			return fmt.Errorf("stop %s: %w", c.name(), err)
+		}
+	}
+	return nil
+}
+
+// Restart restarts the stack's containers, dependencies first.
+func (e *Engine) Restart(ctx context.Context, project string) error {
+	containers, err := e.projectContainers(ctx, project)
+	if err != nil {
+		return err
+	}
+	for _, c := range containers {
+		if err := e.call(ctx, http.MethodPost, "/containers/"+c.ID+"/restart", nil, nil, nil); err != nil {
+			return fmt.Errorf("restart %s: %w", c.name(), err)
+		}
+	}
+	return nil
+}
+
+// Down removes the stack's containers and networks.
+func (e *Engine) Down(ctx context.Context, project string) error {
+	containers, err := e.projectContainers(ctx, project)
+	if err != nil {
+		return err
+	}
+	for i := len(containers) - 1; i >= 0; i-- {
+		if err := e.removeContainer(ctx, containers[i].ID); err != nil {
+			return fmt.Errorf("remove %s: %w", containers[i].name(), err)
+		}
+	}
+	return e.removeNetworks(ctx, project)
+}
+
+func (e *Engine) removeNetworks(ctx context.Context, project string) error {
+	var networks []struct {
+		ID string `json:"Id"`
+	}
+	if err := e.call(ctx, http.MethodGet, "/networks", labelFilter(composeProjectLabel+"="+project), nil, &networks); err != nil {
+		return err
+	}
+	for _, n := range networks {
+		if err := e.call(ctx, http.MethodDelete, "/networks/"+n.ID, nil, nil, nil); err != nil && !isNotFound(err) {
+			return fmt.Errorf("remove network: %w", err)
+		}
+	}
+	return nil
+}
+
+// Logs streams container logs. Lines are prefixed with the service name,
+// or with the container name when opts.Prefix is off, as docker compose
+// does.
+func (e *Engine) Logs(ctx context.Context, project string, opts LogsOptions, stdout, stderr io.Writer) error {
+	containers, err := e.projectContainers(ctx, project)
+	if err != nil {
+		return err
+	}
+	if len(opts.Services) > 0 {
+		want := map[string]bool{}
+		for _, s := range opts.Services {
+			want[s] = true
+		}
+		var filtered []containerSummary
+		for _, c := range containers {
+			if want[c.Labels[composeServiceLabel]] {
+				filtered = append(filtered, c)
+			}
+		}
+		containers = filtered
+	}
+	if len(containers) == 0 {
+		return nil
+	}
+
+	query := url.Values{"stdout": {"1"}, "stderr": {"1"}}
+	if opts.Follow {
+		query.Set("follow", "1")
+	}
+	if opts.Tail != "" {
+		query.Set("tail", opts.Tail)
+	}
+	if opts.Since != "" {
+		since, err := logsSince(opts.Since, time.Now())
+		if err != nil {
+			return err
+		}
+		query.Set("since", since)
+	}
+	if opts.Timestamps {
+		query.Set("timestamps", "1")
+	}
+
+	label := func(c containerSummary) string {
+		if opts.Prefix {
+			return c.Labels[composeServiceLabel]
+		}
+		return c.name()
+	}
+	width := 0
+	for _, c := range containers {
+		width = max(width, len(label(c)))
+	}
+	var mu sync.Mutex
+	errs := make(chan error, len(containers))
+	for _, c := range containers {
+		go func(c containerSummary) {
+			out := newPrefixWriter(stdout, &mu, label(c), width)
+			defer out.Flush()
+			resp, err := e.do(ctx, http.MethodGet, "/containers/"+c.ID+"/logs", query, nil)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer resp.Body.Close()
+			// Container stderr is shown with stdout, like compose logs.
+			errs <- demuxLogs(resp.Body, out, out)
+		}(c)
+	}
+	var firstErr error
+	for range containers {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if firstErr != nil {
+		return fmt.Errorf("logs failed: %w", firstErr)
+	}
+	return nil
+}
+
+// logsSince converts a --since value to what the engine accepts: a
+// duration like "10m" becomes a Unix timestamp, RFC 3339 times are
+// converted, anything else is passed through.
+func logsSince(since string, now time.Time) (string, error) {
+	if d, err := time.ParseDuration(since); err == nil {
+		return strconv.FormatInt(now.Add(-d).Unix(), 10), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, since); err == nil {
+		return strconv.FormatInt(t.Unix(), 10), nil
+	}
+	if _, err := strconv.ParseFloat(since, 64); err == nil {
+		return since, nil
+	}
+	return "", fmt.Errorf("invalid --since value %q", since)
+}
+
+// demuxLogs splits the engine's multiplexed log stream: each frame is an
+// 8-byte header holding the stream (1 stdout, 2 stderr) and a big-endian
+// payload length.
+func demuxLogs(r io.Reader, stdout, stderr io.Writer) error {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		size := int64(header[4])<<24 | int64(header[5])<<16 | int64(header[6])<<8 | int64(header[7])
+		dst := stdout
+		if header[0] == 2 {
+			dst = stderr
+		}
+		if _, err := io.CopyN(dst, r, size); err != nil {
+			return err
+		}
+	}
+}
+
+func (e *Engine) Status(ctx context.Context, project string) ([]domain.ServiceStatus, error) {
+	containers, err := e.projectContainers(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]domain.ServiceStatus, 0, len(containers))
+	for _, c := range containers {
+		s := domain.ServiceStatus{
+			Name:    c.name(),
+			Service: c.Labels[composeServiceLabel],
+			Image:   c.Image,
+			State:   c.State,
+			Health:  healthFromStatus(c.Status),
+			Project: project,
+		}
+		for _, p := range c.Ports {
+			s.Publishers = append(s.Publishers, domain.Publisher{URL: p.IP, TargetPort: p.PrivatePort, PublishedPort: p.PublicPort, Protocol: p.Type})
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+func (e *Engine) ListManagedContainers(ctx context.Context) ([]ManagedContainer, error) {
+	containers, err := e.listContainers(ctx, managedLabel)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ManagedContainer, 0, len(containers))
+	for _, c := range containers {
+		out = append(out, ManagedContainer{
+			Name:    c.name(),
+			Service: c.Labels[serviceLabel],
+			State:   c.State,
+			Health:  healthFromStatus(c.Status),
+			Labels:  c.Labels,
+		})
+	}
+	return out, nil
+}
+
+// RemoveStackContainers force-removes the given containers of a stack that
+// has no compose file any more, along with its networks. Networks are
+// removed best effort.
+func (e *Engine) RemoveStackContainers(ctx context.Context, project string, containers []string) error {
+	for _, c := range containers {
+		if err := e.removeContainer(ctx, c); err != nil {
+			return fmt.Errorf("remove %s: %w", c, err)
+		}
+	}
+	_ = e.removeNetworks(ctx, project)
+	return nil
+}
+
+func (e *Engine) ContainerFor(ctx context.Context, project, service string) (string, error) {
+	managed, err := e.ListManagedContainers(ctx)
+	if err != nil {
+		return "", err
+	}
+	return runningContainer(managed, project, service)
+}
+
+func (e *Engine) Inspect(ctx context.Context, container string) (domain.ContainerInfo, error) {
+	var out inspectOutput
+	if err := e.call(ctx, http.MethodGet, "/containers/"+url.PathEscape(container)+"/json", nil, nil, &out); err != nil {
+		return domain.ContainerInfo{}, err
+	}
+	return out.info(), nil
+}
+
+func (e *Engine) ImageEnv(ctx context.Context, image string) (map[string]string, error) {
+	var out struct {
+		Config struct {
+			Env []string `json:"Env"`
+		} `json:"Config"`
+	}
+	if err := e.call(ctx, http.MethodGet, "/images/"+image+"/json", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return envMap(out.Config.Env), nil
+}
+
+// RemoveContainer force-removes a single container. One that is already
+// gone is not an error.
+func (e *Engine) RemoveContainer(ctx context.Context, container string) error {
+	return e.removeContainer(ctx, container)
+}
+
+// StopContainer stops a single container.
+func (e *Engine) StopContainer(ctx context.Context, container string) error {
+	return e.call(ctx, http.MethodPost, "/containers/"+url.PathEscape(container)+"/stop", nil, nil, nil)
+}
+
+// StartContainer starts a single container.
+func (e *Engine) StartContainer(ctx context.Context, container string) error {
+	return e.call(ctx, http.MethodPost, "/containers/"+url.PathEscape(container)+"/start", nil, nil, nil)
+}
+
+// Events streams the engine's /events endpoint.
+func (e *Engine) Events(ctx context.Context, fn func(Event)) error {
+	b, _ := json.Marshal(map[string][]string{"label": {managedLabel}, "type": {"container"}})
+	resp, err := e.do(ctx, http.MethodGet, "/events", url.Values{"filters": {string(b)}}, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg eventMessage
+		if err := dec.Decode(&msg); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("docker engine: read events: %w", err)
+		}
+		fn(msg.event())
+	}
+}
+
+// Capabilities reads /info, which has the same shape as `docker info`.
+func (e *Engine) Capabilities(ctx context.Context) (Capabilities, error) {
+	var info engineInfo
+	if err := e.call(ctx, http.MethodGet, "/info", nil, nil, &info); err != nil {
+		return Capabilities{}, err
+	}
+	return info.capabilities(), nil
+}
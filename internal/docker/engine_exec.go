@@ -0,0 +1,217 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/muesli/cancelreader"
+)
+
+// execConfig is the body of /containers/{id}/exec.
+type execConfig struct {
+	User         string   `json:"User,omitempty"`
+	WorkingDir   string   `json:"WorkingDir,omitempty"`
+	Env          []string `json:"Env,omitempty"`
+	Cmd          []string `json:"Cmd"`
+	Tty          bool     `json:"Tty"`
+	AttachStdin  bool     `json:"AttachStdin"`
+	AttachStdout bool     `json:"AttachStdout"`
+	AttachStderr bool     `json:"AttachStderr"`
+}
+
+// Attach connects the caller's terminal to the tmux session inside
+// container through an exec session.
+func (e *Engine) Attach(ctx context.Context, container string, opts AttachOptions) error {
+	cfg := execConfig{User: "dev", Cmd: attachCommand(opts.ReadOnly), Tty: true}
+	code, err := e.exec(ctx, container, cfg, opts.Stdin, opts.Stdout, opts.Stderr)
+	if err == nil && code > 0 {
+		err = &ExitError{Code: code}
+	}
+	if err != nil {
+		return fmt.Errorf("attach failed: %w", err)
+	}
+	return nil
+}
+
+// Exec runs a command inside container. A non-zero exit status from the
+// command is returned as *ExitError.
+func (e *Engine) Exec(ctx context.Context, container string, opts ExecOptions) error {
+	cfg := execConfig{User: opts.User, WorkingDir: opts.Workdir, Env: opts.Env, Cmd: opts.Command, Tty: opts.TTY}
+	code, err := e.exec(ctx, container, cfg, opts.Stdin, opts.Stdout, opts.Stderr)
+	if err != nil {
+		return fmt.Errorf("exec failed: %w", err)
+	}
+	if code > 0 {
+		return &ExitError{Code: code}
+	}
+	return nil
+}
+
+// exec runs cfg in container, copying stdin to it and its output to stdout
+// and stderr, and returns its exit status. With a TTY the caller's terminal
+// is put in raw mode and its size is kept in sync with the session's.
+func (e *Engine) exec(ctx context.Context, container string, cfg execConfig, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	cfg.AttachStdin = stdin != nil
+	cfg.AttachStdout, cfg.AttachStderr = true, true
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := e.call(ctx, http.MethodPost, "/containers/"+url.PathEscape(container)+"/exec", nil, cfg, &created); err != nil {
+		return 0, err
+	}
+	conn, out, err := e.hijack(ctx, "/exec/"+created.ID+"/start", map[string]bool{"Detach": false, "Tty": cfg.Tty})
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	// Closing the connection ends the copies below when ctx is canceled.
+	defer context.AfterFunc(ctx, func() { conn.Close() })()
+
+	if cfg.Tty {
+		if f, ok := stdin.(*os.File); ok && term.IsTerminal(f.Fd()) {
+			state, err := term.MakeRaw(f.Fd())
+			if err != nil {
+				return 0, fmt.Errorf("set terminal to raw mode: %w", err)
+			}
+			defer term.Restore(f.Fd(), state)
+		}
+		defer e.forwardResize(ctx, created.ID, stdout)()
+	}
+	if stdin != nil {
+		defer copyStdin(conn, stdin)()
+	}
+
+	if cfg.Tty {
+		_, err = io.Copy(stdout, out)
+	} else {
+		err = demuxLogs(out, stdout, stderr)
+	}
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+	if err != nil {
+		return 0, err
+	}
+	var inspect struct {
+		ExitCode int `json:"ExitCode"`
+	}
+	if err := e.call(ctx, http.MethodGet, "/exec/"+created.ID+"/json", nil, nil, &inspect); err != nil {
+		return 0, err
+	}
+	return inspect.ExitCode, nil
+}
+
+// hijack sends a request that turns the connection into a raw stream, as
+// /exec/{id}/start does, and returns the connection and a reader of what
+// the engine sends on it.
+func (e *Engine) hijack(ctx context.Context, path string, body any) (net.Conn, *bufio.Reader, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.base+path, bytes.NewReader(b))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+	conn, err := e.dial(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("docker engine: %w", err)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("docker engine: %w", err)
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("docker engine: %w", err)
+	}
+	// Engines that don't upgrade answer 200 and stream on the same
+	// connection.
+	if resp.StatusCode >= 400 {
+		err := responseError(resp)
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, r, nil
+}
+
+// copyStdin copies stdin to the session until stdin ends, then closes the
+// session's input. The returned func stops the copy; a terminal read is
+// canceled so it doesn't take input meant for whatever runs next.
+func copyStdin(conn net.Conn, stdin io.Reader) (stop func()) {
+	src := stdin
+	in, err := cancelreader.NewReader(stdin)
+	if err == nil {
+		src = in
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := io.Copy(conn, src); err == nil {
+			if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+				_ = cw.CloseWrite()
+			}
+		}
+	}()
+	return func() {
+		if in == nil {
+			return
+		}
+		conn.Close()
+		if in.Cancel() {
+			<-done
+		}
+		in.Close()
+	}
+}
+
+// forwardResize sizes the exec session like stdout's terminal and keeps it
+// in sync until the returned func is called.
+func (e *Engine) forwardResize(ctx context.Context, id string, stdout io.Writer) (stop func()) {
+	f, ok := stdout.(*os.File)
+	if !ok || !term.IsTerminal(f.Fd()) {
+		return func() {}
+	}
+	resize := func() {
+		w, h, err := term.GetSize(f.Fd())
+		if err != nil {
+			return
+		}
+		query := url.Values{"w": {strconv.Itoa(w)}, "h": {strconv.Itoa(h)}}
+		_ = e.call(ctx, http.MethodPost, "/exec/"+id+"/resize", query, nil, nil)
+	}
+	resize()
+	sigs := make(chan os.Signal, 1)
+	notifyResize(sigs)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-sigs:
+				resize()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+}
@@ -0,0 +1,561 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adrg/xdg"
+	"github.com/openhoo/vibecontainer/internal/domain"
+	"github.com/openhoo/vibecontainer/internal/stack"
+)
+
+type fakeContainer struct {
+	ID     string
+	Name   string
+	State  string
+	Create createRequest
+	Logs   []string
+}
+
+// fakeEngine implements the parts of the Docker Engine API that Engine
+// uses, keeping containers, networks and images in memory.
+type fakeEngine struct {
+	mu         sync.Mutex
+	nextID     int
+	containers []*fakeContainer
+	networks   map[string]map[string]string
+	images     map[string]bool
+	created    []string
+	execs      map[string]*fakeExec
+	// pullAuth is the X-Registry-Auth header of the last pull.
+	pullAuth string
+}
+
+// fakeExec is an exec session. Started, it reads stdin to the end, then
+// echoes it on stdout and its command on stderr.
+type fakeExec struct {
+	Config   execConfig
+	ExitCode int
+	Resized  bool
+}
+
+func newFakeEngine() *fakeEngine {
+	return &fakeEngine{networks: map[string]map[string]string{}, images: map[string]bool{}, execs: map[string]*fakeExec{}}
+}
+
+func (f *fakeEngine) find(ref string) *fakeContainer {
+	for _, c := range f.containers {
+		if c.ID == ref || c.Name == ref {
+			return c
+		}
+	}
+	return nil
+}
+
+func matchesLabels(labels map[string]string, r *http.Request) bool {
+	var filters map[string][]string
+	_ = json.Unmarshal([]byte(r.URL.Query().Get("filters")), &filters)
+	for _, l := range filters["label"] {
+		k, v, hasValue := strings.Cut(l, "=")
+		got, ok := labels[k]
+		if !ok || hasValue && got != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *fakeEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/"+engineAPIVersion)
+	if id, ok := strings.CutSuffix(strings.TrimPrefix(path, "/exec/"), "/start"); ok && strings.HasPrefix(path, "/exec/") {
+		f.startExec(w, r, id)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	notFound := func(what string) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"message":"No such %s"}`, what)
+	}
+	writeJSON := func(v any) { _ = json.NewEncoder(w).Encode(v) }
+
+	switch {
+	case r.Method == http.MethodGet && path == "/containers/json":
+		out := []map[string]any{}
+		for _, c := range f.containers {
+			if matchesLabels(c.Create.Labels, r) {
+				out = append(out, map[string]any{
+					"Id": c.ID, "Names": []string{"/" + c.Name}, "Image": c.Create.Image,
+					"State": c.State, "Status": "Up 1 second (healthy)", "Labels": c.Create.Labels,
+				})
+			}
+		}
+		writeJSON(out)
+	case r.Method == http.MethodPost && path == "/containers/create":
+		var body createRequest
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		f.nextID++
+		c := &fakeContainer{ID: fmt.Sprintf("id%d", f.nextID), Name: r.URL.Query().Get("name"), State: "created", Create: body}
+		f.containers = append(f.containers, c)
+		f.created = append(f.created, c.Name)
+		writeJSON(map[string]string{"Id": c.ID})
+	case strings.HasPrefix(path, "/containers/"):
+		rest := strings.TrimPrefix(path, "/containers/")
+		ref, action, _ := strings.Cut(rest, "/")
+		c := f.find(ref)
+		if c == nil {
+			notFound("container")
+			return
+		}
+		switch action {
+		case "start", "restart":
+			c.State = "running"
+			w.WriteHeader(http.StatusNoContent)
+		case "stop":
+			c.State = "exited"
+			w.WriteHeader(http.StatusNoContent)
+		case "exec":
+			x := &fakeExec{}
+			_ = json.NewDecoder(r.Body).Decode(&x.Config)
+			if len(x.Config.Cmd) > 0 && x.Config.Cmd[0] == "false" {
+				x.ExitCode = 1
+			}
+			id := fmt.Sprintf("exec%d", len(f.execs)+1)
+			f.execs[id] = x
+			writeJSON(map[string]string{"Id": id})
+		case "json":
+			writeJSON(map[string]any{"Name": "/" + c.Name, "Config": map[string]any{"Image": c.Create.Image, "Env": c.Create.Env, "Labels": c.Create.Labels}})
+		case "logs":
+			for _, line := range c.Logs {
+				header := make([]byte, 8)
+				header[0] = 1
+				binary.BigEndian.PutUint32(header[4:], uint32(len(line)))
+				_, _ = w.Write(append(header, line...))
+			}
+		case "":
+			for i, x := range f.containers {
+				if x == c {
+					f.containers = append(f.containers[:i], f.containers[i+1:]...)
+					break
+				}
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	case r.Method == http.MethodGet && path == "/networks":
+		out := []map[string]string{}
+		for name, labels := range f.networks {
+			if matchesLabels(labels, r) {
+				out = append(out, map[string]string{"Id": name})
+			}
+		}
+		writeJSON(out)
+	case r.Method == http.MethodPost && path == "/networks/create":
+		var body struct {
+			Name   string
+			Labels map[string]string
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		f.networks[body.Name] = body.Labels
+		writeJSON(map[string]string{"Id": body.Name})
+	case strings.HasPrefix(path, "/networks/"):
+		name := strings.TrimPrefix(path, "/networks/")
+		if _, ok := f.networks[name]; !ok {
+			notFound("network")
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(f.networks, name)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSON(map[string]string{"Id": name})
+	case r.Method == http.MethodPost && path == "/images/create":
+		f.images[r.URL.Query().Get("fromImage")+":"+r.URL.Query().Get("tag")] = true
+		f.pullAuth = r.Header.Get("X-Registry-Auth")
+		fmt.Fprintln(w, `{"status":"Pulling"}`)
+		fmt.Fprintln(w, `{"status":"Done"}`)
+	case strings.HasPrefix(path, "/images/") && strings.HasSuffix(path, "/json"):
+		name := strings.TrimSuffix(strings.TrimPrefix(path, "/images/"), "/json")
+		if repo, tag := splitImageRef(name); !f.images[repo+":"+tag] {
+			notFound("image")
+			return
+		}
+		writeJSON(map[string]any{"Config": map[string]any{"Env": []string{"PATH=/usr/bin"}}})
+	case strings.HasPrefix(path, "/exec/"):
+		id, action, _ := strings.Cut(strings.TrimPrefix(path, "/exec/"), "/")
+		x := f.execs[id]
+		if x == nil {
+			notFound("exec instance")
+			return
+		}
+		if action == "resize" {
+			x.Resized = true
+			return
+		}
+		writeJSON(map[string]any{"ExitCode": x.ExitCode, "Running": false})
+	case path == "/events":
+		fmt.Fprintln(w, `{"Type":"container","Action":"start","Actor":{"ID":"id1","Attributes":{"name":"demo-vibecontainer","com.openhoo.vibecontainer.stack":"demo","com.openhoo.vibecontainer.service":"vibecontainer"}},"timeNano":1000}`)
+		fmt.Fprintln(w, `{"Type":"container","Action":"die","Actor":{"ID":"id1","Attributes":{"name":"demo-vibecontainer"}},"timeNano":2000}`)
+	default:
+		notFound("endpoint " + r.Method + " " + path)
+	}
+}
+
+// startExec takes over the connection like the engine does and runs the
+// exec session on it.
+func (f *fakeEngine) startExec(w http.ResponseWriter, r *http.Request, id string) {
+	_, _ = io.Copy(io.Discard, r.Body)
+	f.mu.Lock()
+	x := f.execs[id]
+	f.mu.Unlock()
+	if x == nil {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message":"No such exec instance"}`)
+		return
+	}
+	conn, rw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	fmt.Fprint(rw, "HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+	_ = rw.Flush()
+	var in []byte
+	if x.Config.AttachStdin {
+		in, _ = io.ReadAll(rw)
+	}
+	frame := func(stream byte, payload string) {
+		header := make([]byte, 8)
+		header[0] = stream
+		binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+		_, _ = rw.Write(append(header, payload...))
+	}
+	frame(1, string(in))
+	frame(2, strings.Join(x.Config.Cmd, " "))
+	_ = rw.Flush()
+}
+
+// startFakeEngine serves a fakeEngine on a unix socket and points XDG data
+// at a temp dir so stacks can be saved for it to run. Pulls see no saved
+// registry credentials.
+func startFakeEngine(t *testing.T) (*Engine, *fakeEngine) {
+	t.Helper()
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+	xdg.Reload()
+	t.Cleanup(xdg.Reload)
+
+	// Socket paths are limited to ~100 bytes, too short for t.TempDir().
+	dir, err := os.MkdirTemp("", "engine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	sock := filepath.Join(dir, "docker.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := newFakeEngine()
+	srv := httptest.NewUnstartedServer(fake)
+	srv.Listener = ln
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	e, err := NewEngine(Endpoint{Host: "unix://" + sock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e, fake
+}
+
+func saveStack(t *testing.T, opts domain.CreateOptions) {
+	t.Helper()
+	runs := stack.NewRunStore()
+	save := runs.Save
+	if runs.Exists(opts.Name) {
+		save = runs.Update
+	}
+	if _, err := save(context.Background(), opts); err != nil {
+		t.Fatalf("save stack: %v", err)
+	}
+}
+
+func TestEngineUpCreatesAndReconcilesStack(t *testing.T) {
+	e, fake := startFakeEngine(t)
+	ctx := context.Background()
+	opts := domain.CreateOptions{
+		Name:           "demo",
+		Provider:       domain.ProviderBase,
+		TmuxAccess:     "read",
+		ReadOnlyPort:   7681,
+		FirewallEnable: true,
+		TunnelEnable:   true,
+		Env:            map[string]string{"PRICE": "$5"},
+		Auth:           domain.Auth{TunnelToken: "tok"},
+	}
+	saveStack(t, opts)
+
+	if err := e.Up(ctx, "demo"); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if got := strings.Join(fake.created, ","); got != "demo-vibecontainer,demo-cloudflared" {
+		t.Fatalf("expected vibecontainer to be created before cloudflared, got %s", got)
+	}
+	if _, ok := fake.networks["demo_default"]; !ok {
+		t.Fatalf("expected the default network to be created")
+	}
+	vibe, tunnel := fake.find("demo-vibecontainer"), fake.find("demo-cloudflared")
+	if vibe.State != "running" || tunnel.State != "running" {
+		t.Fatalf("expected both containers to run")
+	}
+	if b := vibe.Create.HostConfig.PortBindings["7681/tcp"]; len(b) != 1 || b[0] != (hostBinding{HostIP: "127.0.0.1", HostPort: "7681"}) {
+		t.Fatalf("unexpected port bindings %+v", vibe.Create.HostConfig.PortBindings)
+	}
+	env := strings.Join(vibe.Create.Env, " ")
+	if !strings.Contains(env, "PRICE=$5") || !strings.Contains(env, "TMUX_WEB_ENABLE=1") {
+		t.Fatalf("unexpected env %s", env)
+	}
+	if vibe.Create.Labels[composeProjectLabel] != "demo" || vibe.Create.Labels["com.openhoo.vibecontainer.stack"] != "demo" {
+		t.Fatalf("missing labels %v", vibe.Create.Labels)
+	}
+	if tunnel.Create.HostConfig.NetworkMode != "container:"+vibe.ID {
+		t.Fatalf("cloudflared should share the vibecontainer network, got %q", tunnel.Create.HostConfig.NetworkMode)
+	}
+	if strings.Join(tunnel.Create.Env, " ") != "TUNNEL_TOKEN=tok" {
+		t.Fatalf("expected the tunnel token from .env, got %v", tunnel.Create.Env)
+	}
+
+	if err := e.Up(ctx, "demo"); err != nil {
+		t.Fatalf("second Up failed: %v", err)
+	}
+	if len(fake.created) != 2 {
+		t.Fatalf("unchanged stack should not be recreated, created %v", fake.created)
+	}
+
+	opts.ReadOnlyPort = 7700
+	saveStack(t, opts)
+	if err := e.Up(ctx, "demo"); err != nil {
+		t.Fatalf("Up after change failed: %v", err)
+	}
+	if len(fake.created) != 4 {
+		t.Fatalf("expected both containers to be recreated, created %v", fake.created)
+	}
+
+	// Recreating only vibecontainer takes along cloudflared, which shares
+	// its network namespace.
+	opts.ReadOnlyPort = 7710
+	saveStack(t, opts)
+	if err := e.Up(ctx, "demo", "vibecontainer"); err != nil {
+		t.Fatalf("scoped Up failed: %v", err)
+	}
+	if got := strings.Join(fake.created[4:], ","); got != "demo-vibecontainer,demo-cloudflared" {
+		t.Fatalf("expected vibecontainer and its sidecar to be recreated, got %s", got)
+	}
+
+	statuses, err := e.Status(ctx, "demo")
+	if err != nil || len(statuses) != 2 || statuses[0].Service != "vibecontainer" || statuses[0].Health != "healthy" {
+		t.Fatalf("unexpected status %+v: %v", statuses, err)
+	}
+
+	if err := e.Down(ctx, "demo"); err != nil {
+		t.Fatalf("Down failed: %v", err)
+	}
+	if len(fake.containers) != 0 || len(fake.networks) != 0 {
+		t.Fatalf("expected Down to remove everything, left %d containers and %v", len(fake.containers), fake.networks)
+	}
+}
+
+func TestEngineLogsPrefixesServices(t *testing.T) {
+	e, fake := startFakeEngine(t)
+	saveStack(t, domain.CreateOptions{Name: "demo", Provider: domain.ProviderBase, TmuxAccess: "none"})
+	if err := e.Up(context.Background(), "demo"); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	fake.find("demo-vibecontainer").Logs = []string{"hello\nwor", "ld\n"}
+
+	var out bytes.Buffer
+	if err := e.Logs(context.Background(), "demo", LogsOptions{Prefix: true}, &out, io.Discard); err != nil {
+		t.Fatalf("Logs failed: %v", err)
+	}
+	if out.String() != "vibecontainer | hello\nvibecontainer | world\n" {
+		t.Fatalf("unexpected logs %q", out.String())
+	}
+}
+
+func TestEngineEvents(t *testing.T) {
+	e, _ := startFakeEngine(t)
+	var got []Event
+	if err := e.Events(context.Background(), func(ev Event) { got = append(got, ev) }); err != nil {
+		t.Fatalf("Events failed: %v", err)
+	}
+	if len(got) != 2 || got[0].Stack != "demo" || got[0].Action != "start" || got[1].Container != "demo-vibecontainer" {
+		t.Fatalf("unexpected events %+v", got)
+	}
+}
+
+func TestEngineReportsAPIErrors(t *testing.T) {
+	e, _ := startFakeEngine(t)
+	_, err := e.Inspect(context.Background(), "missing")
+	if !isNotFound(err) || !strings.Contains(err.Error(), "No such container") {
+		t.Fatalf("expected a not-found error, got %v", err)
+	}
+}
+
+func TestParsePortSpec(t *testing.T) {
+	for spec, want := range map[string][3]string{
+		"9001:7681":                         {"", "9001", "7681/tcp"},
+		"127.0.0.1:9001:7681":               {"127.0.0.1", "9001", "7681/tcp"},
+		"[fd7a:115c:a1e0::1]:9001:7681":     {"fd7a:115c:a1e0::1", "9001", "7681/tcp"},
+		"[fd7a:115c:a1e0::1]:9001:7681/udp": {"fd7a:115c:a1e0::1", "9001", "7681/udp"},
+	} {
+		ip, host, port, err := parsePortSpec(spec)
+		if err != nil || [3]string{ip, host, port} != want {
+			t.Errorf("parsePortSpec(%q) = %q, %q, %q, %v; want %q", spec, ip, host, port, err, want)
+		}
+	}
+	for _, spec := range []string{"7681", "9001:http"} {
+		if _, _, _, err := parsePortSpec(spec); err == nil {
+			t.Errorf("parsePortSpec(%q): expected an error", spec)
+		}
+	}
+}
+
+func TestLogsSince(t *testing.T) {
+	now := mustTime(t, "2026-01-02T13:00:00Z")
+	for in, want := range map[string]string{
+		"10m":                  fmt.Sprint(now.Unix() - 600),
+		"2026-01-02T12:00:00Z": fmt.Sprint(now.Unix() - 3600),
+		"1700000000":           "1700000000",
+	} {
+		got, err := logsSince(in, now)
+		if err != nil || got != want {
+			t.Errorf("logsSince(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := logsSince("yesterday", now); err == nil {
+		t.Errorf("expected an error for an unparseable value")
+	}
+}
+
+func mustTime(t *testing.T, s string) time.Time {
+	t.Helper()
+	v, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestEngineExec(t *testing.T) {
+	e, fake := startFakeEngine(t)
+	ctx := context.Background()
+	fake.containers = append(fake.containers, &fakeContainer{ID: "id1", Name: "demo-vibecontainer", State: "running"})
+
+	var stdout, stderr bytes.Buffer
+	err := e.Exec(ctx, "demo-vibecontainer", ExecOptions{
+		Command: []string{"cat"},
+		User:    "dev",
+		Workdir: "/workspace",
+		Stdin:   strings.NewReader("hello"),
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+	})
+	if err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if stdout.String() != "hello" || stderr.String() != "cat" {
+		t.Fatalf("unexpected output %q %q", stdout.String(), stderr.String())
+	}
+	if cfg := fake.execs["exec1"].Config; cfg.User != "dev" || cfg.WorkingDir != "/workspace" || !cfg.AttachStdin || cfg.Tty {
+		t.Fatalf("unexpected exec config %+v", cfg)
+	}
+
+	err = e.Exec(ctx, "demo-vibecontainer", ExecOptions{Command: []string{"false"}, Stdout: io.Discard, Stderr: io.Discard})
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 1 {
+		t.Fatalf("expected exit status 1, got %v", err)
+	}
+
+	if err := e.Exec(ctx, "missing", ExecOptions{Command: []string{"true"}}); !isNotFound(err) {
+		t.Fatalf("expected a missing container to be reported, got %v", err)
+	}
+}
+
+func TestSplitImageRef(t *testing.T) {
+	tests := []struct{ ref, repo, tag string }{
+		{"alpine", "alpine", "latest"},
+		{"alpine:3.20", "alpine", "3.20"},
+		{"localhost:5000/tools/agent", "localhost:5000/tools/agent", "latest"},
+		{"localhost:5000/tools/agent:v1", "localhost:5000/tools/agent", "v1"},
+		{"ghcr.io/openhoo/vibecontainer@sha256:abc", "ghcr.io/openhoo/vibecontainer", "sha256:abc"},
+	}
+	for _, tt := range tests {
+		if repo, tag := splitImageRef(tt.ref); repo != tt.repo || tag != tt.tag {
+			t.Errorf("splitImageRef(%q) = %q, %q, want %q, %q", tt.ref, repo, tag, tt.repo, tt.tag)
+		}
+	}
+}
+
+func TestEnginePullsWithSavedCredentials(t *testing.T) {
+	e, fake := startFakeEngine(t)
+	dir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dir)
+	config := `{"auths":{"registry.example.com":{"auth":"` + base64.StdEncoding.EncodeToString([]byte("dev:s3cret")) + `"}}}`
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.ensureImage(context.Background(), "registry.example.com/agent:v2"); err != nil {
+		t.Fatalf("ensureImage failed: %v", err)
+	}
+	if !fake.images["registry.example.com/agent:v2"] {
+		t.Fatalf("expected the tag to be pulled, got %v", fake.images)
+	}
+	b, err := base64.URLEncoding.DecodeString(fake.pullAuth)
+	if err != nil {
+		t.Fatalf("decode X-Registry-Auth %q: %v", fake.pullAuth, err)
+	}
+	var auth registryAuth
+	if err := json.Unmarshal(b, &auth); err != nil || auth != (registryAuth{Username: "dev", Password: "s3cret", ServerAddress: "registry.example.com"}) {
+		t.Fatalf("unexpected registry auth %s", b)
+	}
+
+	if err := e.ensureImage(context.Background(), "alpine"); err != nil {
+		t.Fatalf("ensureImage failed: %v", err)
+	}
+	if !fake.images["alpine:latest"] || fake.pullAuth != "" {
+		t.Fatalf("expected an anonymous pull of alpine:latest, got %v %q", fake.images, fake.pullAuth)
+	}
+}
+
+func TestEngineRemoveContainer(t *testing.T) {
+	e, fake := startFakeEngine(t)
+	ctx := context.Background()
+	fake.containers = append(fake.containers, &fakeContainer{ID: "id1", Name: "agent", State: "running"})
+
+	if err := e.RemoveContainer(ctx, "agent"); err != nil {
+		t.Fatalf("RemoveContainer failed: %v", err)
+	}
+	if fake.find("agent") != nil {
+		t.Fatalf("expected agent to be removed")
+	}
+	if err := e.RemoveContainer(ctx, "agent"); err != nil {
+		t.Fatalf("expected removing a missing container to succeed, got %v", err)
+	}
+}
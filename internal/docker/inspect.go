@@ -0,0 +1,138 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/openhoo/vibecontainer/internal/domain"
+)
+
+// inspectOutput mirrors the fields of `docker inspect` that domain.ContainerInfo
+// is built from.
+type inspectOutput struct {
+	Name   string `json:"Name"`
+	Config struct {
+		Image  string            `json:"Image"`
+		Env    []string          `json:"Env"`
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+	State struct {
+		Status string `json:"Status"`
+	} `json:"State"`
+	HostConfig struct {
+		CapAdd       []string `json:"CapAdd"`
+		PortBindings map[string][]struct {
+			HostIP   string `json:"HostIp"`
+			HostPort string `json:"HostPort"`
+		} `json:"PortBindings"`
+	} `json:"HostConfig"`
+	Mounts []struct {
+		Type        string `json:"Type"`
+		Source      string `json:"Source"`
+		Destination string `json:"Destination"`
+		RW          bool   `json:"RW"`
+	} `json:"Mounts"`
+}
+
+// Inspect describes a container by name or ID.
+func (c *Compose) Inspect(ctx context.Context, container string) (domain.ContainerInfo, error) {
+	stdout, stderr, err := c.runner.Run(ctx, c.dialect.Binary, "inspect", "--type", "container", container)
+	if err != nil {
+		return domain.ContainerInfo{}, fmt.Errorf("%s inspect failed: %w\n%s", c.dialect.Binary, err, strings.TrimSpace(stderr))
+	}
+	return parseInspect(stdout)
+}
+
+// ImageEnv returns the environment baked into an image, so callers can tell
+// it apart from variables set when the container was created.
+func (c *Compose) ImageEnv(ctx context.Context, image string) (map[string]string, error) {
+	stdout, stderr, err := c.runner.Run(ctx, c.dialect.Binary, "image", "inspect", "--format", "{{json .Config.Env}}", image)
+	if err != nil {
+		return nil, fmt.Errorf("%s image inspect failed: %w\n%s", c.dialect.Binary, err, strings.TrimSpace(stderr))
+	}
+	var env []string
+	if err := json.Unmarshal([]byte(strings.TrimSpace(stdout)), &env); err != nil {
+		return nil, fmt.Errorf("parse %s image inspect output: %w", c.dialect.Binary, err)
+	}
+	return envMap(env), nil
+}
+
+// RemoveContainer force-removes a single container.
+func (c *Compose) RemoveContainer(ctx context.Context, container string) error {
+	if _, stderr, err := c.runner.Run(ctx, c.dialect.Binary, "rm", "-f", container); err != nil {
+		return fmt.Errorf("%s rm failed: %w\n%s", c.dialect.Binary, err, strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+// StopContainer stops a single container.
+func (c *Compose) StopContainer(ctx context.Context, container string) error {
+	if _, stderr, err := c.runner.Run(ctx, c.dialect.Binary, "stop", container); err != nil {
+		return fmt.Errorf("%s stop failed: %w\n%s", c.dialect.Binary, err, strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+// StartContainer starts a single container.
+func (c *Compose) StartContainer(ctx context.Context, container string) error {
+	if _, stderr, err := c.runner.Run(ctx, c.dialect.Binary, "start", container); err != nil {
+		return fmt.Errorf("%s start failed: %w\n%s", c.dialect.Binary, err, strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+func parseInspect(stdout string) (domain.ContainerInfo, error) {
+	var items []inspectOutput
+	if err := json.Unmarshal([]byte(stdout), &items); err != nil {
+		return domain.ContainerInfo{}, fmt.Errorf("parse docker inspect output: %w", err)
+	}
+	if len(items) != 1 {
+		return domain.ContainerInfo{}, fmt.Errorf("docker inspect returned %d containers", len(items))
+	}
+	return items[0].info(), nil
+}
+
+func (item inspectOutput) info() domain.ContainerInfo {
+	info := domain.ContainerInfo{
+		Name:   strings.TrimPrefix(item.Name, "/"),
+		Image:  item.Config.Image,
+		State:  item.State.Status,
+		Env:    envMap(item.Config.Env),
+		Labels: item.Config.Labels,
+		CapAdd: item.HostConfig.CapAdd,
+	}
+	if info.Labels == nil {
+		info.Labels = map[string]string{}
+	}
+	for containerPort, bindings := range item.HostConfig.PortBindings {
+		port, err := strconv.Atoi(strings.TrimSuffix(containerPort, "/tcp"))
+		if err != nil {
+			continue
+		}
+		for _, b := range bindings {
+			hostPort, err := strconv.Atoi(b.HostPort)
+			if err != nil {
+				continue
+			}
+			info.Ports = append(info.Ports, domain.PortBinding{HostIP: b.HostIP, HostPort: hostPort, ContainerPort: port})
+		}
+	}
+	sort.Slice(info.Ports, func(i, j int) bool { return info.Ports[i].ContainerPort < info.Ports[j].ContainerPort })
+	for _, m := range item.Mounts {
+		info.Mounts = append(info.Mounts, domain.Mount{Type: m.Type, Source: m.Source, Destination: m.Destination, ReadOnly: !m.RW})
+	}
+	return info
+}
+
+func envMap(env []string) map[string]string {
+	out := make(map[string]string, len(env))
+	for _, kv := range env {
+		k, v, _ := strings.Cut(kv, "=")
+		out[k] = v
+	}
+	return out
+}
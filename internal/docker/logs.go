@@ -0,0 +1,60 @@
+package docker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// prefixWriter labels each complete line written to it with a service name
+// before passing it on. Several prefixWriters may share one destination; mu
+// serializes their writes so lines from different services never interleave.
+type prefixWriter struct {
+	dst    io.Writer
+	mu     *sync.Mutex
+	prefix []byte
+	buf    []byte
+}
+
+func newPrefixWriter(dst io.Writer, mu *sync.Mutex, name string, width int) *prefixWriter {
+	return &prefixWriter{
+		dst:    dst,
+		mu:     mu,
+		prefix: []byte(fmt.Sprintf("%-*s | ", width, name)),
+	}
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := w.emit(w.buf[:i+1]); err != nil {
+			return len(p), err
+		}
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes any trailing partial line.
+func (w *prefixWriter) Flush() {
+	if len(w.buf) == 0 {
+		return
+	}
+	_ = w.emit(append(w.buf, '\n'))
+	w.buf = nil
+}
+
+func (w *prefixWriter) emit(line []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.dst.Write(w.prefix); err != nil {
+		return err
+	}
+	_, err := w.dst.Write(line)
+	return err
+}
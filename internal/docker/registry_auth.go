@@ -0,0 +1,120 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// defaultRegistry is the key Docker Hub credentials are saved under in the
+// docker CLI's config.json.
+const defaultRegistry = "https://index.docker.io/v1/"
+
+// splitImageRef splits an image reference into the repository and the tag
+// or digest /images/create takes separately. The tag defaults to latest.
+func splitImageRef(ref string) (repo, tag string) {
+	if repo, digest, ok := strings.Cut(ref, "@"); ok {
+		return repo, digest
+	}
+	// A colon before the last slash separates a registry's port.
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, "latest"
+}
+
+// registryOf returns the registry a repository is pulled from, as the
+// docker CLI keys it in config.json.
+func registryOf(repo string) string {
+	first, _, ok := strings.Cut(repo, "/")
+	if ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return first
+	}
+	return defaultRegistry
+}
+
+// registryAuth is the X-Registry-Auth header value.
+type registryAuth struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+	ServerAddress string `json:"serveraddress"`
+}
+
+// registryAuthHeader returns the X-Registry-Auth header for pulling repo
+// with the credentials `docker login` saved, or "" when there are none.
+// Credential helpers are asked the way the docker CLI asks them.
+func registryAuthHeader(ctx context.Context, repo string) (string, error) {
+	cfg, err := loadDockerConfig()
+	if err != nil {
+		return "", err
+	}
+
+	registry := registryOf(repo)
+	auth := registryAuth{ServerAddress: registry}
+	helper := cfg.CredHelpers[registry]
+	if helper == "" {
+		helper = cfg.CredsStore
+	}
+	if helper != "" {
+		if err := credentialHelper(ctx, helper, registry, &auth); err != nil {
+			return "", err
+		}
+	} else if saved, ok := cfg.Auths[registry]; ok {
+		auth.IdentityToken = saved.IdentityToken
+		if saved.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(saved.Auth)
+			if err != nil {
+				return "", fmt.Errorf("credentials for %s in config.json: %w", registry, err)
+			}
+			auth.Username, auth.Password, _ = strings.Cut(string(decoded), ":")
+		}
+	}
+	if auth.Username == "" && auth.IdentityToken == "" {
+		return "", nil
+	}
+	header, err := json.Marshal(auth)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(header), nil
+}
+
+// credentialHelper fills auth from docker-credential-<helper>. A registry
+// the helper has nothing for leaves auth empty.
+func credentialHelper(ctx context.Context, helper, registry string, auth *registryAuth) error {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(registry)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		// Without its helper the CLI couldn't use the credentials either;
+		// pull anonymously.
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil
+		}
+		if strings.Contains(stdout.String()+stderr.String(), "credentials not found") {
+			return nil
+		}
+		return fmt.Errorf("docker-credential-%s: %w", helper, err)
+	}
+	var creds struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &creds); err != nil {
+		return fmt.Errorf("parse docker-credential-%s output: %w", helper, err)
+	}
+	// Helpers return identity tokens under the username <token>.
+	if creds.Username == "<token>" {
+		auth.IdentityToken = creds.Secret
+	} else {
+		auth.Username, auth.Password = creds.Username, creds.Secret
+	}
+	return nil
+}
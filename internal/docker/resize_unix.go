@@ -0,0 +1,15 @@
+//go:build unix
+
+package docker
+
+import (
+	"os"
+	"os/signal"
+
+	"golang.org/x/sys/unix"
+)
+
+// notifyResize relays terminal size changes to ch.
+func notifyResize(ch chan<- os.Signal) {
+	signal.Notify(ch, unix.SIGWINCH)
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package docker
+
+import "os"
+
+// notifyResize does nothing: Windows consoles don't signal size changes, so
+// sessions keep the size they started with.
+func notifyResize(ch chan<- os.Signal) {}
@@ -4,11 +4,18 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os/exec"
 )
 
 type Runner interface {
 	Run(ctx context.Context, cmd string, args ...string) (string, string, error)
+	// Stream runs cmd and copies its output to stdout and stderr as it is
+	// produced. It returns when the process exits or ctx is canceled.
+	Stream(ctx context.Context, stdout, stderr io.Writer, cmd string, args ...string) error
+	// Interactive runs cmd wired directly to the given streams so that a
+	// terminal on stdin is passed through to the child untouched.
+	Interactive(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, cmd string, args ...string) error
 }
 
 type ExecRunner struct{}
@@ -22,11 +29,39 @@ func (r *ExecRunner) Run(ctx context.Context, cmd string, args ...string) (strin
 	c.Stderr = &stderr
 	err := c.Run()
 	if err != nil {
-		sub := ""
-		if len(args) > 0 {
-			sub = " " + args[0]
-		}
-		return stdout.String(), stderr.String(), fmt.Errorf("%s%s: %w", cmd, sub, err)
+		return stdout.String(), stderr.String(), commandError(cmd, args, err)
 	}
 	return stdout.String(), stderr.String(), nil
 }
+
+func (r *ExecRunner) Stream(ctx context.Context, stdout, stderr io.Writer, cmd string, args ...string) error {
+	c := exec.CommandContext(ctx, cmd, args...)
+	c.Stdout = stdout
+	c.Stderr = stderr
+	if err := c.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return commandError(cmd, args, err)
+	}
+	return nil
+}
+
+func (r *ExecRunner) Interactive(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, cmd string, args ...string) error {
+	c := exec.CommandContext(ctx, cmd, args...)
+	c.Stdin = stdin
+	c.Stdout = stdout
+	c.Stderr = stderr
+	if err := c.Run(); err != nil {
+		return commandError(cmd, args, err)
+	}
+	return nil
+}
+
+func commandError(cmd string, args []string, err error) error {
+	sub := ""
+	if len(args) > 0 {
+		sub = " " + args[0]
+	}
+	return fmt.Errorf("%s%s: %w", cmd, sub, err)
+}
@@ -0,0 +1,57 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/openhoo/vibecontainer/internal/domain"
+)
+
+// WaitHealthy polls the services of stack until every one is running and,
+// when it has a healthcheck, healthy. It fails as soon as a service exits or
+// turns unhealthy, or when ctx is done.
+func WaitHealthy(ctx context.Context, b Backend, stack string, interval time.Duration) error {
+	pending := []string{"stack " + stack}
+	for {
+		statuses, err := b.Status(ctx, stack)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			return err
+		case err == nil && len(statuses) > 0:
+			if pending, err = unhealthy(statuses); err != nil {
+				return err
+			}
+			if len(pending) == 0 {
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for %s to become healthy", strings.Join(pending, ", "))
+		case <-time.After(interval):
+		}
+	}
+}
+
+// unhealthy lists the services that are not ready yet, or fails if one
+// never will be.
+func unhealthy(statuses []domain.ServiceStatus) ([]string, error) {
+	var pending []string
+	for _, s := range statuses {
+		name := s.Service
+		if name == "" {
+			name = s.Name
+		}
+		switch {
+		case s.State == "exited" || s.State == "dead":
+			return nil, fmt.Errorf("service %s has %s", name, s.State)
+		case s.Health == "unhealthy":
+			return nil, fmt.Errorf("service %s is unhealthy", name)
+		case s.State != "running" || s.Health == "starting":
+			pending = append(pending, name)
+		}
+	}
+	return pending, nil
+}
@@ -0,0 +1,60 @@
+package docker
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// statusRunner answers compose ps with each of its outputs in turn,
+// repeating the last.
+type statusRunner struct {
+	fakeRunner
+	outputs []string
+}
+
+func (r *statusRunner) Run(ctx context.Context, cmd string, args ...string) (string, string, error) {
+	r.record(cmd, args)
+	out := r.outputs[0]
+	if len(r.outputs) > 1 {
+		r.outputs = r.outputs[1:]
+	}
+	return out, "", nil
+}
+
+func TestWaitHealthy(t *testing.T) {
+	starting := `{"Name":"demo-vibecontainer","Service":"vibecontainer","State":"running","Health":"starting"}`
+	healthy := `{"Name":"demo-vibecontainer","Service":"vibecontainer","State":"running","Health":"healthy"}
+{"Name":"demo-cloudflared","Service":"cloudflared","State":"running","Health":""}`
+	unhealthy := `{"Name":"demo-vibecontainer","Service":"vibecontainer","State":"running","Health":"unhealthy"}`
+	exited := `{"Name":"demo-vibecontainer","Service":"vibecontainer","State":"exited","Health":""}`
+
+	cases := []struct {
+		name    string
+		outputs []string
+		err     string
+	}{
+		{"becomes healthy", []string{"", starting, healthy}, ""},
+		{"unhealthy", []string{starting, unhealthy}, "service vibecontainer is unhealthy"},
+		{"exited", []string{exited}, "service vibecontainer has exited"},
+		{"times out", []string{starting}, "timed out waiting for vibecontainer"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			c := NewCompose(&statusRunner{outputs: tc.outputs})
+			err := WaitHealthy(ctx, c, "demo", time.Millisecond)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("WaitHealthy failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("expected error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
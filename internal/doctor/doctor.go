@@ -0,0 +1,289 @@
+// Package doctor checks the host environment that stack creation depends on.
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/openhoo/vibecontainer/internal/docker"
+	"github.com/openhoo/vibecontainer/internal/domain"
+	"github.com/openhoo/vibecontainer/internal/keyring"
+	"github.com/openhoo/vibecontainer/internal/stack"
+)
+
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+)
+
+// Result is the outcome of one check. Fix says how to resolve a warning or
+// failure.
+type Result struct {
+	Name   string `json:"name" yaml:"name"`
+	Status Status `json:"status" yaml:"status"`
+	Detail string `json:"detail" yaml:"detail"`
+	Fix    string `json:"fix,omitempty" yaml:"fix,omitempty"`
+}
+
+// Dir names a directory the CLI must be able to write to.
+type Dir struct {
+	Name string
+	Path string
+}
+
+// Doctor runs the checks. Its dependencies are fields so tests can replace
+// them.
+type Doctor struct {
+	Runner docker.Runner
+	// Dialect is the container CLI to check; the zero value means docker.
+	Dialect  docker.Dialect
+	Defaults domain.Defaults
+	// Keyring probes the credential backend.
+	Keyring func() error
+	// KeyringBackend describes where credentials are kept; KeyringFallback
+	// is why that isn't the system keychain, when it isn't.
+	KeyringBackend  string
+	KeyringFallback string
+	// PortFree reports whether a host port can be bound on addr.
+	PortFree func(addr string, port int) error
+	// StackPorts maps the host ports managed stacks publish to the stack
+	// that publishes them. Those ports are expected to be in use.
+	StackPorts map[int]string
+	Dirs       []Dir
+}
+
+// Run executes every check in order. Checks that need the daemon are skipped
+// when the container CLI or daemon is unavailable.
+func (d *Doctor) Run(ctx context.Context) []Result {
+	var results []Result
+	if d.Dialect.Binary == "" {
+		d.Dialect = docker.DockerCLI
+	}
+
+	cli := d.checkCLI(ctx)
+	results = append(results, cli)
+	if cli.Status != StatusFail {
+		results = append(results, d.checkCompose(ctx))
+		daemon, caps := d.checkDaemon(ctx)
+		results = append(results, daemon)
+		if daemon.Status != StatusFail {
+			results = append(results, d.checkRootless(caps))
+			results = append(results, d.checkImages(ctx)...)
+		}
+	}
+	results = append(results, d.checkKeyring())
+	results = append(results, d.checkPorts()...)
+	results = append(results, d.checkDirs()...)
+	return results
+}
+
+// Failed reports how many results are hard failures.
+func Failed(results []Result) int {
+	n := 0
+	for _, r := range results {
+		if r.Status == StatusFail {
+			n++
+		}
+	}
+	return n
+}
+
+func (d *Doctor) checkCLI(ctx context.Context) Result {
+	r := Result{Name: d.Dialect.Title + " CLI"}
+	stdout, stderr, err := d.Runner.Run(ctx, d.Dialect.Binary, "version", "--format", "{{.Client.Version}}")
+	// `docker version` exits non-zero when only the daemon is down; the
+	// client version is still printed in that case.
+	version := strings.TrimSpace(stdout)
+	if errors.Is(err, exec.ErrNotFound) || version == "" {
+		r.Status = StatusFail
+		r.Detail = firstLine(stderr, err)
+		r.Fix = fmt.Sprintf("Install %s (%s) and make sure `%s` is on PATH.", d.Dialect.Title, d.Dialect.InstallURL, d.Dialect.Binary)
+		return r
+	}
+	r.Status = StatusOK
+	r.Detail = version
+	return r
+}
+
+func (d *Doctor) checkCompose(ctx context.Context) Result {
+	r := Result{Name: d.Dialect.Title + " Compose"}
+	stdout, stderr, err := d.Runner.Run(ctx, d.Dialect.Binary, "compose", "version", "--short")
+	if err != nil {
+		r.Status = StatusFail
+		r.Detail = firstLine(stderr, err)
+		if d.Dialect.Name == docker.DockerCLI.Name {
+			r.Fix = "Install the Docker Compose v2 plugin (https://docs.docker.com/compose/install/)."
+		} else {
+			r.Fix = fmt.Sprintf("Make sure `%s compose` works; see %s.", d.Dialect.Binary, d.Dialect.InstallURL)
+		}
+		return r
+	}
+	version := strings.TrimPrefix(strings.TrimSpace(stdout), "v")
+	// Only docker has a legacy compose; podman and nerdctl version their
+	// providers independently.
+	major, _ := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+	if d.Dialect.Name == docker.DockerCLI.Name && major < 2 {
+		r.Status = StatusFail
+		r.Detail = version
+		r.Fix = "Upgrade to Docker Compose v2; the standalone v1 `docker-compose` is not supported."
+		return r
+	}
+	r.Status = StatusOK
+	r.Detail = version
+	return r
+}
+
+func (d *Doctor) checkDaemon(ctx context.Context) (Result, docker.Capabilities) {
+	r := Result{Name: d.Dialect.Title + " daemon"}
+	caps, err := docker.NewComposeFor(d.Runner, d.Dialect).Capabilities(ctx)
+	if err != nil {
+		r.Status = StatusFail
+		r.Detail = firstLine(err.Error(), nil)
+		r.Fix = fmt.Sprintf("Start the %s daemon (or Docker Desktop) and check that your user can access its socket.", d.Dialect.Title)
+		if d.Dialect.Name == docker.PodmanCLI.Name {
+			r.Fix = "Check that `podman info` works for your user (on macOS and Windows run `podman machine start`)."
+		}
+		return r, caps
+	}
+	r.Status = StatusOK
+	r.Detail = "server " + caps.ServerVersion
+	return r, caps
+}
+
+func (d *Doctor) checkRootless(caps docker.Capabilities) Result {
+	r := Result{Name: "Rootless mode", Status: StatusOK, Detail: "daemon runs as root"}
+	if !caps.Rootless {
+		return r
+	}
+	if !d.Defaults.FirewallEnable {
+		r.Detail = "daemon is rootless; firewall is disabled by default"
+		return r
+	}
+	r.Status = StatusWarn
+	r.Detail = "daemon is rootless; NET_ADMIN/NET_RAW may be unable to manage the container firewall"
+	r.Fix = fmt.Sprintf("Check that the firewall applies in a test stack; if not, create stacks with --firewall-enable=false or run %s rootful.", d.Dialect.Title)
+	return r
+}
+
+func (d *Doctor) checkImages(ctx context.Context) []Result {
+	images := []string{stack.DefaultImage(d.Defaults.Provider)}
+	if d.Defaults.TunnelEnable {
+		images = append(images, stack.CloudflaredImage)
+	}
+	results := make([]Result, 0, len(images))
+	for _, image := range images {
+		r := Result{Name: "Image " + image, Status: StatusOK, Detail: "present locally"}
+		if _, _, err := d.Runner.Run(ctx, d.Dialect.Binary, "image", "inspect", "--format", "{{.Id}}", image); err != nil {
+			r.Status = StatusWarn
+			r.Detail = "not pulled yet; the first create will download it"
+			r.Fix = d.Dialect.Binary + " pull " + image
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+func (d *Doctor) checkKeyring() Result {
+	r := Result{Name: "Keychain"}
+	if err := d.Keyring(); err != nil {
+		r.Status = StatusWarn
+		r.Detail = err.Error()
+		r.Fix = "Credentials can't be saved between runs. On Linux start a Secret Service provider " +
+			"(e.g. gnome-keyring-daemon --start), or pass credentials as flags with --no-save-auth."
+		if d.KeyringFallback != "" {
+			r.Detail = fmt.Sprintf("%s: %s (no system keychain: %s)", d.KeyringBackend, err, d.KeyringFallback)
+			r.Fix = fmt.Sprintf("Set $%s or point $%s at a key file to use the encrypted file, or start a Secret Service provider "+
+				"(e.g. gnome-keyring-daemon --start).", keyring.PassphraseEnv, keyring.KeyFileEnv)
+		}
+		return r
+	}
+	r.Status = StatusOK
+	r.Detail = "credentials can be stored"
+	if d.KeyringBackend != "" {
+		r.Detail += " in " + d.KeyringBackend
+	}
+	if d.KeyringFallback != "" {
+		r.Detail += fmt.Sprintf(" (no system keychain: %s)", d.KeyringFallback)
+	}
+	return r
+}
+
+func (d *Doctor) checkPorts() []Result {
+	var ports []int
+	switch d.Defaults.TmuxAccess {
+	case "read":
+		ports = []int{d.Defaults.ReadOnlyPort}
+	case "write":
+		ports = []int{d.Defaults.ReadOnlyPort, d.Defaults.InteractivePort}
+	}
+	addr := domain.PublishAddress(d.Defaults.BindAddress)
+	results := make([]Result, 0, len(ports))
+	for _, port := range ports {
+		r := Result{Name: fmt.Sprintf("Port %d", port), Status: StatusOK, Detail: "free"}
+		if name, ok := d.StackPorts[port]; ok {
+			r.Detail = "used by stack " + name
+		} else if err := d.PortFree(addr, port); err != nil {
+			r.Status = StatusWarn
+			r.Detail = "in use"
+			r.Fix = fmt.Sprintf("Stop whatever listens on %s, or create stacks with --readonly-port auto/--interactive-port auto.", net.JoinHostPort(addr, strconv.Itoa(port)))
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+func (d *Doctor) checkDirs() []Result {
+	results := make([]Result, 0, len(d.Dirs))
+	for _, dir := range d.Dirs {
+		r := Result{Name: dir.Name, Status: StatusOK, Detail: dir.Path}
+		if err := writable(dir.Path); err != nil {
+			r.Status = StatusFail
+			r.Detail = err.Error()
+			r.Fix = fmt.Sprintf("Make %s writable by your user, or point XDG_CONFIG_HOME/XDG_DATA_HOME elsewhere.", dir.Path)
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// PortFree reports whether port can be bound on addr.
+func PortFree(addr string, port int) error {
+	l, err := net.Listen("tcp", net.JoinHostPort(addr, strconv.Itoa(port)))
+	if err != nil {
+		return err
+	}
+	return l.Close()
+}
+
+func writable(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	_ = f.Close()
+	return os.Remove(name)
+}
+
+func firstLine(stderr string, err error) string {
+	if s := strings.TrimSpace(stderr); s != "" {
+		line, _, _ := strings.Cut(s, "\n")
+		return line
+	}
+	if err != nil {
+		return err.Error()
+	}
+	return "unexpected output"
+}
@@ -0,0 +1,201 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/openhoo/vibecontainer/internal/docker"
+	"github.com/openhoo/vibecontainer/internal/domain"
+	"github.com/openhoo/vibecontainer/internal/keyring"
+)
+
+// scriptedRunner answers Run calls by the first two docker arguments.
+type scriptedRunner struct {
+	stdout map[string]string
+	errs   map[string]error
+}
+
+func (r *scriptedRunner) Run(ctx context.Context, cmd string, args ...string) (string, string, error) {
+	key := strings.Join(args[:2], " ")
+	return r.stdout[key], "", r.errs[key]
+}
+
+func (r *scriptedRunner) Stream(ctx context.Context, stdout, stderr io.Writer, cmd string, args ...string) error {
+	return nil
+}
+
+func (r *scriptedRunner) Interactive(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, cmd string, args ...string) error {
+	return nil
+}
+
+func healthyRunner() *scriptedRunner {
+	return &scriptedRunner{
+		stdout: map[string]string{
+			"version --format": "27.3.1\n",
+			"compose version":  "v2.29.7\n",
+			"info --format":    `{"ServerVersion":"27.3.1","SecurityOptions":["name=seccomp,profile=builtin"]}`,
+		},
+		errs: map[string]error{},
+	}
+}
+
+func newDoctor(t *testing.T, r *scriptedRunner) *Doctor {
+	return &Doctor{
+		Runner:   r,
+		Defaults: domain.DefaultDefaults(),
+		Keyring:  func() error { return nil },
+		PortFree: func(string, int) error { return nil },
+		Dirs:     []Dir{{Name: "Data dir", Path: filepath.Join(t.TempDir(), "data")}},
+	}
+}
+
+func find(t *testing.T, results []Result, name string) Result {
+	t.Helper()
+	for _, r := range results {
+		if strings.HasPrefix(r.Name, name) {
+			return r
+		}
+	}
+	t.Fatalf("no %q result in %+v", name, results)
+	return Result{}
+}
+
+func TestDoctorHealthy(t *testing.T) {
+	results := newDoctor(t, healthyRunner()).Run(context.Background())
+	for _, r := range results {
+		if r.Status != StatusOK {
+			t.Errorf("expected %s to pass, got %+v", r.Name, r)
+		}
+	}
+	if Failed(results) != 0 {
+		t.Fatal("expected no failures")
+	}
+}
+
+func TestDoctorMissingDockerSkipsDaemonChecks(t *testing.T) {
+	r := healthyRunner()
+	r.stdout["version --format"] = ""
+	r.errs["version --format"] = exec.ErrNotFound
+	results := newDoctor(t, r).Run(context.Background())
+	if got := find(t, results, "Docker CLI"); got.Status != StatusFail || got.Fix == "" {
+		t.Fatalf("expected docker CLI failure with fix, got %+v", got)
+	}
+	for _, res := range results {
+		if res.Name == "Docker daemon" || res.Name == "Docker Compose" {
+			t.Fatalf("expected daemon checks to be skipped, got %+v", res)
+		}
+	}
+	if Failed(results) != 1 {
+		t.Fatalf("expected exactly one failure, got %d", Failed(results))
+	}
+}
+
+func TestDoctorRootlessAndComposeV1(t *testing.T) {
+	r := healthyRunner()
+	r.stdout["compose version"] = "1.29.2\n"
+	r.stdout["info --format"] = `{"ServerVersion":"27.3.1","SecurityOptions":["name=seccomp,profile=builtin","name=rootless"]}`
+	d := newDoctor(t, r)
+	d.Keyring = func() error { return errors.New("no secret service") }
+	d.PortFree = func(string, int) error { return errors.New("in use") }
+	results := d.Run(context.Background())
+
+	if got := find(t, results, "Docker Compose"); got.Status != StatusFail {
+		t.Fatalf("expected compose v1 to fail, got %+v", got)
+	}
+	if got := find(t, results, "Rootless mode"); got.Status != StatusWarn {
+		t.Fatalf("expected rootless warning, got %+v", got)
+	}
+	if got := find(t, results, "Keychain"); got.Status != StatusWarn {
+		t.Fatalf("expected keychain warning, got %+v", got)
+	}
+	if got := find(t, results, "Port 7681"); got.Status != StatusWarn {
+		t.Fatalf("expected port warning, got %+v", got)
+	}
+}
+
+func TestDoctorDaemonUnreachable(t *testing.T) {
+	r := healthyRunner()
+	r.stdout["info --format"] = ""
+	r.errs["info --format"] = errors.New("exit status 1")
+	results := newDoctor(t, r).Run(context.Background())
+	if got := find(t, results, "Docker daemon"); got.Status != StatusFail {
+		t.Fatalf("expected daemon failure, got %+v", got)
+	}
+	for _, res := range results {
+		if strings.HasPrefix(res.Name, "Image") {
+			t.Fatalf("expected image checks to be skipped, got %+v", res)
+		}
+	}
+}
+
+func TestDoctorPodmanRootless(t *testing.T) {
+	r := healthyRunner()
+	r.stdout["compose version"] = "1.2.0\n"
+	r.stdout["info --format"] = `{"host":{"security":{"rootless":true}},"version":{"Version":"5.2.2"}}`
+	d := newDoctor(t, r)
+	d.Dialect = docker.PodmanCLI
+	results := d.Run(context.Background())
+
+	if got := find(t, results, "Podman Compose"); got.Status != StatusOK {
+		t.Fatalf("expected podman compose to pass, got %+v", got)
+	}
+	if got := find(t, results, "Podman daemon"); got.Detail != "server 5.2.2" {
+		t.Fatalf("expected podman version, got %+v", got)
+	}
+	if got := find(t, results, "Rootless mode"); got.Status != StatusWarn || !strings.Contains(got.Fix, "Podman") {
+		t.Fatalf("expected rootless warning, got %+v", got)
+	}
+}
+
+func TestDoctorKeyringFallback(t *testing.T) {
+	d := newDoctor(t, healthyRunner())
+	d.KeyringBackend = "encrypted file /tmp/secrets.enc"
+	d.KeyringFallback = "no secret service"
+	if got := find(t, d.Run(context.Background()), "Keychain"); got.Status != StatusOK || !strings.Contains(got.Detail, "/tmp/secrets.enc") {
+		t.Fatalf("expected the fallback file in the detail, got %+v", got)
+	}
+
+	d.Keyring = func() error { return keyring.ErrNoUnlock }
+	got := find(t, d.Run(context.Background()), "Keychain")
+	if got.Status != StatusWarn || !strings.Contains(got.Fix, keyring.PassphraseEnv) {
+		t.Fatalf("expected a warning naming %s, got %+v", keyring.PassphraseEnv, got)
+	}
+}
+
+func TestDoctorPorts(t *testing.T) {
+	var checked []string
+	d := newDoctor(t, healthyRunner())
+	d.PortFree = func(addr string, port int) error {
+		checked = append(checked, fmt.Sprintf("%s:%d", addr, port))
+		return errors.New("in use")
+	}
+
+	d.Defaults.TmuxAccess = "none"
+	for _, res := range d.checkPorts() {
+		t.Fatalf("expected no port checks without tmux access, got %+v", res)
+	}
+
+	d.Defaults.TmuxAccess = "write"
+	d.Defaults.BindAddress = "0.0.0.0"
+	d.StackPorts = map[int]string{d.Defaults.ReadOnlyPort: "web"}
+	results := d.checkPorts()
+	if len(results) != 2 {
+		t.Fatalf("expected both ports to be checked, got %+v", results)
+	}
+	if got := results[0]; got.Status != StatusOK || got.Detail != "used by stack web" {
+		t.Fatalf("expected the stack's port to pass, got %+v", got)
+	}
+	if got := results[1]; got.Status != StatusWarn || !strings.Contains(got.Fix, "0.0.0.0:") {
+		t.Fatalf("expected a warning naming the bind address, got %+v", got)
+	}
+	if want := []string{fmt.Sprintf("0.0.0.0:%d", d.Defaults.InteractivePort)}; !slices.Equal(checked, want) {
+		t.Fatalf("checked %v, want %v", checked, want)
+	}
+}
@@ -1,6 +1,11 @@
 package domain
 
-import "time"
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Provider string
 
@@ -19,6 +24,9 @@ func (p Provider) Valid() bool {
 	}
 }
 
+// Auth holds the credentials of a stack. Each field is either the secret
+// itself or a reference to it in a secret manager (see IsSecretRef), which
+// is resolved when the stack's .env is written.
 type Auth struct {
 	ClaudeOAuthToken string `json:"-"`
 	AnthropicAPIKey  string `json:"-"`
@@ -28,27 +36,253 @@ type Auth struct {
 	TunnelToken      string `json:"-"`
 }
 
+// managedEnv lists the variables vibecontainer sets in a stack's compose
+// file itself.
+var managedEnv = map[string]bool{
+	"TMUX_WEB_ENABLE":             true,
+	"TMUX_WEB_INTERACTIVE_ENABLE": true,
+	"FIREWALL_ENABLE":             true,
+	"TTYD_CREDENTIAL":             true,
+	"CLAUDE_CODE_OAUTH_TOKEN":     true,
+	"ANTHROPIC_API_KEY":           true,
+	"CODEX_AUTH_JSON":             true,
+	"OPENAI_API_KEY":              true,
+	"CODEX_API_KEY":               true,
+}
+
+// IsManagedEnv reports whether key is set by vibecontainer and so can't be
+// used as extra environment.
+func IsManagedEnv(key string) bool {
+	return managedEnv[key]
+}
+
+// IsSecretRef reports whether v names a secret kept elsewhere instead of
+// holding it: op://vault/item/field for 1Password, pass:path for pass or
+// env:VAR for an environment variable.
+func IsSecretRef(v string) bool {
+	return strings.HasPrefix(v, "op://") || strings.HasPrefix(v, "pass:") || strings.HasPrefix(v, "env:")
+}
+
+// fields maps the variables the containers read credentials from to the
+// fields of a.
+func (a *Auth) fields() map[string]*string {
+	return map[string]*string{
+		"CLAUDE_CODE_OAUTH_TOKEN": &a.ClaudeOAuthToken,
+		"ANTHROPIC_API_KEY":       &a.AnthropicAPIKey,
+		"CODEX_AUTH_JSON":         &a.CodexAuthJSON,
+		"OPENAI_API_KEY":          &a.OpenAIAPIKey,
+		"CODEX_API_KEY":           &a.CodexAPIKey,
+		"TUNNEL_TOKEN":            &a.TunnelToken,
+	}
+}
+
+// Refs returns the secret references in a by variable name, or nil when
+// there are none.
+func (a Auth) Refs() map[string]string {
+	var refs map[string]string
+	for env, v := range a.fields() {
+		if IsSecretRef(*v) {
+			if refs == nil {
+				refs = map[string]string{}
+			}
+			refs[env] = *v
+		}
+	}
+	return refs
+}
+
+// WithRefs returns a with the fields named in refs set to their
+// references. Values in refs that aren't references are ignored.
+func (a Auth) WithRefs(refs map[string]string) Auth {
+	fields := a.fields()
+	for env, ref := range refs {
+		if v, ok := fields[env]; ok && IsSecretRef(ref) {
+			*v = ref
+		}
+	}
+	return a
+}
+
+// Map returns a with f applied to every field that is set, stopping at the
+// first error. f is given the variable name of the field.
+func (a Auth) Map(f func(env, value string) (string, error)) (Auth, error) {
+	for env, v := range a.fields() {
+		if *v == "" {
+			continue
+		}
+		out, err := f(env, *v)
+		if err != nil {
+			return Auth{}, err
+		}
+		*v = out
+	}
+	return a, nil
+}
+
 type CreateOptions struct {
-	Name            string   `json:"name"`
-	WorkspacePath   string   `json:"workspace_path"`
-	Provider        Provider `json:"provider"`
-	Image           string   `json:"image,omitempty"`
-	ReadOnlyPort    int      `json:"read_only_port"`
-	InteractivePort int      `json:"interactive_port"`
-	TmuxAccess      string   `json:"tmux_access"` // "none", "read", "write"
-	TTYDCredential  string   `json:"ttyd_credential,omitempty"`
-	FirewallEnable  bool     `json:"firewall_enable"`
-	TunnelEnable    bool     `json:"tunnel_enable"`
-	Auth            Auth     `json:"-"`
+	Name            string            `json:"name"`
+	WorkspacePath   string            `json:"workspace_path"`
+	Provider        Provider          `json:"provider"`
+	Image           string            `json:"image,omitempty"`
+	ReadOnlyPort    int               `json:"read_only_port"`
+	InteractivePort int               `json:"interactive_port"`
+	TmuxAccess      string            `json:"tmux_access"` // "none", "read", "write"
+	TTYDCredential  string            `json:"ttyd_credential,omitempty"`
+	FirewallEnable  bool              `json:"firewall_enable"`
+	TunnelEnable    bool              `json:"tunnel_enable"`
+	Env             map[string]string `json:"env,omitempty"`    // extra vibecontainer environment
+	Mounts          []string          `json:"mounts,omitempty"` // host:container[:ro|rw]
+	// BindAddress is the host address ttyd ports are published on; empty
+	// means LoopbackAddress.
+	BindAddress string `json:"bind_address,omitempty"`
+	// ForcePublicWrite allows interactive access on a public BindAddress.
+	ForcePublicWrite bool `json:"force_public_write,omitempty"`
+	// AuthProfile names the keychain credential profile Auth was loaded
+	// from; empty means the default profile.
+	AuthProfile string `json:"auth_profile,omitempty"`
+	Auth        Auth   `json:"-"`
+}
+
+// LoopbackAddress is where ttyd ports are published unless a bind address
+// is set.
+const LoopbackAddress = "127.0.0.1"
+
+// PublishAddress returns the host address ports are published on for a
+// bind address setting.
+func PublishAddress(bind string) string {
+	if bind == "" {
+		return LoopbackAddress
+	}
+	return bind
+}
+
+// DefaultAuthProfile is the credential profile of stacks that don't name
+// one.
+const DefaultAuthProfile = "default"
+
+// CredentialProfile returns the credential profile an AuthProfile setting
+// selects.
+func CredentialProfile(profile string) string {
+	if profile == "" {
+		return DefaultAuthProfile
+	}
+	return profile
 }
 
+// AutoPort in a port of CreateOptions asks for a free host port, picked
+// from Defaults.PortRange, before the stack is saved.
+const AutoPort = -1
+
+// RunMetadataVersion is the current schema version of run.json. Version 1
+// files predate Spec and are migrated when loaded.
+const RunMetadataVersion = 2
+
 type RunMetadata struct {
+	Version   int       `json:"version"`
 	Name      string    `json:"name"`
 	Workspace string    `json:"workspace"`
 	Provider  Provider  `json:"provider"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	Image     string    `json:"image"`
+	Spec      StackSpec `json:"spec"`
+	// AdoptedFrom names a container taken over by adopt that still has to
+	// be replaced by the stack's own on the next start.
+	AdoptedFrom string `json:"adopted_from,omitempty"`
+}
+
+// StackSpec records every non-secret setting of CreateOptions so a stack can
+// be described and re-rendered without asking for them again.
+type StackSpec struct {
+	WorkspacePath     string            `json:"workspace_path,omitempty"`
+	Provider          Provider          `json:"provider"`
+	Image             string            `json:"image,omitempty"`
+	ReadOnlyPort      int               `json:"read_only_port"`
+	InteractivePort   int               `json:"interactive_port"`
+	TmuxAccess        string            `json:"tmux_access"`
+	TTYDCredentialSet bool              `json:"ttyd_credential_set"`
+	FirewallEnable    bool              `json:"firewall_enable"`
+	TunnelEnable      bool              `json:"tunnel_enable"`
+	Env               map[string]string `json:"env,omitempty"`
+	Mounts            []string          `json:"mounts,omitempty"`
+	BindAddress       string            `json:"bind_address,omitempty"`
+	ForcePublicWrite  bool              `json:"force_public_write,omitempty"`
+	AuthProfile       string            `json:"auth_profile,omitempty"`
+	// AuthRefs are the credentials given as secret references, by
+	// variable name; .env holds what they resolved to.
+	AuthRefs map[string]string `json:"auth_refs,omitempty"`
+}
+
+// SpecFromOptions extracts the non-secret settings of opts.
+func SpecFromOptions(opts CreateOptions) StackSpec {
+	return StackSpec{
+		WorkspacePath:     opts.WorkspacePath,
+		Provider:          opts.Provider,
+		Image:             opts.Image,
+		ReadOnlyPort:      opts.ReadOnlyPort,
+		InteractivePort:   opts.InteractivePort,
+		TmuxAccess:        opts.TmuxAccess,
+		TTYDCredentialSet: opts.TTYDCredential != "",
+		FirewallEnable:    opts.FirewallEnable,
+		TunnelEnable:      opts.TunnelEnable,
+		Env:               opts.Env,
+		Mounts:            opts.Mounts,
+		BindAddress:       opts.BindAddress,
+		ForcePublicWrite:  opts.ForcePublicWrite,
+		AuthProfile:       opts.AuthProfile,
+		AuthRefs:          opts.Auth.Refs(),
+	}
+}
+
+// Options rebuilds CreateOptions for the named stack. Secrets, including the
+// ttyd credential, are left empty; credentials given as references get them
+// back.
+func (s StackSpec) Options(name string) CreateOptions {
+	return CreateOptions{
+		Name:             name,
+		WorkspacePath:    s.WorkspacePath,
+		Provider:         s.Provider,
+		Image:            s.Image,
+		ReadOnlyPort:     s.ReadOnlyPort,
+		InteractivePort:  s.InteractivePort,
+		TmuxAccess:       s.TmuxAccess,
+		FirewallEnable:   s.FirewallEnable,
+		TunnelEnable:     s.TunnelEnable,
+		Env:              s.Env,
+		Mounts:           s.Mounts,
+		BindAddress:      s.BindAddress,
+		ForcePublicWrite: s.ForcePublicWrite,
+		AuthProfile:      s.AuthProfile,
+		Auth:             Auth{}.WithRefs(s.AuthRefs),
+	}
+}
+
+// ReadOnlyURL returns the host URL of the read-only ttyd stream, or "" when
+// tmux is not exposed.
+func (s StackSpec) ReadOnlyURL() string {
+	if s.TmuxAccess != "read" && s.TmuxAccess != "write" {
+		return ""
+	}
+	return s.url(s.ReadOnlyPort)
+}
+
+// InteractiveURL returns the host URL of the interactive ttyd stream, or ""
+// when interactive access is disabled.
+func (s StackSpec) InteractiveURL() string {
+	if s.TmuxAccess != "write" {
+		return ""
+	}
+	return s.url(s.InteractivePort)
+}
+
+// url is the ttyd URL of port. Ports published on all interfaces are
+// reached through loopback.
+func (s StackSpec) url(port int) string {
+	host := PublishAddress(s.BindAddress)
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		host = LoopbackAddress
+	}
+	return "http://" + net.JoinHostPort(host, strconv.Itoa(port))
 }
 
 type Defaults struct {
@@ -58,13 +292,35 @@ type Defaults struct {
 	TmuxAccess      string   `json:"tmux_access"` // "none", "read", "write"
 	FirewallEnable  bool     `json:"firewall_enable"`
 	TunnelEnable    bool     `json:"tunnel_enable"`
+	// Engine is the container engine to use when --engine is not given;
+	// empty means docker.
+	Engine string `json:"engine,omitempty"`
+	// PortRange is the "first-last" range auto ports are picked from.
+	PortRange string `json:"port_range,omitempty"`
+	// BindAddress is the host address new stacks publish ttyd on.
+	BindAddress string `json:"bind_address,omitempty"`
+	// CredentialRefs are secret references, by variable name (e.g.
+	// OPENAI_API_KEY), used for credentials neither flags nor the keychain
+	// provide. Raw secrets don't belong in config.json and are ignored.
+	CredentialRefs map[string]string `json:"credential_refs,omitempty"`
 }
 
 type ServiceStatus struct {
-	Name    string `json:"Name"`
-	State   string `json:"State"`
-	Health  string `json:"Health"`
-	Project string `json:"Project"`
+	Name       string      `json:"Name"`
+	Service    string      `json:"Service"`
+	Image      string      `json:"Image"`
+	State      string      `json:"State"`
+	Health     string      `json:"Health"`
+	Project    string      `json:"Project"`
+	Publishers []Publisher `json:"Publishers"`
+}
+
+// Publisher is a port published by a service, as reported by compose ps.
+type Publisher struct {
+	URL           string `json:"URL"`
+	TargetPort    int    `json:"TargetPort"`
+	PublishedPort int    `json:"PublishedPort"`
+	Protocol      string `json:"Protocol"`
 }
 
 func DefaultDefaults() Defaults {
@@ -75,5 +331,34 @@ func DefaultDefaults() Defaults {
 		TmuxAccess:      "read",
 		FirewallEnable:  true,
 		TunnelEnable:    true,
+		PortRange:       "7681-7780",
 	}
 }
+
+// ContainerInfo is the subset of a container's inspect data needed to
+// describe it as a stack.
+type ContainerInfo struct {
+	Name   string
+	Image  string
+	State  string
+	Env    map[string]string
+	Labels map[string]string
+	Ports  []PortBinding
+	Mounts []Mount
+	CapAdd []string
+}
+
+// PortBinding is a container port published on the host.
+type PortBinding struct {
+	HostIP        string
+	HostPort      int
+	ContainerPort int
+}
+
+// Mount is a volume or bind mount of a container.
+type Mount struct {
+	Type        string
+	Source      string
+	Destination string
+	ReadOnly    bool
+}
@@ -0,0 +1,37 @@
+// Package fsutil holds the file primitives the stores share: atomic writes
+// and advisory locks between vibecontainer processes.
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// WriteFile writes data to path like os.WriteFile, but through a temp file
+// in the same directory that is synced and renamed into place, so readers
+// see either the old content or the new, never a partial file.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp) // no-op once renamed
+
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
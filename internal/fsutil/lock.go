@@ -0,0 +1,77 @@
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrLocked is returned when a lock is still held by another process after
+// the timeout.
+var ErrLocked = errors.New("locked by another process")
+
+// errWouldBlock is returned by tryLock when the lock is held elsewhere.
+var errWouldBlock = errors.New("lock would block")
+
+// lockPoll is how often a contended lock is retried.
+const lockPoll = 100 * time.Millisecond
+
+// Lock is an advisory lock on a file, held until Unlock. Locks are per open
+// file, so a process that locks the same path twice blocks on itself.
+type Lock struct {
+	f *os.File
+}
+
+// LockExclusive locks path for a single holder, creating the file if
+// needed. It waits up to timeout for other holders to let go.
+func LockExclusive(path string, timeout time.Duration) (*Lock, error) {
+	return lock(path, true, timeout)
+}
+
+// LockShared locks path alongside other shared holders; it excludes only
+// exclusive ones.
+func LockShared(path string, timeout time.Duration) (*Lock, error) {
+	return lock(path, false, timeout)
+}
+
+func lock(path string, exclusive bool, timeout time.Duration) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		err := tryLock(f, exclusive)
+		if err == nil {
+			return &Lock{f: f}, nil
+		}
+		if !errors.Is(err, errWouldBlock) || !time.Now().Before(deadline) {
+			f.Close()
+			if errors.Is(err, errWouldBlock) {
+				err = ErrLocked
+			}
+			return nil, fmt.Errorf("lock %s: %w", path, err)
+		}
+		time.Sleep(lockPoll)
+	}
+}
+
+// Unlock releases the lock. The lock file is left in place: removing it
+// would let a process waiting on the old file and one creating a new file
+// both take the lock.
+func (l *Lock) Unlock() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	err := unlock(l.f)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	l.f = nil
+	return err
+}
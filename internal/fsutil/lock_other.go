@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package fsutil
+
+import "os"
+
+// Platforms without file locks run unlocked.
+func tryLock(f *os.File, exclusive bool) error { return nil }
+
+func unlock(f *os.File) error { return nil }
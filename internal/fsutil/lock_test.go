@@ -0,0 +1,64 @@
+package fsutil
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockExclusiveTimesOut(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "locks", "demo.lock")
+	held, err := LockExclusive(path, time.Second)
+	if err != nil {
+		t.Fatalf("LockExclusive failed: %v", err)
+	}
+	if _, err := LockExclusive(path, 200*time.Millisecond); !errors.Is(err, ErrLocked) {
+		t.Fatalf("got %v, want ErrLocked", err)
+	}
+	if _, err := LockShared(path, 0); !errors.Is(err, ErrLocked) {
+		t.Fatalf("got %v, want ErrLocked", err)
+	}
+	if err := held.Unlock(); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	again, err := LockExclusive(path, 0)
+	if err != nil {
+		t.Fatalf("lock after unlock failed: %v", err)
+	}
+	again.Unlock()
+}
+
+func TestLockSharedHolders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "all.lock")
+	a, err := LockShared(path, 0)
+	if err != nil {
+		t.Fatalf("LockShared failed: %v", err)
+	}
+	defer a.Unlock()
+	b, err := LockShared(path, 0)
+	if err != nil {
+		t.Fatalf("second LockShared failed: %v", err)
+	}
+	defer b.Unlock()
+	if _, err := LockExclusive(path, 0); !errors.Is(err, ErrLocked) {
+		t.Fatalf("got %v, want ErrLocked", err)
+	}
+}
+
+func TestLockWaitsForRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "demo.lock")
+	held, err := LockExclusive(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		held.Unlock()
+	}()
+	l, err := LockExclusive(path, 5*time.Second)
+	if err != nil {
+		t.Fatalf("waiting lock failed: %v", err)
+	}
+	l.Unlock()
+}
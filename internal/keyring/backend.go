@@ -0,0 +1,126 @@
+package keyring
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/openhoo/vibecontainer/internal/config"
+	"github.com/zalando/go-keyring"
+)
+
+// ErrNotFound is returned by a Backend for a key that isn't stored.
+var ErrNotFound = keyring.ErrNotFound
+
+// Backend stores secrets by service and key.
+type Backend interface {
+	Get(service, key string) (string, error)
+	Set(service, key, value string) error
+	Delete(service, key string) error
+	// Name describes where secrets are kept, for messages.
+	Name() string
+}
+
+// Environment variables that choose and unlock the secret backend.
+const (
+	// BackendEnv selects the backend: "system", "file", or empty to use
+	// the system keychain when it is reachable and the file otherwise.
+	BackendEnv = "VIBECONTAINER_SECRET_BACKEND"
+	// PassphraseEnv unlocks the encrypted file.
+	PassphraseEnv = "VIBECONTAINER_SECRETS_PASSPHRASE"
+	// KeyFileEnv names a file whose contents unlock the encrypted file.
+	KeyFileEnv = "VIBECONTAINER_SECRETS_KEY_FILE"
+)
+
+// systemBackend is the OS keychain: macOS Keychain, Windows Credential
+// Manager or the Secret Service on Linux.
+type systemBackend struct{}
+
+func (systemBackend) Get(service, key string) (string, error) { return keyring.Get(service, key) }
+func (systemBackend) Set(service, key, value string) error    { return keyring.Set(service, key, value) }
+func (systemBackend) Delete(service, key string) error        { return keyring.Delete(service, key) }
+func (systemBackend) Name() string                            { return "system keychain" }
+
+// Detection records the backend DefaultBackend picked.
+type Detection struct {
+	Backend Backend
+	// Fallback is why the system keychain isn't used when BackendEnv left
+	// the choice to detection.
+	Fallback string
+}
+
+// Notices receives the message printed when detection falls back to the
+// encrypted file.
+var Notices io.Writer = os.Stderr
+
+var (
+	detectOnce sync.Once
+	detected   Detection
+)
+
+// DefaultBackend returns the backend New stores use, detecting it on first
+// use.
+func DefaultBackend() Detection {
+	detectOnce.Do(func() {
+		detected = Detect(systemBackend{}, NewFileBackend(config.SecretsPath(), EnvUnlock))
+		if detected.Fallback != "" {
+			fmt.Fprintf(Notices, "Note: %s\n", FallbackMessage(detected))
+		}
+	})
+	return detected
+}
+
+// Detect picks between the system keychain and the encrypted file. An
+// existing file that can be unlocked is kept in use; otherwise the system
+// keychain is used when it answers.
+func Detect(system Backend, file *FileBackend) Detection {
+	switch os.Getenv(BackendEnv) {
+	case "system":
+		return Detection{Backend: system}
+	case "file":
+		return Detection{Backend: file}
+	}
+	if file.Exists() && file.Unlockable() {
+		return Detection{Backend: file}
+	}
+	_, err := system.Get(serviceName, probeKey)
+	if err == nil || errors.Is(err, ErrNotFound) {
+		return Detection{Backend: system}
+	}
+	return Detection{Backend: file, Fallback: err.Error()}
+}
+
+// FallbackMessage explains a fallback to the encrypted file and, when it
+// can't be unlocked, how to unlock it.
+func FallbackMessage(d Detection) string {
+	msg := fmt.Sprintf("no system keychain (%s); credentials are kept in %s", d.Fallback, d.Backend.Name())
+	if f, ok := d.Backend.(*FileBackend); ok && !f.Unlockable() {
+		msg += fmt.Sprintf(", which needs $%s or $%s to be set", PassphraseEnv, KeyFileEnv)
+	}
+	return msg
+}
+
+// EnvUnlock returns the secret that unlocks the encrypted file, from
+// PassphraseEnv or the file named by KeyFileEnv.
+func EnvUnlock() ([]byte, error) {
+	if p := os.Getenv(PassphraseEnv); p != "" {
+		return []byte(p), nil
+	}
+	if path := os.Getenv(KeyFileEnv); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read key file: %w", err)
+		}
+		if len(b) == 0 {
+			return nil, fmt.Errorf("key file %s is empty", path)
+		}
+		return b, nil
+	}
+	return nil, ErrNoUnlock
+}
+
+// ErrNoUnlock is returned by EnvUnlock when neither PassphraseEnv nor
+// KeyFileEnv is set.
+var ErrNoUnlock = fmt.Errorf("the encrypted credential file is locked; set $%s or $%s", PassphraseEnv, KeyFileEnv)
@@ -0,0 +1,224 @@
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/openhoo/vibecontainer/internal/fsutil"
+)
+
+// fileVersion is the schema version of the encrypted file.
+const fileVersion = 1
+
+// defaultIterations is the PBKDF2-SHA256 work factor for new files.
+const defaultIterations = 600_000
+
+// minIterations and maxIterations bound the work factor read from a file,
+// so a damaged one neither weakens the key nor stalls every command.
+const (
+	minIterations = 100_000
+	maxIterations = 10_000_000
+)
+
+// fileLockTimeout bounds the wait for another process writing the file.
+const fileLockTimeout = 10 * time.Second
+
+// FileBackend keeps secrets in a single file, encrypted with AES-256-GCM
+// under a key derived from a passphrase with PBKDF2-SHA256.
+type FileBackend struct {
+	path   string
+	unlock func() ([]byte, error)
+	// iterations is the work factor used when the file is first written.
+	iterations int
+
+	mu sync.Mutex
+	// salt, keyIterations and key cache the last derived key; deriving is
+	// slow on purpose.
+	salt          []byte
+	keyIterations int
+	key           []byte
+}
+
+// encryptedFile is the on-disk layout. The plaintext is the JSON of a
+// service -> key -> value map.
+type encryptedFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// NewFileBackend returns a backend for the file at path, unlocked with the
+// secret unlock returns.
+func NewFileBackend(path string, unlock func() ([]byte, error)) *FileBackend {
+	return &FileBackend{path: path, unlock: unlock, iterations: defaultIterations}
+}
+
+func (f *FileBackend) Name() string { return "encrypted file " + f.path }
+
+// Exists reports whether the file has been written.
+func (f *FileBackend) Exists() bool {
+	_, err := os.Stat(f.path)
+	return err == nil
+}
+
+// Unlockable reports whether a passphrase or key file is configured.
+func (f *FileBackend) Unlockable() bool {
+	_, err := f.unlock()
+	return err == nil
+}
+
+func (f *FileBackend) Get(service, key string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	secrets, _, err := f.read()
+	if err != nil {
+		return "", err
+	}
+	val, ok := secrets[service][key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return val, nil
+}
+
+func (f *FileBackend) Set(service, key, value string) error {
+	return f.update(func(secrets map[string]map[string]string) error {
+		if secrets[service] == nil {
+			secrets[service] = map[string]string{}
+		}
+		secrets[service][key] = value
+		return nil
+	})
+}
+
+func (f *FileBackend) Delete(service, key string) error {
+	return f.update(func(secrets map[string]map[string]string) error {
+		if _, ok := secrets[service][key]; !ok {
+			return ErrNotFound
+		}
+		delete(secrets[service], key)
+		if len(secrets[service]) == 0 {
+			delete(secrets, service)
+		}
+		return nil
+	})
+}
+
+// update rewrites the file with the secrets change returns, holding a lock
+// so concurrent commands don't lose each other's writes.
+func (f *FileBackend) update(change func(map[string]map[string]string) error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	lock, err := fsutil.LockExclusive(f.path+".lock", fileLockTimeout)
+	if err != nil {
+		return fmt.Errorf("lock %s: %w", f.path, err)
+	}
+	defer lock.Unlock()
+
+	secrets, header, err := f.read()
+	if err != nil {
+		return err
+	}
+	if err := change(secrets); err != nil {
+		return err
+	}
+	return f.write(secrets, header)
+}
+
+// read decrypts the file. A missing file reads as empty, with a fresh
+// header for the first write.
+func (f *FileBackend) read() (map[string]map[string]string, encryptedFile, error) {
+	secrets := map[string]map[string]string{}
+	b, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, encryptedFile{}, err
+		}
+		return secrets, encryptedFile{Version: fileVersion, KDF: "pbkdf2-sha256", Iterations: f.iterations, Salt: salt}, nil
+	}
+	if err != nil {
+		return nil, encryptedFile{}, err
+	}
+	var header encryptedFile
+	if err := json.Unmarshal(b, &header); err != nil {
+		return nil, encryptedFile{}, fmt.Errorf("parse %s: %w", f.path, err)
+	}
+	if header.Version != fileVersion || header.KDF != "pbkdf2-sha256" {
+		return nil, encryptedFile{}, fmt.Errorf("%s has unsupported version %d (%s)", f.path, header.Version, header.KDF)
+	}
+	if header.Iterations < minIterations || header.Iterations > maxIterations {
+		return nil, encryptedFile{}, fmt.Errorf("%s has %d key derivation iterations, outside %d to %d; the file is damaged", f.path, header.Iterations, minIterations, maxIterations)
+	}
+	aead, err := f.cipher(header)
+	if err != nil {
+		return nil, encryptedFile{}, err
+	}
+	plain, err := aead.Open(nil, header.Nonce, header.Ciphertext, nil)
+	if err != nil {
+		return nil, encryptedFile{}, fmt.Errorf("decrypt %s: wrong passphrase or key file, or the file is damaged", f.path)
+	}
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, encryptedFile{}, fmt.Errorf("parse %s: %w", f.path, err)
+	}
+	return secrets, header, nil
+}
+
+// write encrypts secrets with a new nonce and replaces the file.
+func (f *FileBackend) write(secrets map[string]map[string]string, header encryptedFile) error {
+	aead, err := f.cipher(header)
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	header.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(header.Nonce); err != nil {
+		return err
+	}
+	header.Ciphertext = aead.Seal(nil, header.Nonce, plain, nil)
+	b, err := json.MarshalIndent(header, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o700); err != nil {
+		return err
+	}
+	return fsutil.WriteFile(f.path, append(b, '\n'), 0o600)
+}
+
+// cipher derives the file key for header's salt and iterations, reusing
+// the cached one.
+func (f *FileBackend) cipher(header encryptedFile) (cipher.AEAD, error) {
+	if f.key == nil || string(f.salt) != string(header.Salt) || f.keyIterations != header.Iterations {
+		secret, err := f.unlock()
+		if err != nil {
+			return nil, err
+		}
+		key, err := pbkdf2.Key(sha256.New, string(secret), header.Salt, header.Iterations, 32)
+		if err != nil {
+			return nil, fmt.Errorf("derive key: %w", err)
+		}
+		f.salt, f.keyIterations, f.key = header.Salt, header.Iterations, key
+	}
+	block, err := aes.NewCipher(f.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
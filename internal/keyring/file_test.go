@@ -0,0 +1,162 @@
+package keyring
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openhoo/vibecontainer/internal/domain"
+)
+
+func newTestFileBackend(t *testing.T, passphrase string) *FileBackend {
+	t.Helper()
+	f := NewFileBackend(filepath.Join(t.TempDir(), "secrets.enc"), func() ([]byte, error) { return []byte(passphrase), nil })
+	f.iterations = minIterations
+	return f
+}
+
+func TestFileBackendRoundTrip(t *testing.T) {
+	f := newTestFileBackend(t, "hunter2")
+	if _, err := f.Get("svc", "k"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get on a missing file: got %v, want ErrNotFound", err)
+	}
+	if err := f.Set("svc", "k", "s3cret-value"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	b, err := os.ReadFile(f.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "s3cret-value") {
+		t.Fatal("the file holds the secret in plain text")
+	}
+	if info, _ := os.Stat(f.path); info.Mode().Perm() != 0o600 {
+		t.Errorf("file mode %v, want 0600", info.Mode().Perm())
+	}
+
+	reopened := NewFileBackend(f.path, func() ([]byte, error) { return []byte("hunter2"), nil })
+	if got, err := reopened.Get("svc", "k"); err != nil || got != "s3cret-value" {
+		t.Fatalf("Get after reopen: got %q, %v", got, err)
+	}
+	wrong := NewFileBackend(f.path, func() ([]byte, error) { return []byte("nope"), nil })
+	if _, err := wrong.Get("svc", "k"); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Fatalf("wrong passphrase: got %v", err)
+	}
+
+	if err := f.Delete("svc", "k"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := f.Delete("svc", "k"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Delete of a missing key: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestFileBackendRejectsBadIterations(t *testing.T) {
+	f := newTestFileBackend(t, "pw")
+	if err := f.Set("svc", "k", "v"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(f.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{0, maxIterations + 1} {
+		var header encryptedFile
+		if err := json.Unmarshal(b, &header); err != nil {
+			t.Fatal(err)
+		}
+		header.Iterations = n
+		damaged, _ := json.Marshal(header)
+		if err := os.WriteFile(f.path, damaged, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		reopened := NewFileBackend(f.path, func() ([]byte, error) { return []byte("pw"), nil })
+		if _, err := reopened.Get("svc", "k"); err == nil || !strings.Contains(err.Error(), "iterations") {
+			t.Errorf("iterations %d: got %v", n, err)
+		}
+	}
+}
+
+func TestFileBackendLocked(t *testing.T) {
+	f := NewFileBackend(filepath.Join(t.TempDir(), "secrets.enc"), func() ([]byte, error) { return nil, ErrNoUnlock })
+	if f.Unlockable() {
+		t.Fatal("expected the backend to be locked")
+	}
+	if err := f.Set("svc", "k", "v"); !errors.Is(err, ErrNoUnlock) {
+		t.Fatalf("Set without a passphrase: got %v, want ErrNoUnlock", err)
+	}
+}
+
+func TestStoreOnFileBackend(t *testing.T) {
+	store := &Store{service: "vibecontainer-test", profile: "work", backend: newTestFileBackend(t, "pw")}
+	if err := store.SaveAuth(domain.Auth{OpenAIAPIKey: "sk-file"}); err != nil {
+		t.Fatalf("SaveAuth failed: %v", err)
+	}
+	if got := store.LoadAuth().OpenAIAPIKey; got != "sk-file" {
+		t.Fatalf("LoadAuth: got %q", got)
+	}
+	if ok, err := store.HasProfile("work"); err != nil || !ok {
+		t.Fatalf("HasProfile: got %v, %v", ok, err)
+	}
+	if err := store.Probe(); err != nil {
+		t.Fatalf("Probe failed: %v", err)
+	}
+}
+
+func TestEnvUnlock(t *testing.T) {
+	t.Setenv(PassphraseEnv, "")
+	t.Setenv(KeyFileEnv, "")
+	if _, err := EnvUnlock(); !errors.Is(err, ErrNoUnlock) {
+		t.Fatalf("got %v, want ErrNoUnlock", err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(keyFile, []byte("from-file"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(KeyFileEnv, keyFile)
+	if got, err := EnvUnlock(); err != nil || string(got) != "from-file" {
+		t.Fatalf("key file: got %q, %v", got, err)
+	}
+	t.Setenv(PassphraseEnv, "from-env")
+	if got, err := EnvUnlock(); err != nil || string(got) != "from-env" {
+		t.Fatalf("passphrase: got %q, %v", got, err)
+	}
+}
+
+// brokenBackend is a system keychain that can't be reached.
+type brokenBackend struct{ systemBackend }
+
+func (brokenBackend) Get(service, key string) (string, error) {
+	return "", errors.New("no secret service")
+}
+
+func TestDetect(t *testing.T) {
+	t.Setenv(BackendEnv, "")
+	file := newTestFileBackend(t, "pw")
+
+	if d := Detect(systemBackend{}, file); d.Backend != (systemBackend{}) || d.Fallback != "" {
+		t.Fatalf("reachable keychain: got %+v", d)
+	}
+	d := Detect(brokenBackend{}, file)
+	if d.Backend != file || d.Fallback != "no secret service" {
+		t.Fatalf("unreachable keychain: got %+v", d)
+	}
+	if msg := FallbackMessage(d); !strings.Contains(msg, file.path) {
+		t.Errorf("fallback message should name the file: %s", msg)
+	}
+
+	if err := file.Set("svc", "k", "v"); err != nil {
+		t.Fatal(err)
+	}
+	if d := Detect(systemBackend{}, file); d.Backend != file {
+		t.Fatalf("an existing file should stay in use, got %+v", d)
+	}
+	t.Setenv(BackendEnv, "system")
+	if d := Detect(systemBackend{}, file); d.Backend != (systemBackend{}) {
+		t.Fatalf("%s=system: got %+v", BackendEnv, d)
+	}
+}
@@ -1,10 +1,14 @@
 package keyring
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/openhoo/vibecontainer/internal/domain"
-	"github.com/zalando/go-keyring"
 )
 
 const serviceName = "vibecontainer"
@@ -19,110 +23,375 @@ const (
 	KeyTunnelToken      = "tunnel_token"
 )
 
+// Credential describes a credential that can be stored in a profile.
+type Credential struct {
+	// Key is the name it is stored under.
+	Key string
+	// Title names it in messages.
+	Title string
+	// Env is the variable the stack's containers read it from.
+	Env string
+}
+
+// Credentials lists the credentials stored per profile.
+var Credentials = []Credential{
+	{Key: KeyClaudeOAuthToken, Title: "Claude OAuth Token", Env: "CLAUDE_CODE_OAUTH_TOKEN"},
+	{Key: KeyAnthropicAPIKey, Title: "Anthropic API Key", Env: "ANTHROPIC_API_KEY"},
+	{Key: KeyCodexAuthJSON, Title: "Codex Auth JSON", Env: "CODEX_AUTH_JSON"},
+	{Key: KeyOpenAIAPIKey, Title: "OpenAI API Key", Env: "OPENAI_API_KEY"},
+	{Key: KeyCodexAPIKey, Title: "Codex API Key", Env: "CODEX_API_KEY"},
+	{Key: KeyTunnelToken, Title: "Tunnel Token", Env: "TUNNEL_TOKEN"},
+}
+
+// LookupCredential finds a credential by its key or environment variable
+// name.
+func LookupCredential(name string) (Credential, bool) {
+	for _, c := range Credentials {
+		if name == c.Key || name == c.Env {
+			return c, true
+		}
+	}
+	return Credential{}, false
+}
+
+// authKeys lists the keys of Credentials.
+var authKeys = func() []string {
+	keys := make([]string, len(Credentials))
+	for i, c := range Credentials {
+		keys[i] = c.Key
+	}
+	return keys
+}()
+
+// DefaultProfile is the credential profile used when none is named.
+// Credentials saved before profiles existed are moved to it.
+const DefaultProfile = domain.DefaultAuthProfile
+
+// profilePrefix namespaces the credential keys of each profile, e.g.
+// "profile:work:claude_oauth_token".
+const profilePrefix = "profile:"
+
+// profilesKey holds the newline-separated names of the profiles that have
+// saved credentials, since the keychain can't list its entries.
+const profilesKey = "profiles"
+
+// ttydCredentialPrefix namespaces the ttyd credential of each stack.
+const ttydCredentialPrefix = "ttyd_credential:"
+
+// TTYDCredentialKey is the key the ttyd credential of stack is stored under.
+func TTYDCredentialKey(stack string) string {
+	return ttydCredentialPrefix + stack
+}
+
 // Store provides secure credential storage using the system keychain
 type Store struct {
 	service string
+	profile string
+	// backend holds the secrets; nil means the system keychain.
+	backend Backend
+	// migrated is set once migrate has run.
+	migrated bool
 }
 
-// New creates a new keyring store
+// New creates a new keyring store for the default profile
 func New() *Store {
-	return &Store{service: serviceName}
+	return NewProfile(DefaultProfile)
+}
+
+// NewProfile creates a keyring store for the credentials of profile.
+func NewProfile(profile string) *Store {
+	return &Store{service: serviceName, profile: profile, backend: DefaultBackend().Backend}
+}
+
+// secrets returns the backend s reads and writes.
+func (s *Store) secrets() Backend {
+	if s.backend == nil {
+		return systemBackend{}
+	}
+	return s.backend
+}
+
+// Profile returns the name of the profile s reads and writes.
+func (s *Store) Profile() string {
+	return domain.CredentialProfile(s.profile)
+}
+
+// key returns the keychain entry of a credential key in s's profile.
+func (s *Store) key(name string) string {
+	return profilePrefix + s.Profile() + ":" + name
 }
 
 // SaveAuth saves all non-empty auth credentials to the keyring
 func (s *Store) SaveAuth(auth domain.Auth) error {
 	if auth.ClaudeOAuthToken != "" {
-		if err := keyring.Set(s.service, KeyClaudeOAuthToken, auth.ClaudeOAuthToken); err != nil {
+		if err := s.secrets().Set(s.service, s.key(KeyClaudeOAuthToken), auth.ClaudeOAuthToken); err != nil {
 			return fmt.Errorf("save claude oauth token: %w", err)
 		}
 	}
 	if auth.AnthropicAPIKey != "" {
-		if err := keyring.Set(s.service, KeyAnthropicAPIKey, auth.AnthropicAPIKey); err != nil {
+		if err := s.secrets().Set(s.service, s.key(KeyAnthropicAPIKey), auth.AnthropicAPIKey); err != nil {
 			return fmt.Errorf("save anthropic api key: %w", err)
 		}
 	}
 	if auth.CodexAuthJSON != "" {
-		if err := keyring.Set(s.service, KeyCodexAuthJSON, auth.CodexAuthJSON); err != nil {
+		if err := s.secrets().Set(s.service, s.key(KeyCodexAuthJSON), auth.CodexAuthJSON); err != nil {
 			return fmt.Errorf("save codex auth json: %w", err)
 		}
 	}
 	if auth.OpenAIAPIKey != "" {
-		if err := keyring.Set(s.service, KeyOpenAIAPIKey, auth.OpenAIAPIKey); err != nil {
+		if err := s.secrets().Set(s.service, s.key(KeyOpenAIAPIKey), auth.OpenAIAPIKey); err != nil {
 			return fmt.Errorf("save openai api key: %w", err)
 		}
 	}
 	if auth.CodexAPIKey != "" {
-		if err := keyring.Set(s.service, KeyCodexAPIKey, auth.CodexAPIKey); err != nil {
+		if err := s.secrets().Set(s.service, s.key(KeyCodexAPIKey), auth.CodexAPIKey); err != nil {
 			return fmt.Errorf("save codex api key: %w", err)
 		}
 	}
 	if auth.TunnelToken != "" {
-		if err := keyring.Set(s.service, KeyTunnelToken, auth.TunnelToken); err != nil {
+		if err := s.secrets().Set(s.service, s.key(KeyTunnelToken), auth.TunnelToken); err != nil {
 			return fmt.Errorf("save tunnel token: %w", err)
 		}
 	}
+	if auth != (domain.Auth{}) {
+		if err := s.addProfile(); err != nil {
+			return fmt.Errorf("record profile %s: %w", s.Profile(), err)
+		}
+	}
 	return nil
 }
 
 // LoadAuth loads auth credentials from the keyring
 // Returns a partially filled Auth struct with whatever credentials are available
 func (s *Store) LoadAuth() domain.Auth {
+	s.migrate()
 	auth := domain.Auth{}
 
 	// Try to load each credential, but don't fail if any are missing
-	if val, err := keyring.Get(s.service, KeyClaudeOAuthToken); err == nil {
+	if val, err := s.secrets().Get(s.service, s.key(KeyClaudeOAuthToken)); err == nil {
 		auth.ClaudeOAuthToken = val
 	}
-	if val, err := keyring.Get(s.service, KeyAnthropicAPIKey); err == nil {
+	if val, err := s.secrets().Get(s.service, s.key(KeyAnthropicAPIKey)); err == nil {
 		auth.AnthropicAPIKey = val
 	}
-	if val, err := keyring.Get(s.service, KeyCodexAuthJSON); err == nil {
+	if val, err := s.secrets().Get(s.service, s.key(KeyCodexAuthJSON)); err == nil {
 		auth.CodexAuthJSON = val
 	}
-	if val, err := keyring.Get(s.service, KeyOpenAIAPIKey); err == nil {
+	if val, err := s.secrets().Get(s.service, s.key(KeyOpenAIAPIKey)); err == nil {
 		auth.OpenAIAPIKey = val
 	}
-	if val, err := keyring.Get(s.service, KeyCodexAPIKey); err == nil {
+	if val, err := s.secrets().Get(s.service, s.key(KeyCodexAPIKey)); err == nil {
 		auth.CodexAPIKey = val
 	}
-	if val, err := keyring.Get(s.service, KeyTunnelToken); err == nil {
+	if val, err := s.secrets().Get(s.service, s.key(KeyTunnelToken)); err == nil {
 		auth.TunnelToken = val
 	}
 
 	return auth
 }
 
-// Get retrieves a single credential from the keyring
+// Get retrieves a single credential of the profile from the keyring
 func (s *Store) Get(key string) (string, error) {
-	return keyring.Get(s.service, key)
+	s.migrate()
+	return s.secrets().Get(s.service, s.key(key))
 }
 
-// Set stores a single credential in the keyring
+// Set stores a single credential of the profile in the keyring
 func (s *Store) Set(key, value string) error {
-	return keyring.Set(s.service, key, value)
+	if err := s.secrets().Set(s.service, s.key(key), value); err != nil {
+		return err
+	}
+	return s.addProfile()
 }
 
-// Delete removes a single credential from the keyring
+// Delete removes a single credential of the profile from the keyring
 func (s *Store) Delete(key string) error {
-	return keyring.Delete(s.service, key)
+	s.migrate()
+	return s.secrets().Delete(s.service, s.key(key))
+}
+
+// migrate moves credentials saved under the global keys used before
+// profiles existed to the default profile, once per Store. A credential
+// already saved in the profile wins over the old one.
+func (s *Store) migrate() {
+	if s.migrated || s.Profile() != DefaultProfile {
+		return
+	}
+	s.migrated = true
+	for _, key := range authKeys {
+		val, err := s.secrets().Get(s.service, key)
+		if err != nil {
+			continue
+		}
+		if _, err := s.secrets().Get(s.service, s.key(key)); errors.Is(err, ErrNotFound) {
+			if s.secrets().Set(s.service, s.key(key), val) != nil {
+				continue
+			}
+		}
+		_ = s.secrets().Delete(s.service, key)
+	}
+}
+
+// Profiles returns the names of the profiles with saved credentials, the
+// default profile first and the others sorted.
+func (s *Store) Profiles() ([]string, error) {
+	names, err := s.profiles()
+	if err != nil {
+		return nil, err
+	}
+	out := []string{DefaultProfile}
+	for _, name := range names {
+		if name != DefaultProfile {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out[1:])
+	return out, nil
+}
+
+// HasProfile reports whether profile has saved credentials. The default
+// profile always exists.
+func (s *Store) HasProfile(profile string) (bool, error) {
+	names, err := s.Profiles()
+	if err != nil {
+		return false, err
+	}
+	for _, name := range names {
+		if name == profile {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) profiles() ([]string, error) {
+	val, err := s.secrets().Get(s.service, profilesKey)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(val), nil
+}
+
+// addProfile records s's profile in the profile list.
+func (s *Store) addProfile() error {
+	names, err := s.profiles()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if name == s.Profile() {
+			return nil
+		}
+	}
+	return s.secrets().Set(s.service, profilesKey, strings.Join(append(names, s.Profile()), "\n"))
+}
+
+// removeProfile drops s's profile from the profile list.
+func (s *Store) removeProfile() error {
+	names, err := s.profiles()
+	if err != nil {
+		return err
+	}
+	kept := names[:0]
+	for _, name := range names {
+		if name != s.Profile() {
+			kept = append(kept, name)
+		}
+	}
+	if len(kept) == len(names) {
+		return nil
+	}
+	if len(kept) == 0 {
+		err := s.secrets().Delete(s.service, profilesKey)
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	return s.secrets().Set(s.service, profilesKey, strings.Join(kept, "\n"))
+}
+
+// SaveTTYDCredential stores the user:password ttyd asks for on stack.
+func (s *Store) SaveTTYDCredential(stack, credential string) error {
+	if err := s.secrets().Set(s.service, TTYDCredentialKey(stack), credential); err != nil {
+		return fmt.Errorf("save ttyd credential of %s: %w", stack, err)
+	}
+	return nil
+}
+
+// LoadTTYDCredential returns the stored ttyd credential of stack, or
+// ErrNotFound.
+func (s *Store) LoadTTYDCredential(stack string) (string, error) {
+	return s.secrets().Get(s.service, TTYDCredentialKey(stack))
+}
+
+// DeleteTTYDCredential forgets the ttyd credential of stack. A stack without
+// one is not an error.
+func (s *Store) DeleteTTYDCredential(stack string) error {
+	err := s.secrets().Delete(s.service, TTYDCredentialKey(stack))
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// ttydUser is the user name of generated ttyd credentials.
+const ttydUser = "vibe"
+
+// GenerateTTYDCredential returns a new user:password for ttyd with a 192-bit
+// random password.
+func GenerateTTYDCredential() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate ttyd credential: %w", err)
+	}
+	return ttydUser + ":" + base64.RawURLEncoding.EncodeToString(b), nil
 }
 
-// Clear removes all stored credentials from the keyring
+// probeKey is written and removed again by Probe.
+const probeKey = "probe"
+
+// Probe checks that the keychain backend can store, read and delete a value.
+func (s *Store) Probe() error {
+	const value = "ok"
+	if err := s.secrets().Set(s.service, probeKey, value); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	got, err := s.secrets().Get(s.service, probeKey)
+	if err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+	if err := s.secrets().Delete(s.service, probeKey); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+	if got != value {
+		return fmt.Errorf("read back %q, want %q", got, value)
+	}
+	return nil
+}
+
+// Clear removes all stored credentials of the profile from the keyring
 func (s *Store) Clear() error {
-	keys := []string{
-		KeyClaudeOAuthToken,
-		KeyAnthropicAPIKey,
-		KeyCodexAuthJSON,
-		KeyOpenAIAPIKey,
-		KeyCodexAPIKey,
-		KeyTunnelToken,
+	keys := make([]string, 0, 2*len(authKeys))
+	for _, key := range authKeys {
+		keys = append(keys, s.key(key))
+	}
+	if s.Profile() == DefaultProfile {
+		// Credentials saved before profiles existed.
+		keys = append(keys, authKeys...)
 	}
 
 	var firstErr error
 	for _, key := range keys {
-		if err := keyring.Delete(s.service, key); err != nil && err != keyring.ErrNotFound && firstErr == nil {
+		if err := s.secrets().Delete(s.service, key); err != nil && !errors.Is(err, ErrNotFound) && firstErr == nil {
 			firstErr = err
 		}
 	}
+	if err := s.removeProfile(); err != nil && firstErr == nil {
+		firstErr = err
+	}
 	return firstErr
 }
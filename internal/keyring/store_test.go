@@ -2,6 +2,7 @@ package keyring
 
 import (
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/openhoo/vibecontainer/internal/domain"
@@ -152,3 +153,106 @@ func TestStoreClear(t *testing.T) {
 		t.Errorf("Clear did not remove all credentials: %+v", loaded)
 	}
 }
+
+func TestStoreProbe(t *testing.T) {
+	store := &Store{service: "vibecontainer-test"}
+	if err := store.Probe(); err != nil {
+		t.Fatalf("Probe failed: %v", err)
+	}
+	if _, err := store.Get(probeKey); err != keyring.ErrNotFound {
+		t.Errorf("Probe should clean up its key, got %v", err)
+	}
+}
+
+func TestStoreTTYDCredential(t *testing.T) {
+	store := &Store{service: "vibecontainer-test"}
+
+	cred, err := GenerateTTYDCredential()
+	if err != nil {
+		t.Fatalf("GenerateTTYDCredential failed: %v", err)
+	}
+	user, password, ok := strings.Cut(cred, ":")
+	if !ok || user == "" || len(password) < 32 || strings.ContainsAny(password, ": \t\n") {
+		t.Fatalf("unexpected credential %q", cred)
+	}
+	if other, _ := GenerateTTYDCredential(); other == cred {
+		t.Fatal("expected a fresh credential on every call")
+	}
+
+	if err := store.SaveTTYDCredential("demo", cred); err != nil {
+		t.Fatalf("SaveTTYDCredential failed: %v", err)
+	}
+	if _, err := store.LoadTTYDCredential("other"); err != keyring.ErrNotFound {
+		t.Fatalf("credentials must be per stack, got %v", err)
+	}
+	got, err := store.LoadTTYDCredential("demo")
+	if err != nil || got != cred {
+		t.Fatalf("LoadTTYDCredential: got %q, %v", got, err)
+	}
+	if err := store.DeleteTTYDCredential("demo"); err != nil {
+		t.Fatalf("DeleteTTYDCredential failed: %v", err)
+	}
+	if err := store.DeleteTTYDCredential("demo"); err != nil {
+		t.Fatalf("deleting a missing credential should succeed, got %v", err)
+	}
+}
+
+func TestStoreProfiles(t *testing.T) {
+	work := &Store{service: "vibecontainer-test", profile: "work"}
+	personal := &Store{service: "vibecontainer-test"}
+	defer func() {
+		_ = work.Clear()
+		_ = personal.Clear()
+	}()
+
+	if err := work.SaveAuth(domain.Auth{AnthropicAPIKey: "work-key"}); err != nil {
+		t.Fatalf("SaveAuth failed: %v", err)
+	}
+	if err := personal.SaveAuth(domain.Auth{AnthropicAPIKey: "personal-key"}); err != nil {
+		t.Fatalf("SaveAuth failed: %v", err)
+	}
+	if got := work.LoadAuth().AnthropicAPIKey; got != "work-key" {
+		t.Errorf("work profile: got %q", got)
+	}
+	if got := personal.LoadAuth().AnthropicAPIKey; got != "personal-key" {
+		t.Errorf("default profile: got %q", got)
+	}
+
+	profiles, err := work.Profiles()
+	if err != nil {
+		t.Fatalf("Profiles failed: %v", err)
+	}
+	if strings.Join(profiles, ",") != "default,work" {
+		t.Errorf("unexpected profiles %v", profiles)
+	}
+
+	if err := work.Clear(); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if got := personal.LoadAuth().AnthropicAPIKey; got != "personal-key" {
+		t.Errorf("clearing a profile must keep the others, got %q", got)
+	}
+	if ok, _ := personal.HasProfile("work"); ok {
+		t.Error("cleared profile is still listed")
+	}
+}
+
+func TestStoreMigratesLegacyKeys(t *testing.T) {
+	store := &Store{service: "vibecontainer-test"}
+	defer func() {
+		_ = store.Clear()
+	}()
+
+	if err := keyring.Set(store.service, KeyOpenAIAPIKey, "legacy-key"); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.LoadAuth().OpenAIAPIKey; got != "legacy-key" {
+		t.Fatalf("legacy key not migrated, got %q", got)
+	}
+	if _, err := keyring.Get(store.service, KeyOpenAIAPIKey); err != keyring.ErrNotFound {
+		t.Errorf("legacy key should be removed after migration, got %v", err)
+	}
+	if got, err := store.Get(KeyOpenAIAPIKey); err != nil || got != "legacy-key" {
+		t.Errorf("migrated key: got %q, %v", got, err)
+	}
+}
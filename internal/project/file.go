@@ -0,0 +1,216 @@
+// Package project reads vibecontainer.yaml, the per-repository stack spec
+// used by `vibecontainer up` and `vibecontainer down`.
+package project
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/openhoo/vibecontainer/internal/domain"
+	"github.com/openhoo/vibecontainer/internal/stack"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name `up` looks for in the current directory.
+const FileName = "vibecontainer.yaml"
+
+// Version is the only schema version this release understands.
+const Version = 1
+
+// File is the schema of vibecontainer.yaml. Unset fields fall back to the
+// user's defaults. Credentials are never read from the file.
+type File struct {
+	Version  int             `yaml:"version"`
+	Name     string          `yaml:"name,omitempty"`
+	Provider domain.Provider `yaml:"provider,omitempty"`
+	Image    string          `yaml:"image,omitempty"`
+	// Workspace is mounted at /workspace. It defaults to the directory that
+	// holds the file; an empty string disables the mount.
+	Workspace *string           `yaml:"workspace,omitempty"`
+	Tmux      Tmux              `yaml:"tmux,omitempty"`
+	Firewall  *bool             `yaml:"firewall,omitempty"`
+	Tunnel    *bool             `yaml:"tunnel,omitempty"`
+	Env       map[string]string `yaml:"env,omitempty"`
+	// Mounts are host:container[:ro|rw] bind mounts. Relative and ~ host
+	// paths resolve against the file's directory and the home directory.
+	Mounts []string `yaml:"mounts,omitempty"`
+	// AuthProfile names the keychain credential profile the stack uses.
+	AuthProfile string `yaml:"auth_profile,omitempty"`
+
+	// dir is the directory the file was loaded from.
+	dir string
+}
+
+type Tmux struct {
+	Access          string `yaml:"access,omitempty"`
+	ReadOnlyPort    int    `yaml:"readonly_port,omitempty"`
+	InteractivePort int    `yaml:"interactive_port,omitempty"`
+	// BindAddress is the host address ttyd is published on.
+	BindAddress string `yaml:"bind_address,omitempty"`
+}
+
+// Find returns the path of the project file in dir.
+func Find(dir string) (string, error) {
+	path := filepath.Join(dir, FileName)
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("no %s found in %s", FileName, dir)
+		}
+		return "", err
+	}
+	return path, nil
+}
+
+// Load reads and validates the project file at path.
+func Load(path string) (*File, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, err
+	}
+	f, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	f.dir = filepath.Dir(abs)
+	return f, nil
+}
+
+// Parse decodes and validates a project file. Unknown keys are rejected so
+// typos don't silently fall back to defaults.
+func Parse(b []byte) (*File, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	var f File
+	if err := dec.Decode(&f); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("file is empty")
+		}
+		return nil, err
+	}
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (f *File) validate() error {
+	if f.Version != Version {
+		return fmt.Errorf("version must be %d", Version)
+	}
+	if f.Provider != "" && !f.Provider.Valid() {
+		return fmt.Errorf("provider must be one of: base, claude, codex")
+	}
+	switch f.Tmux.Access {
+	case "", "none", "read", "write":
+	default:
+		return fmt.Errorf("tmux.access must be one of: none, read, write")
+	}
+	return nil
+}
+
+// StackName returns the stack name: the file's name field, or else one
+// derived from the directory that holds the file.
+func (f *File) StackName() (string, error) {
+	if f.Name != "" {
+		return f.Name, nil
+	}
+	return NameFromDir(f.dir)
+}
+
+// NameFromDir turns a directory's base name into a valid stack name.
+func NameFromDir(dir string) (string, error) {
+	name := stack.SanitizeName(filepath.Base(dir))
+	if name == "" {
+		return "", fmt.Errorf("can't derive a stack name from %q; set name in %s", dir, FileName)
+	}
+	return name, nil
+}
+
+// Options maps the file onto CreateOptions, filling unset fields from
+// defaults. Auth is left empty for the caller to load from the keychain.
+func (f *File) Options(defaults domain.Defaults) (domain.CreateOptions, error) {
+	name, err := f.StackName()
+	if err != nil {
+		return domain.CreateOptions{}, err
+	}
+	opts := domain.CreateOptions{
+		Name:            name,
+		Provider:        defaults.Provider,
+		Image:           f.Image,
+		ReadOnlyPort:    defaults.ReadOnlyPort,
+		InteractivePort: defaults.InteractivePort,
+		TmuxAccess:      defaults.TmuxAccess,
+		FirewallEnable:  defaults.FirewallEnable,
+		TunnelEnable:    defaults.TunnelEnable,
+		Env:             f.Env,
+		BindAddress:     defaults.BindAddress,
+		AuthProfile:     f.AuthProfile,
+	}
+	if f.Provider != "" {
+		opts.Provider = f.Provider
+	}
+	if f.Tmux.Access != "" {
+		opts.TmuxAccess = f.Tmux.Access
+	}
+	if f.Tmux.ReadOnlyPort != 0 {
+		opts.ReadOnlyPort = f.Tmux.ReadOnlyPort
+	}
+	if f.Tmux.InteractivePort != 0 {
+		opts.InteractivePort = f.Tmux.InteractivePort
+	}
+	if f.Tmux.BindAddress != "" {
+		opts.BindAddress = f.Tmux.BindAddress
+	}
+	if f.Firewall != nil {
+		opts.FirewallEnable = *f.Firewall
+	}
+	if f.Tunnel != nil {
+		opts.TunnelEnable = *f.Tunnel
+	}
+
+	workspace := "."
+	if f.Workspace != nil {
+		workspace = *f.Workspace
+	}
+	if workspace != "" {
+		if opts.WorkspacePath, err = f.resolve(workspace); err != nil {
+			return domain.CreateOptions{}, err
+		}
+	}
+	for _, m := range f.Mounts {
+		host, rest, ok := strings.Cut(m, ":")
+		if !ok {
+			return domain.CreateOptions{}, fmt.Errorf("mount %q must be in host:container[:ro|rw] format", m)
+		}
+		if host, err = f.resolve(host); err != nil {
+			return domain.CreateOptions{}, err
+		}
+		opts.Mounts = append(opts.Mounts, host+":"+rest)
+	}
+	return opts, nil
+}
+
+// resolve makes p absolute relative to the file's directory, expanding a
+// leading ~ to the home directory.
+func (f *File) resolve(p string) (string, error) {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("resolve %q: %w", p, err)
+		}
+		p = filepath.Join(home, strings.TrimPrefix(p, "~"))
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(f.dir, p)
+	}
+	return filepath.Clean(p), nil
+}
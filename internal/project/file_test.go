@@ -0,0 +1,118 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openhoo/vibecontainer/internal/domain"
+)
+
+func writeProject(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, FileName)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadMapsOntoCreateOptions(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "My_Repo")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	path := writeProject(t, dir, `
+version: 1
+provider: claude
+tmux:
+  access: write
+  interactive_port: 9002
+firewall: false
+env:
+  GIT_AUTHOR_NAME: Dev
+mounts:
+  - ./cache:/home/dev/.cache
+  - /etc/gitconfig:/etc/gitconfig:ro
+`)
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	opts, err := f.Options(domain.DefaultDefaults())
+	if err != nil {
+		t.Fatalf("Options failed: %v", err)
+	}
+	if opts.Name != "my-repo" {
+		t.Fatalf("expected name derived from directory, got %q", opts.Name)
+	}
+	if opts.Provider != domain.ProviderClaude || opts.TmuxAccess != "write" {
+		t.Fatalf("unexpected provider/tmux: %+v", opts)
+	}
+	if opts.ReadOnlyPort != 7681 || opts.InteractivePort != 9002 {
+		t.Fatalf("expected default readonly port and explicit interactive port, got %d/%d", opts.ReadOnlyPort, opts.InteractivePort)
+	}
+	if opts.FirewallEnable || !opts.TunnelEnable {
+		t.Fatalf("expected explicit firewall and default tunnel, got %+v", opts)
+	}
+	if opts.WorkspacePath != dir {
+		t.Fatalf("expected workspace to default to %q, got %q", dir, opts.WorkspacePath)
+	}
+	wantMounts := []string{filepath.Join(dir, "cache") + ":/home/dev/.cache", "/etc/gitconfig:/etc/gitconfig:ro"}
+	if strings.Join(opts.Mounts, ",") != strings.Join(wantMounts, ",") {
+		t.Fatalf("unexpected mounts %v", opts.Mounts)
+	}
+	if opts.Env["GIT_AUTHOR_NAME"] != "Dev" {
+		t.Fatalf("unexpected env %v", opts.Env)
+	}
+}
+
+func TestLoadWorkspaceDisabled(t *testing.T) {
+	path := writeProject(t, t.TempDir(), "version: 1\nname: custom\nworkspace: \"\"\n")
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	opts, err := f.Options(domain.DefaultDefaults())
+	if err != nil {
+		t.Fatalf("Options failed: %v", err)
+	}
+	if opts.Name != "custom" || opts.WorkspacePath != "" {
+		t.Fatalf("unexpected options %+v", opts)
+	}
+}
+
+func TestParseRejectsInvalidFiles(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown key":    "version: 1\nprovidr: codex\n",
+		"bad version":    "version: 2\n",
+		"bad provider":   "version: 1\nprovider: gemini\n",
+		"bad tmux":       "version: 1\ntmux:\n  access: full\n",
+		"empty document": "",
+	} {
+		if _, err := Parse([]byte(content)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestNameFromDir(t *testing.T) {
+	cases := map[string]string{
+		"/src/vibecontainer": "vibecontainer",
+		"/src/My Project.v2": "my-project-v2",
+		"/src/__x__":         "",
+	}
+	for dir, want := range cases {
+		got, err := NameFromDir(dir)
+		if want == "" {
+			if err == nil {
+				t.Errorf("%s: expected error, got %q", dir, got)
+			}
+			continue
+		}
+		if err != nil || got != want {
+			t.Errorf("%s: got %q, %v; want %q", dir, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Package secretref resolves credentials given as references to a secret
+// manager: op://vault/item/field (1Password CLI), pass:path (pass) and
+// env:VAR.
+package secretref
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/openhoo/vibecontainer/internal/domain"
+)
+
+// Timeout bounds each call to a secret manager, which may wait for an
+// unlock prompt.
+const Timeout = 2 * time.Minute
+
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Check validates the syntax of a reference without resolving it.
+func Check(ref string) error {
+	switch {
+	case strings.HasPrefix(ref, "op://"):
+		parts := strings.Split(strings.TrimPrefix(ref, "op://"), "/")
+		if len(parts) < 3 || slices.Contains(parts, "") {
+			return fmt.Errorf("%s: want op://vault/item/field", ref)
+		}
+	case strings.HasPrefix(ref, "pass:"):
+		if strings.TrimSpace(strings.TrimPrefix(ref, "pass:")) == "" {
+			return fmt.Errorf("%s: want pass:path/to/entry", ref)
+		}
+	case strings.HasPrefix(ref, "env:"):
+		if !envNameRe.MatchString(strings.TrimPrefix(ref, "env:")) {
+			return fmt.Errorf("%s: want env:VARIABLE_NAME", ref)
+		}
+	default:
+		return fmt.Errorf("%s is not a secret reference (want op://, pass: or env:)", ref)
+	}
+	return nil
+}
+
+// Resolve returns the secret value names. Values that aren't references
+// are returned as they are.
+func Resolve(ctx context.Context, value string) (string, error) {
+	if !domain.IsSecretRef(value) {
+		return value, nil
+	}
+	if err := Check(value); err != nil {
+		return "", err
+	}
+	var (
+		out string
+		err error
+	)
+	switch {
+	case strings.HasPrefix(value, "op://"):
+		out, err = run(ctx, "op", "1Password CLI", "read", "--no-newline", value)
+	case strings.HasPrefix(value, "pass:"):
+		out, err = run(ctx, "pass", "pass", "show", strings.TrimPrefix(value, "pass:"))
+		// pass keeps the password on the first line and notes below it.
+		out, _, _ = strings.Cut(out, "\n")
+	default:
+		name := strings.TrimPrefix(value, "env:")
+		v, ok := os.LookupEnv(name)
+		if !ok || v == "" {
+			return "", fmt.Errorf("%s: $%s is not set", value, name)
+		}
+		out = v
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", value, err)
+	}
+	out = strings.TrimRight(out, "\r\n")
+	if out == "" {
+		return "", fmt.Errorf("%s resolved to an empty value", value)
+	}
+	return out, nil
+}
+
+// ResolveAuth resolves every reference in auth.
+func ResolveAuth(ctx context.Context, auth domain.Auth) (domain.Auth, error) {
+	return auth.Map(func(env, value string) (string, error) {
+		v, err := Resolve(ctx, value)
+		if err != nil {
+			return "", fmt.Errorf("resolve %s: %w", env, err)
+		}
+		return v, nil
+	})
+}
+
+// run invokes a secret manager and returns what it prints.
+func run(ctx context.Context, name, title string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Let the tool ask for its passphrase on the terminal.
+	cmd.Stdin = os.Stdin
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", fmt.Errorf("%s (%s) is not installed or not on PATH", name, title)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s failed: %w\n%s", name, err, msg)
+		}
+		return "", fmt.Errorf("%s failed: %w", name, err)
+	}
+	return stdout.String(), nil
+}
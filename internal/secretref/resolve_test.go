@@ -0,0 +1,99 @@
+package secretref
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/openhoo/vibecontainer/internal/domain"
+)
+
+// fakeTool puts an executable shell script called name first on PATH.
+func fakeTool(t *testing.T, name, script string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestResolveOnePassword(t *testing.T) {
+	fakeTool(t, "op", `[ "$1 $2 $3" = "read --no-newline op://dev/openai/key" ] || { echo "bad args: $*" >&2; exit 1; }
+printf 'sk-from-op'`)
+	got, err := Resolve(context.Background(), "op://dev/openai/key")
+	if err != nil || got != "sk-from-op" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	if _, err := Resolve(context.Background(), "op://dev/other/key"); err == nil || !strings.Contains(err.Error(), "bad args") {
+		t.Fatalf("expected the tool's error, got %v", err)
+	}
+}
+
+func TestResolvePass(t *testing.T) {
+	fakeTool(t, "pass", `[ "$1" = show ] && [ "$2" = work/anthropic ] || exit 1
+printf 'sk-from-pass\nurl: https://console.anthropic.com\n'`)
+	got, err := Resolve(context.Background(), "pass:work/anthropic")
+	if err != nil || got != "sk-from-pass" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+}
+
+func TestResolveEnvAndPlain(t *testing.T) {
+	t.Setenv("VC_TEST_TOKEN", "tok")
+	if got, err := Resolve(context.Background(), "env:VC_TEST_TOKEN"); err != nil || got != "tok" {
+		t.Fatalf("env: got %q, %v", got, err)
+	}
+	if _, err := Resolve(context.Background(), "env:VC_TEST_UNSET"); err == nil {
+		t.Fatal("expected an unset variable to fail")
+	}
+	if got, err := Resolve(context.Background(), "sk-plain"); err != nil || got != "sk-plain" {
+		t.Fatalf("plain values pass through, got %q, %v", got, err)
+	}
+}
+
+func TestResolveMissingTool(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	if _, err := Resolve(context.Background(), "op://a/b/c"); err == nil || !strings.Contains(err.Error(), "not installed") {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestResolveAuth(t *testing.T) {
+	t.Setenv("VC_TEST_TUNNEL", "tunnel-secret")
+	auth, err := ResolveAuth(context.Background(), domain.Auth{TunnelToken: "env:VC_TEST_TUNNEL", OpenAIAPIKey: "sk-plain"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth.TunnelToken != "tunnel-secret" || auth.OpenAIAPIKey != "sk-plain" {
+		t.Fatalf("unexpected auth %+v", auth)
+	}
+	_, err = ResolveAuth(context.Background(), domain.Auth{AnthropicAPIKey: "env:VC_TEST_UNSET"})
+	if err == nil || !strings.Contains(err.Error(), "ANTHROPIC_API_KEY") {
+		t.Fatalf("errors should name the credential, got %v", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	for ref, ok := range map[string]bool{
+		"op://vault/item/field":         true,
+		"op://vault/item/section/field": true,
+		"op://vault/item":               false,
+		"op://vault//field":             false,
+		"pass:work/openai":              true,
+		"pass:":                         false,
+		"env:OPENAI_API_KEY":            true,
+		"env:1BAD":                      false,
+		"sk-plain":                      false,
+	} {
+		if err := Check(ref); (err == nil) != ok {
+			t.Errorf("Check(%q): got %v, want ok=%v", ref, err, ok)
+		}
+	}
+}
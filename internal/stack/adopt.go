@@ -0,0 +1,201 @@
+package stack
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/openhoo/vibecontainer/internal/domain"
+)
+
+// Container-side ttyd ports used by the images unless overridden.
+const (
+	readOnlyContainerPort    = 7681
+	interactiveContainerPort = 7682
+)
+
+// adoptDroppedEnv lists image variables ComposeYAML can't carry over: it
+// always publishes ttyd on the default ports and loopback address.
+var adoptDroppedEnv = map[string]bool{
+	"TMUX_WEB_READONLY_PORT":    true,
+	"TMUX_WEB_INTERACTIVE_PORT": true,
+	"TMUX_WEB_BIND_ADDRESS":     true,
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// SanitizeName turns an arbitrary string into a valid stack name, or
+// returns "" when too little of it survives.
+func SanitizeName(s string) string {
+	name := invalidNameChars.ReplaceAllString(strings.ToLower(s), "-")
+	if len(name) > 31 {
+		name = name[:31]
+	}
+	name = strings.Trim(name, "-")
+	if len(name) < 2 {
+		return ""
+	}
+	return name
+}
+
+// AdoptName suggests a stack name for a container: the stack it is labelled
+// with, or else one derived from its container name.
+func AdoptName(info domain.ContainerInfo) string {
+	if name := info.Labels[stackLabel]; name != "" {
+		return name
+	}
+	return SanitizeName(strings.TrimSuffix(info.Name, "-vibecontainer"))
+}
+
+// OptionsFromContainer reconstructs the CreateOptions a vibecontainer
+// container was started with. Settings recorded in labels by commonLabels
+// win; everything else is inferred from the container's image, environment,
+// port bindings and mounts. imageEnv is the environment baked into the
+// image, used to tell user-set variables apart from image defaults.
+// Warnings describe settings that could not be carried over.
+func OptionsFromContainer(info domain.ContainerInfo, imageEnv map[string]string) (domain.CreateOptions, []string) {
+	var warnings []string
+	opts := domain.CreateOptions{
+		Name:           AdoptName(info),
+		Provider:       adoptProvider(info),
+		FirewallEnable: info.Env["FIREWALL_ENABLE"] != "0",
+	}
+	if info.Image != DefaultImage(opts.Provider) {
+		opts.Image = info.Image
+	}
+
+	switch {
+	case info.Env["TMUX_WEB_INTERACTIVE_ENABLE"] == "1":
+		opts.TmuxAccess = "write"
+	case info.Env["TMUX_WEB_ENABLE"] == "1":
+		opts.TmuxAccess = "read"
+	default:
+		opts.TmuxAccess = "none"
+	}
+	readOnlyPort := envPort(info.Env, "TMUX_WEB_READONLY_PORT", readOnlyContainerPort)
+	interactivePort := envPort(info.Env, "TMUX_WEB_INTERACTIVE_PORT", interactiveContainerPort)
+	for _, p := range info.Ports {
+		switch p.ContainerPort {
+		case readOnlyPort:
+			opts.ReadOnlyPort = p.HostPort
+		case interactivePort:
+			opts.InteractivePort = p.HostPort
+		}
+		if p.HostIP != "" && p.HostIP != domain.LoopbackAddress && (p.ContainerPort == readOnlyPort || p.ContainerPort == interactivePort) {
+			opts.BindAddress = p.HostIP
+		}
+	}
+
+	for _, m := range info.Mounts {
+		if m.Type != "" && m.Type != "bind" {
+			warnings = append(warnings, fmt.Sprintf("%s mount at %s is not carried over", m.Type, m.Destination))
+			continue
+		}
+		if m.Destination == "/workspace" {
+			opts.WorkspacePath = m.Source
+			continue
+		}
+		mount := m.Source + ":" + m.Destination
+		if m.ReadOnly {
+			mount += ":ro"
+		}
+		opts.Mounts = append(opts.Mounts, mount)
+	}
+	sort.Strings(opts.Mounts)
+
+	for k, v := range info.Env {
+		if domain.IsManagedEnv(k) {
+			continue
+		}
+		if adoptDroppedEnv[k] {
+			warnings = append(warnings, fmt.Sprintf("%s is not supported by managed stacks and is dropped", k))
+			continue
+		}
+		if iv, ok := imageEnv[k]; ok && iv == v {
+			continue
+		}
+		if opts.Env == nil {
+			opts.Env = map[string]string{}
+		}
+		opts.Env[k] = v
+	}
+	applySecrets(&opts, info.Env)
+
+	if opts.FirewallEnable && !(hasCap(info.CapAdd, "NET_ADMIN") && hasCap(info.CapAdd, "NET_RAW")) {
+		warnings = append(warnings, "container lacks NET_ADMIN/NET_RAW; the recreated stack adds them for the firewall")
+	}
+
+	applyLabels(&opts, info.Labels)
+	sort.Strings(warnings)
+	return opts, warnings
+}
+
+// applyLabels overrides opts with the settings commonLabels records. Labels
+// written before those settings existed are ignored.
+func applyLabels(opts *domain.CreateOptions, labels map[string]string) {
+	if labels[managedLabel] != "true" {
+		return
+	}
+	if v, err := strconv.Atoi(labels[versionLabel]); err != nil || v < 2 {
+		return
+	}
+	if v, ok := labels[workspaceLabel]; ok {
+		opts.WorkspacePath = v
+	}
+	if v := labels[tmuxAccessLabel]; v != "" {
+		opts.TmuxAccess = v
+	}
+	if v, err := strconv.Atoi(labels[readOnlyPortLabel]); err == nil {
+		opts.ReadOnlyPort = v
+	}
+	if v, err := strconv.Atoi(labels[interactivePortLabel]); err == nil {
+		opts.InteractivePort = v
+	}
+	if v, err := strconv.ParseBool(labels[firewallLabel]); err == nil {
+		opts.FirewallEnable = v
+	}
+	if v, ok := labels[bindAddressLabel]; ok {
+		opts.BindAddress = v
+	}
+	if v, err := strconv.ParseBool(labels[tunnelLabel]); err == nil {
+		opts.TunnelEnable = v
+	}
+}
+
+// adoptProvider picks the provider from the container's label, then from
+// the credentials in its environment, then from its image tag.
+func adoptProvider(info domain.ContainerInfo) domain.Provider {
+	if p := domain.Provider(info.Labels[providerLabel]); p.Valid() {
+		return p
+	}
+	switch {
+	case info.Env["CLAUDE_CODE_OAUTH_TOKEN"] != "" || info.Env["ANTHROPIC_API_KEY"] != "":
+		return domain.ProviderClaude
+	case info.Env["CODEX_AUTH_JSON"] != "" || info.Env["OPENAI_API_KEY"] != "" || info.Env["CODEX_API_KEY"] != "":
+		return domain.ProviderCodex
+	}
+	if _, tag, ok := strings.Cut(info.Image[strings.LastIndex(info.Image, "/")+1:], ":"); ok {
+		if p := domain.Provider(tag); p.Valid() {
+			return p
+		}
+	}
+	return domain.ProviderBase
+}
+
+func envPort(env map[string]string, key string, fallback int) int {
+	if v, err := strconv.Atoi(env[key]); err == nil {
+		return v
+	}
+	return fallback
+}
+
+func hasCap(caps []string, name string) bool {
+	for _, c := range caps {
+		if strings.TrimPrefix(strings.ToUpper(c), "CAP_") == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package stack
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/openhoo/vibecontainer/internal/domain"
+)
+
+func TestOptionsFromContainerInfersSettings(t *testing.T) {
+	info := domain.ContainerInfo{
+		Name:  "My_Box",
+		Image: "ghcr.io/openhoo/vibecontainer:codex",
+		Env: map[string]string{
+			"TMUX_WEB_ENABLE":             "1",
+			"TMUX_WEB_INTERACTIVE_ENABLE": "1",
+			"TMUX_WEB_INTERACTIVE_PORT":   "8000",
+			"OPENAI_API_KEY":              "sk-test",
+			"PATH":                        "/usr/bin",
+			"EDITOR":                      "vim",
+		},
+		Labels: map[string]string{},
+		Ports: []domain.PortBinding{
+			{HostIP: "127.0.0.1", HostPort: 9001, ContainerPort: 7681},
+			{HostIP: "127.0.0.1", HostPort: 9002, ContainerPort: 8000},
+		},
+		Mounts: []domain.Mount{
+			{Type: "bind", Source: "/src", Destination: "/workspace"},
+			{Type: "bind", Source: "/cache", Destination: "/cache", ReadOnly: true},
+		},
+		CapAdd: []string{"NET_ADMIN", "NET_RAW"},
+	}
+	opts, warnings := OptionsFromContainer(info, map[string]string{"PATH": "/usr/bin"})
+
+	want := domain.CreateOptions{
+		Name:            "my-box",
+		Provider:        domain.ProviderCodex,
+		WorkspacePath:   "/src",
+		ReadOnlyPort:    9001,
+		InteractivePort: 9002,
+		TmuxAccess:      "write",
+		FirewallEnable:  true,
+		Env:             map[string]string{"EDITOR": "vim"},
+		Mounts:          []string{"/cache:/cache:ro"},
+		Auth:            domain.Auth{OpenAIAPIKey: "sk-test"},
+	}
+	if !reflect.DeepEqual(opts, want) {
+		t.Fatalf("got %+v\nwant %+v", opts, want)
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("expected a warning for the dropped port variable, got %v", warnings)
+	}
+}
+
+func TestOptionsFromContainerPrefersLabels(t *testing.T) {
+	src := domain.CreateOptions{
+		Name:            "demo",
+		Provider:        domain.ProviderClaude,
+		WorkspacePath:   "/src",
+		ReadOnlyPort:    7001,
+		InteractivePort: 7002,
+		TmuxAccess:      "read",
+		TunnelEnable:    true,
+	}
+	info := domain.ContainerInfo{
+		Name:   "demo-vibecontainer",
+		Image:  DefaultImage(domain.ProviderClaude),
+		Env:    map[string]string{"FIREWALL_ENABLE": "0"},
+		Labels: commonLabels(src, "vibecontainer"),
+	}
+	opts, _ := OptionsFromContainer(info, nil)
+	if !reflect.DeepEqual(opts, src) {
+		t.Fatalf("got %+v\nwant %+v", opts, src)
+	}
+}
+
+func TestSanitizeName(t *testing.T) {
+	for in, want := range map[string]string{
+		"My Project": "my-project",
+		"--x--":      "",
+		"a.b_c":      "a-b-c",
+	} {
+		if got := SanitizeName(in); got != want {
+			t.Errorf("SanitizeName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package stack
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/openhoo/vibecontainer/internal/domain"
+)
+
+// Change describes one setting that differs between two CreateOptions.
+// Secret values are never included; they are reported as set, unset or
+// changed.
+type Change struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// DiffOptions lists the settings that differ between old and new, in the
+// order they appear in the create wizard.
+func DiffOptions(old, new domain.CreateOptions) []Change {
+	var changes []Change
+	add := func(field, o, n string) {
+		if o != n {
+			changes = append(changes, Change{Field: field, Old: o, New: n})
+		}
+	}
+	secret := func(field, o, n string) {
+		if o == n {
+			return
+		}
+		switch {
+		case o == "":
+			changes = append(changes, Change{Field: field, Old: "(not set)", New: "(set)"})
+		case n == "":
+			changes = append(changes, Change{Field: field, Old: "(set)", New: "(not set)"})
+		default:
+			changes = append(changes, Change{Field: field, Old: "(set)", New: "(changed)"})
+		}
+	}
+
+	add("Provider", string(old.Provider), string(new.Provider))
+	add("Image", imageOrDefault(old), imageOrDefault(new))
+	add("Workspace", orNone(old.WorkspacePath), orNone(new.WorkspacePath))
+	add("Tmux Access", old.TmuxAccess, new.TmuxAccess)
+	add("Read-only Port", portString(old.ReadOnlyPort), portString(new.ReadOnlyPort))
+	add("Interactive Port", portString(old.InteractivePort), portString(new.InteractivePort))
+	add("Bind Address", domain.PublishAddress(old.BindAddress), domain.PublishAddress(new.BindAddress))
+	add("Public Write", strconv.FormatBool(old.ForcePublicWrite), strconv.FormatBool(new.ForcePublicWrite))
+	add("Firewall", strconv.FormatBool(old.FirewallEnable), strconv.FormatBool(new.FirewallEnable))
+	add("Tunnel", strconv.FormatBool(old.TunnelEnable), strconv.FormatBool(new.TunnelEnable))
+	add("Auth Profile", domain.CredentialProfile(old.AuthProfile), domain.CredentialProfile(new.AuthProfile))
+	add("Mounts", orNone(strings.Join(old.Mounts, ", ")), orNone(strings.Join(new.Mounts, ", ")))
+	for _, k := range envKeys(old.Env, new.Env) {
+		o, oldOK := old.Env[k]
+		n, newOK := new.Env[k]
+		switch {
+		case !oldOK:
+			add("Env "+k, "(unset)", n)
+		case !newOK:
+			add("Env "+k, o, "(unset)")
+		default:
+			add("Env "+k, o, n)
+		}
+	}
+	secret("TTYD Credential", old.TTYDCredential, new.TTYDCredential)
+	secret("Claude OAuth Token", old.Auth.ClaudeOAuthToken, new.Auth.ClaudeOAuthToken)
+	secret("Anthropic API Key", old.Auth.AnthropicAPIKey, new.Auth.AnthropicAPIKey)
+	secret("Codex Auth JSON", old.Auth.CodexAuthJSON, new.Auth.CodexAuthJSON)
+	secret("OpenAI API Key", old.Auth.OpenAIAPIKey, new.Auth.OpenAIAPIKey)
+	secret("Codex API Key", old.Auth.CodexAPIKey, new.Auth.CodexAPIKey)
+	secret("Tunnel Token", old.Auth.TunnelToken, new.Auth.TunnelToken)
+	return changes
+}
+
+func envKeys(a, b map[string]string) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, m := range []map[string]string{a, b} {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func imageOrDefault(opts domain.CreateOptions) string {
+	if opts.Image != "" {
+		return opts.Image
+	}
+	return DefaultImage(opts.Provider)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
+
+func portString(p int) string {
+	if p == 0 {
+		return "-"
+	}
+	return strconv.Itoa(p)
+}
@@ -0,0 +1,42 @@
+package stack
+
+import "sort"
+
+// Drift describes where run directories and managed containers disagree.
+type Drift struct {
+	// OrphanContainers maps a stack name to containers labelled for it that
+	// have no run directory.
+	OrphanContainers map[string][]string
+	// DeadRunDirs lists stacks with a run directory but no containers.
+	DeadRunDirs []string
+}
+
+// Empty reports whether there is nothing to reconcile.
+func (d Drift) Empty() bool {
+	return len(d.OrphanContainers) == 0 && len(d.DeadRunDirs) == 0
+}
+
+// FindDrift compares the run directory names against the containers found
+// for each stack label. Stacks in adopted still run on the container they
+// were adopted from, which may carry no stack label until the next start,
+// so their run directories are never stale.
+func FindDrift(runDirs []string, adopted map[string]bool, containersByStack map[string][]string) Drift {
+	drift := Drift{OrphanContainers: map[string][]string{}}
+	known := map[string]bool{}
+	for _, name := range runDirs {
+		known[name] = true
+		if len(containersByStack[name]) == 0 && !adopted[name] {
+			drift.DeadRunDirs = append(drift.DeadRunDirs, name)
+		}
+	}
+	for name, containers := range containersByStack {
+		if known[name] || len(containers) == 0 {
+			continue
+		}
+		sorted := append([]string(nil), containers...)
+		sort.Strings(sorted)
+		drift.OrphanContainers[name] = sorted
+	}
+	sort.Strings(drift.DeadRunDirs)
+	return drift
+}
@@ -0,0 +1,38 @@
+package stack
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindDrift(t *testing.T) {
+	drift := FindDrift(
+		[]string{"healthy", "dead"},
+		nil,
+		map[string][]string{
+			"healthy": {"healthy-vibecontainer"},
+			"orphan":  {"orphan-vibecontainer", "orphan-cloudflared"},
+		},
+	)
+	if !reflect.DeepEqual(drift.DeadRunDirs, []string{"dead"}) {
+		t.Fatalf("unexpected dead run dirs %v", drift.DeadRunDirs)
+	}
+	want := map[string][]string{"orphan": {"orphan-cloudflared", "orphan-vibecontainer"}}
+	if !reflect.DeepEqual(drift.OrphanContainers, want) {
+		t.Fatalf("unexpected orphans %v", drift.OrphanContainers)
+	}
+	if drift.Empty() {
+		t.Fatal("expected drift")
+	}
+	if !FindDrift([]string{"a"}, nil, map[string][]string{"a": {"a-vibecontainer"}}).Empty() {
+		t.Fatal("expected no drift")
+	}
+}
+
+func TestFindDriftKeepsAdoptedStacks(t *testing.T) {
+	// An adopted container named after the stack has no stack label yet.
+	drift := FindDrift([]string{"my-box", "dead"}, map[string]bool{"my-box": true}, map[string][]string{})
+	if !reflect.DeepEqual(drift.DeadRunDirs, []string{"dead"}) {
+		t.Fatalf("an adopted stack is not stale, got %v", drift.DeadRunDirs)
+	}
+}
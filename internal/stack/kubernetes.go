@@ -0,0 +1,262 @@
+package stack
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/openhoo/vibecontainer/internal/domain"
+	"gopkg.in/yaml.v3"
+)
+
+// workspaceSize is the storage requested for the workspace volume.
+const workspaceSize = "10Gi"
+
+// Minimal Kubernetes object shapes; only the fields KubernetesYAML sets.
+type (
+	k8sObject struct {
+		APIVersion string            `yaml:"apiVersion"`
+		Kind       string            `yaml:"kind"`
+		Metadata   k8sMeta           `yaml:"metadata"`
+		Type       string            `yaml:"type,omitempty"`
+		StringData map[string]string `yaml:"stringData,omitempty"`
+		Spec       any               `yaml:"spec,omitempty"`
+	}
+	k8sMeta struct {
+		Name   string            `yaml:"name,omitempty"`
+		Labels map[string]string `yaml:"labels,omitempty"`
+	}
+	k8sDeploymentSpec struct {
+		Replicas int `yaml:"replicas"`
+		Strategy struct {
+			Type string `yaml:"type"`
+		} `yaml:"strategy"`
+		Selector struct {
+			MatchLabels map[string]string `yaml:"matchLabels"`
+		} `yaml:"selector"`
+		Template struct {
+			Metadata k8sMeta    `yaml:"metadata"`
+			Spec     k8sPodSpec `yaml:"spec"`
+		} `yaml:"template"`
+	}
+	k8sPodSpec struct {
+		Containers []k8sContainer `yaml:"containers"`
+		Volumes    []k8sVolume    `yaml:"volumes,omitempty"`
+	}
+	k8sContainer struct {
+		Name            string              `yaml:"name"`
+		Image           string              `yaml:"image"`
+		Args            []string            `yaml:"args,omitempty"`
+		WorkingDir      string              `yaml:"workingDir,omitempty"`
+		Env             []k8sEnv            `yaml:"env,omitempty"`
+		Ports           []k8sContainerPort  `yaml:"ports,omitempty"`
+		SecurityContext *k8sSecurityContext `yaml:"securityContext,omitempty"`
+		VolumeMounts    []k8sVolumeMount    `yaml:"volumeMounts,omitempty"`
+	}
+	k8sEnv struct {
+		Name      string        `yaml:"name"`
+		Value     string        `yaml:"value,omitempty"`
+		ValueFrom *k8sEnvSource `yaml:"valueFrom,omitempty"`
+	}
+	k8sEnvSource struct {
+		SecretKeyRef struct {
+			Name string `yaml:"name"`
+			Key  string `yaml:"key"`
+		} `yaml:"secretKeyRef"`
+	}
+	k8sContainerPort struct {
+		Name          string `yaml:"name"`
+		ContainerPort int    `yaml:"containerPort"`
+	}
+	k8sSecurityContext struct {
+		Capabilities struct {
+			Add []string `yaml:"add"`
+		} `yaml:"capabilities"`
+	}
+	k8sVolumeMount struct {
+		Name      string `yaml:"name"`
+		MountPath string `yaml:"mountPath"`
+	}
+	k8sVolume struct {
+		Name                  string `yaml:"name"`
+		PersistentVolumeClaim struct {
+			ClaimName string `yaml:"claimName"`
+		} `yaml:"persistentVolumeClaim"`
+	}
+	k8sPVCSpec struct {
+		AccessModes []string `yaml:"accessModes"`
+		Resources   struct {
+			Requests map[string]string `yaml:"requests"`
+		} `yaml:"resources"`
+	}
+	k8sServiceSpec struct {
+		Selector map[string]string `yaml:"selector"`
+		Ports    []k8sServicePort  `yaml:"ports"`
+	}
+	k8sServicePort struct {
+		Name       string `yaml:"name"`
+		Port       int    `yaml:"port"`
+		TargetPort string `yaml:"targetPort"`
+	}
+)
+
+// KubernetesYAML renders a stack as Kubernetes manifests: a Secret with the
+// variables EnvFile writes, a PVC for the workspace, a Deployment running
+// the vibecontainer (and the cloudflared sidecar, which shares its network
+// as it does under compose) and a Service per ttyd port. Host paths can't be
+// carried to a cluster, so extra mounts are left out and reported as
+// warnings.
+func KubernetesYAML(opts domain.CreateOptions) ([]byte, []string, error) {
+	image := opts.Image
+	if strings.TrimSpace(image) == "" {
+		image = DefaultImage(opts.Provider)
+	}
+	var warnings []string
+	if opts.WorkspacePath != "" {
+		warnings = append(warnings, fmt.Sprintf("workspace %s is not copied; the pod starts with an empty %s-workspace volume", opts.WorkspacePath, opts.Name))
+	}
+	for _, m := range opts.Mounts {
+		warnings = append(warnings, fmt.Sprintf("mount %s is a host path and is left out", m))
+	}
+
+	labels := k8sLabels(opts)
+	selector := map[string]string{
+		"app.kubernetes.io/name":     "vibecontainer",
+		"app.kubernetes.io/instance": opts.Name,
+	}
+	secretName := opts.Name + "-env"
+	secrets := secretEnv(opts)
+	var objects []k8sObject
+	if len(secrets) > 0 {
+		objects = append(objects, k8sObject{
+			APIVersion: "v1",
+			Kind:       "Secret",
+			Metadata:   k8sMeta{Name: secretName, Labels: labels},
+			Type:       "Opaque",
+			StringData: secrets,
+		})
+	}
+
+	pvc := k8sPVCSpec{AccessModes: []string{"ReadWriteOnce"}}
+	pvc.Resources.Requests = map[string]string{"storage": workspaceSize}
+	objects = append(objects, k8sObject{
+		APIVersion: "v1",
+		Kind:       "PersistentVolumeClaim",
+		Metadata:   k8sMeta{Name: opts.Name + "-workspace", Labels: labels},
+		Spec:       pvc,
+	})
+
+	tmuxEnabled := opts.TmuxAccess == "read" || opts.TmuxAccess == "write"
+	env := map[string]string{}
+	for k, v := range opts.Env {
+		// Kubernetes expands $(VAR) in values; user values are literal.
+		env[k] = strings.ReplaceAll(v, "$(", "$$(")
+	}
+	env["TMUX_WEB_ENABLE"] = boolTo01(tmuxEnabled)
+	env["TMUX_WEB_INTERACTIVE_ENABLE"] = boolTo01(opts.TmuxAccess == "write")
+	env["FIREWALL_ENABLE"] = boolTo01(opts.FirewallEnable)
+	vibe := k8sContainer{
+		Name:         "vibecontainer",
+		Image:        image,
+		WorkingDir:   "/workspace",
+		Env:          k8sEnvList(env, secrets, secretName, func(key string) bool { return key != "TUNNEL_TOKEN" }),
+		VolumeMounts: []k8sVolumeMount{{Name: "workspace", MountPath: "/workspace"}},
+	}
+	vibe.SecurityContext = &k8sSecurityContext{}
+	vibe.SecurityContext.Capabilities.Add = []string{"NET_ADMIN", "NET_RAW"}
+	// Port names are capped at 15 characters, so the interactive port is
+	// "ttyd-write".
+	type service struct {
+		name string
+		port k8sServicePort
+	}
+	var services []service
+	if tmuxEnabled {
+		vibe.Ports = append(vibe.Ports, k8sContainerPort{Name: "ttyd-readonly", ContainerPort: 7681})
+		services = append(services, service{opts.Name + "-readonly", k8sServicePort{Name: "ttyd-readonly", Port: 7681, TargetPort: "ttyd-readonly"}})
+	}
+	if opts.TmuxAccess == "write" {
+		vibe.Ports = append(vibe.Ports, k8sContainerPort{Name: "ttyd-write", ContainerPort: 7682})
+		services = append(services, service{opts.Name + "-interactive", k8sServicePort{Name: "ttyd-write", Port: 7682, TargetPort: "ttyd-write"}})
+	}
+
+	deployment := k8sDeploymentSpec{Replicas: 1}
+	// The workspace volume is ReadWriteOnce; never run two pods at once.
+	deployment.Strategy.Type = "Recreate"
+	deployment.Selector.MatchLabels = selector
+	deployment.Template.Metadata = k8sMeta{Labels: labels}
+	deployment.Template.Spec.Containers = []k8sContainer{vibe}
+	if opts.TunnelEnable {
+		deployment.Template.Spec.Containers = append(deployment.Template.Spec.Containers, k8sContainer{
+			Name:  "cloudflared",
+			Image: CloudflaredImage,
+			Args:  []string{"tunnel", "run"},
+			Env:   k8sEnvList(nil, secrets, secretName, func(key string) bool { return key == "TUNNEL_TOKEN" }),
+		})
+	}
+	volume := k8sVolume{Name: "workspace"}
+	volume.PersistentVolumeClaim.ClaimName = opts.Name + "-workspace"
+	deployment.Template.Spec.Volumes = []k8sVolume{volume}
+	objects = append(objects, k8sObject{
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		Metadata:   k8sMeta{Name: opts.Name, Labels: labels},
+		Spec:       deployment,
+	})
+
+	for _, svc := range services {
+		objects = append(objects, k8sObject{
+			APIVersion: "v1",
+			Kind:       "Service",
+			Metadata:   k8sMeta{Name: svc.name, Labels: labels},
+			Spec:       k8sServiceSpec{Selector: selector, Ports: []k8sServicePort{svc.port}},
+		})
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	for _, obj := range objects {
+		if err := enc.Encode(obj); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), warnings, nil
+}
+
+// k8sLabels identifies every object of a stack, with the same managed
+// labels compose containers carry.
+func k8sLabels(opts domain.CreateOptions) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":       "vibecontainer",
+		"app.kubernetes.io/instance":   opts.Name,
+		"app.kubernetes.io/managed-by": "vibecontainer",
+		managedLabel:                   "true",
+		stackLabel:                     opts.Name,
+		providerLabel:                  string(opts.Provider),
+	}
+}
+
+// k8sEnvList lists env as plain values and the secrets keep selects as
+// references to the stack's Secret, sorted by name.
+func k8sEnvList(env, secrets map[string]string, secretName string, keep func(string) bool) []k8sEnv {
+	out := make([]k8sEnv, 0, len(env)+len(secrets))
+	for k, v := range env {
+		out = append(out, k8sEnv{Name: k, Value: v})
+	}
+	for k := range secrets {
+		if !keep(k) {
+			continue
+		}
+		ref := &k8sEnvSource{}
+		ref.SecretKeyRef.Name = secretName
+		ref.SecretKeyRef.Key = k
+		out = append(out, k8sEnv{Name: k, ValueFrom: ref})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,64 @@
+package stack
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openhoo/vibecontainer/internal/domain"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+func TestKubernetesYAMLGolden(t *testing.T) {
+	for _, provider := range []domain.Provider{domain.ProviderBase, domain.ProviderClaude, domain.ProviderCodex} {
+		for _, access := range []string{"none", "read", "write"} {
+			name := string(provider) + "-" + access
+			t.Run(name, func(t *testing.T) {
+				opts := domain.CreateOptions{
+					Name:            "demo",
+					WorkspacePath:   "/src/demo",
+					Provider:        provider,
+					ReadOnlyPort:    7681,
+					TmuxAccess:      access,
+					InteractivePort: 7682,
+					FirewallEnable:  true,
+					// Alternate the sidecar so both shapes are covered.
+					TunnelEnable:   access != "read",
+					TTYDCredential: "dev:secret",
+					Env:            map[string]string{"GIT_AUTHOR_NAME": "Dev $(whoami)"},
+					Mounts:         []string{"/home/dev/.gitconfig:/home/dev/.gitconfig:ro"},
+					Auth: domain.Auth{
+						TunnelToken:      "tunnel-token",
+						ClaudeOAuthToken: "claude-token",
+						OpenAIAPIKey:     "sk-openai",
+					},
+				}
+				got, warnings, err := KubernetesYAML(opts)
+				if err != nil {
+					t.Fatalf("KubernetesYAML failed: %v", err)
+				}
+				if len(warnings) != 2 {
+					t.Fatalf("expected workspace and mount warnings, got %q", warnings)
+				}
+				golden := filepath.Join("testdata", "k8s", name+".yaml")
+				if *update {
+					if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+						t.Fatal(err)
+					}
+					if err := os.WriteFile(golden, got, 0o644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatalf("read golden file (run go test -update to create it): %v", err)
+				}
+				if string(got) != string(want) {
+					t.Fatalf("%s is out of date; run go test ./internal/stack -update\n got:\n%s", golden, got)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package stack
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/openhoo/vibecontainer/internal/config"
+	"github.com/openhoo/vibecontainer/internal/fsutil"
+)
+
+// LockTimeout is how long a command waits for another vibecontainer process
+// to finish with a stack before giving up.
+var LockTimeout = 30 * time.Second
+
+// StackLock is held by a command while it changes stacks.
+type StackLock struct {
+	locks []*fsutil.Lock
+}
+
+// Unlock releases the lock, innermost first.
+func (l *StackLock) Unlock() {
+	for i := len(l.locks) - 1; i >= 0; i-- {
+		_ = l.locks[i].Unlock()
+	}
+	l.locks = nil
+}
+
+// Lock keeps other vibecontainer processes from changing stack name until
+// Unlock. Callers hold it around every write to the stack's run dir,
+// including Touch. It also holds the global lock shared, so LockAll waits
+// for it.
+func (s *RunStore) Lock(name string) (*StackLock, error) {
+	global, err := fsutil.LockShared(config.GlobalLockPath(), LockTimeout)
+	if err != nil {
+		return nil, lockError("another vibecontainer command is changing all stacks", err)
+	}
+	l, err := fsutil.LockExclusive(config.StackLockPath(name), LockTimeout)
+	if err != nil {
+		_ = global.Unlock()
+		return nil, lockError(fmt.Sprintf("stack %q is being changed by another vibecontainer command", name), err)
+	}
+	return &StackLock{locks: []*fsutil.Lock{global, l}}, nil
+}
+
+// LockAll keeps other vibecontainer processes from changing any stack, for
+// commands such as prune that span stacks.
+func (s *RunStore) LockAll() (*StackLock, error) {
+	l, err := fsutil.LockExclusive(config.GlobalLockPath(), LockTimeout)
+	if err != nil {
+		return nil, lockError("another vibecontainer command is changing stacks", err)
+	}
+	return &StackLock{locks: []*fsutil.Lock{l}}, nil
+}
+
+// LockPorts is held from picking the host ports of a stack until they are
+// saved in its run.json, where other commands see them. Take it after the
+// stack's Lock.
+func (s *RunStore) LockPorts() (*StackLock, error) {
+	l, err := fsutil.LockExclusive(config.PortsLockPath(), LockTimeout)
+	if err != nil {
+		return nil, lockError("another vibecontainer command is picking ports", err)
+	}
+	return &StackLock{locks: []*fsutil.Lock{l}}, nil
+}
+
+func lockError(msg string, err error) error {
+	if errors.Is(err, fsutil.ErrLocked) {
+		return fmt.Errorf("%s; gave up after %s: %w", msg, LockTimeout, err)
+	}
+	return err
+}
@@ -0,0 +1,43 @@
+package stack
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/openhoo/vibecontainer/internal/fsutil"
+)
+
+func TestRunStoreLocks(t *testing.T) {
+	useTempDataDir(t)
+	prev := LockTimeout
+	LockTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { LockTimeout = prev })
+	store := NewRunStore()
+
+	demo, err := store.Lock("demo")
+	if err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	if _, err := store.Lock("demo"); !errors.Is(err, fsutil.ErrLocked) {
+		t.Fatalf("second Lock of demo: got %v, want ErrLocked", err)
+	}
+	other, err := store.Lock("other")
+	if err != nil {
+		t.Fatalf("Lock of another stack failed: %v", err)
+	}
+	if _, err := store.LockAll(); !errors.Is(err, fsutil.ErrLocked) {
+		t.Fatalf("LockAll while stacks are locked: got %v, want ErrLocked", err)
+	}
+	demo.Unlock()
+	other.Unlock()
+
+	all, err := store.LockAll()
+	if err != nil {
+		t.Fatalf("LockAll failed: %v", err)
+	}
+	defer all.Unlock()
+	if _, err := store.Lock("demo"); !errors.Is(err, fsutil.ErrLocked) {
+		t.Fatalf("Lock during LockAll: got %v, want ErrLocked", err)
+	}
+}
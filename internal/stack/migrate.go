@@ -0,0 +1,37 @@
+package stack
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/openhoo/vibecontainer/internal/config"
+	"github.com/openhoo/vibecontainer/internal/domain"
+)
+
+// migrate upgrades run.json written by older releases to the current
+// version. Version 1 files carry no Spec, so it is recovered from the
+// stack's compose file. Load is a read path and takes no lock, so the
+// result is kept in memory; the next write, made under the stack's Lock,
+// persists it.
+func migrate(meta domain.RunMetadata) (domain.RunMetadata, error) {
+	composeYAML, err := os.ReadFile(config.RunComposePath(meta.Name))
+	if err != nil {
+		return domain.RunMetadata{}, fmt.Errorf("migrate stack %q: %w", meta.Name, err)
+	}
+	opts, err := OptionsFromCompose(composeYAML, nil)
+	if err != nil {
+		return domain.RunMetadata{}, fmt.Errorf("migrate stack %q: %w", meta.Name, err)
+	}
+	spec := domain.SpecFromOptions(opts)
+	envFile, err := os.ReadFile(config.RunEnvPath(meta.Name))
+	if err != nil && !os.IsNotExist(err) {
+		return domain.RunMetadata{}, fmt.Errorf("migrate stack %q: %w", meta.Name, err)
+	}
+	if env, err := ParseEnvFile(envFile); err == nil {
+		spec.TTYDCredentialSet = env["TTYD_CREDENTIAL"] != ""
+	}
+
+	meta.Spec = spec
+	meta.Version = domain.RunMetadataVersion
+	return meta, nil
+}
@@ -0,0 +1,181 @@
+package stack
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/openhoo/vibecontainer/internal/config"
+	"github.com/openhoo/vibecontainer/internal/domain"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadOptions returns the CreateOptions a stack was rendered from: settings
+// come from run.json and secrets from the stack's .env.
+func (s *RunStore) LoadOptions(name string) (domain.CreateOptions, error) {
+	meta, err := s.Load(name)
+	if err != nil {
+		return domain.CreateOptions{}, err
+	}
+	envFile, err := os.ReadFile(config.RunEnvPath(name))
+	if err != nil && !os.IsNotExist(err) {
+		return domain.CreateOptions{}, err
+	}
+	env, err := ParseEnvFile(envFile)
+	if err != nil {
+		return domain.CreateOptions{}, fmt.Errorf("read stack %q: %w", name, err)
+	}
+	opts := meta.Spec.Options(name)
+	applySecrets(&opts, env)
+	// Credentials given as references are returned as references.
+	opts.Auth = opts.Auth.WithRefs(meta.Spec.AuthRefs)
+	return opts, nil
+}
+
+// OptionsFromCompose is the inverse of ComposeYAML and EnvFile.
+func OptionsFromCompose(composeYAML, envFile []byte) (domain.CreateOptions, error) {
+	var cf composeFile
+	if err := yaml.Unmarshal(composeYAML, &cf); err != nil {
+		return domain.CreateOptions{}, fmt.Errorf("parse compose file: %w", err)
+	}
+	vibe, ok := cf.Services["vibecontainer"]
+	if !ok {
+		return domain.CreateOptions{}, fmt.Errorf("compose file has no vibecontainer service")
+	}
+	env, err := ParseEnvFile(envFile)
+	if err != nil {
+		return domain.CreateOptions{}, err
+	}
+
+	opts := domain.CreateOptions{
+		Name:           vibe.Labels[stackLabel],
+		Provider:       domain.Provider(vibe.Labels[providerLabel]),
+		FirewallEnable: vibe.Environment["FIREWALL_ENABLE"] == "1",
+		TunnelEnable:   hasService(cf, "cloudflared"),
+	}
+	if vibe.Image != DefaultImage(opts.Provider) {
+		opts.Image = vibe.Image
+	}
+	switch {
+	case vibe.Environment["TMUX_WEB_INTERACTIVE_ENABLE"] == "1":
+		opts.TmuxAccess = "write"
+	case vibe.Environment["TMUX_WEB_ENABLE"] == "1":
+		opts.TmuxAccess = "read"
+	default:
+		opts.TmuxAccess = "none"
+	}
+	for _, p := range vibe.Ports {
+		host, container, err := splitPortMapping(p)
+		if err != nil {
+			return domain.CreateOptions{}, err
+		}
+		switch container {
+		case 7681:
+			opts.ReadOnlyPort = host
+		case 7682:
+			opts.InteractivePort = host
+		}
+	}
+	for _, v := range vibe.Volumes {
+		if src, ok := strings.CutSuffix(v, ":/workspace"); ok && opts.WorkspacePath == "" {
+			opts.WorkspacePath = src
+			continue
+		}
+		opts.Mounts = append(opts.Mounts, v)
+	}
+	for k, v := range vibe.Environment {
+		if domain.IsManagedEnv(k) {
+			continue
+		}
+		if opts.Env == nil {
+			opts.Env = map[string]string{}
+		}
+		opts.Env[k] = strings.ReplaceAll(v, "$$", "$")
+	}
+
+	applySecrets(&opts, env)
+	return opts, nil
+}
+
+// applySecrets fills the secret fields of opts from the variables EnvFile
+// writes.
+func applySecrets(opts *domain.CreateOptions, env map[string]string) {
+	opts.TTYDCredential = env["TTYD_CREDENTIAL"]
+	opts.Auth = domain.Auth{
+		ClaudeOAuthToken: env["CLAUDE_CODE_OAUTH_TOKEN"],
+		AnthropicAPIKey:  env["ANTHROPIC_API_KEY"],
+		CodexAuthJSON:    env["CODEX_AUTH_JSON"],
+		OpenAIAPIKey:     env["OPENAI_API_KEY"],
+		CodexAPIKey:      env["CODEX_API_KEY"],
+		TunnelToken:      env["TUNNEL_TOKEN"],
+	}
+}
+
+// ParseEnvFile reads the KEY=VALUE lines written by EnvFile, undoing the
+// quoting applied by shellEscape.
+func ParseEnvFile(b []byte) (map[string]string, error) {
+	env := map[string]string{}
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid env line %q", key)
+		}
+		value, err := shellUnescape(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %w", key, err)
+		}
+		env[key] = value
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+func shellUnescape(v string) (string, error) {
+	var out strings.Builder
+	for i := 0; i < len(v); i++ {
+		switch q := v[i]; q {
+		case '\'', '"':
+			end := strings.IndexByte(v[i+1:], q)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated quote")
+			}
+			out.WriteString(v[i+1 : i+1+end])
+			i += end + 1
+		default:
+			out.WriteByte(q)
+		}
+	}
+	return out.String(), nil
+}
+
+func splitPortMapping(p string) (int, int, error) {
+	parts := strings.Split(p, ":")
+	if len(parts) < 2 {
+		return 0, 0, fmt.Errorf("invalid port mapping %q", p)
+	}
+	host, err := strconv.Atoi(parts[len(parts)-2])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port mapping %q", p)
+	}
+	container, err := strconv.Atoi(parts[len(parts)-1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port mapping %q", p)
+	}
+	return host, container, nil
+}
+
+func hasService(cf composeFile, name string) bool {
+	_, ok := cf.Services[name]
+	return ok
+}
@@ -0,0 +1,87 @@
+package stack
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/openhoo/vibecontainer/internal/domain"
+)
+
+func TestOptionsFromComposeRoundTrip(t *testing.T) {
+	opts := domain.CreateOptions{
+		Name:            "demo-stack",
+		WorkspacePath:   "/tmp/workspace",
+		Provider:        domain.ProviderCodex,
+		Image:           "example.com/custom:1",
+		ReadOnlyPort:    9001,
+		TmuxAccess:      "write",
+		InteractivePort: 9002,
+		TTYDCredential:  "user:p@ss",
+		FirewallEnable:  true,
+		TunnelEnable:    true,
+		Env:             map[string]string{"GIT_AUTHOR_NAME": "Dev $USER"},
+		Mounts:          []string{"/home/me/.gitconfig:/home/dev/.gitconfig:ro"},
+		Auth: domain.Auth{
+			TunnelToken:   "tok",
+			CodexAuthJSON: `{"OPENAI_API_KEY": "it's quoted"}`,
+		},
+	}
+	compose, _, err := ComposeYAML(opts)
+	if err != nil {
+		t.Fatalf("compose generation failed: %v", err)
+	}
+	got, err := OptionsFromCompose(compose, EnvFile(opts))
+	if err != nil {
+		t.Fatalf("OptionsFromCompose failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, opts) {
+		t.Fatalf("round trip mismatch\n got: %+v\nwant: %+v", got, opts)
+	}
+}
+
+func TestOptionsFromComposeDefaults(t *testing.T) {
+	opts := domain.CreateOptions{
+		Name:       "demo-stack",
+		Provider:   domain.ProviderClaude,
+		TmuxAccess: "none",
+		Auth:       domain.Auth{ClaudeOAuthToken: "oauth"},
+	}
+	compose, _, err := ComposeYAML(opts)
+	if err != nil {
+		t.Fatalf("compose generation failed: %v", err)
+	}
+	got, err := OptionsFromCompose(compose, EnvFile(opts))
+	if err != nil {
+		t.Fatalf("OptionsFromCompose failed: %v", err)
+	}
+	if got.Image != "" {
+		t.Fatalf("expected default image to read back as empty, got %q", got.Image)
+	}
+	if !reflect.DeepEqual(got, opts) {
+		t.Fatalf("round trip mismatch\n got: %+v\nwant: %+v", got, opts)
+	}
+}
+
+func TestDiffOptionsHidesSecrets(t *testing.T) {
+	old := domain.CreateOptions{
+		Provider:     domain.ProviderCodex,
+		TmuxAccess:   "read",
+		ReadOnlyPort: 7681,
+		Auth:         domain.Auth{OpenAIAPIKey: "sk-old"},
+	}
+	next := old
+	next.TmuxAccess = "write"
+	next.Auth.OpenAIAPIKey = "sk-new"
+
+	changes := DiffOptions(old, next)
+	want := []Change{
+		{Field: "Tmux Access", Old: "read", New: "write"},
+		{Field: "OpenAI API Key", Old: "(set)", New: "(changed)"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+	if len(DiffOptions(old, old)) != 0 {
+		t.Fatal("expected no changes for identical options")
+	}
+}
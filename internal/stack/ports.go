@@ -0,0 +1,120 @@
+package stack
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/openhoo/vibecontainer/internal/domain"
+)
+
+// PortRange is an inclusive range of host ports.
+type PortRange struct {
+	First, Last int
+}
+
+// ParsePortRange reads a range written as "first-last".
+func ParsePortRange(s string) (PortRange, error) {
+	first, last, ok := strings.Cut(strings.TrimSpace(s), "-")
+	if !ok {
+		return PortRange{}, fmt.Errorf("port range %q must be in first-last format", s)
+	}
+	var r PortRange
+	var err1, err2 error
+	r.First, err1 = strconv.Atoi(strings.TrimSpace(first))
+	r.Last, err2 = strconv.Atoi(strings.TrimSpace(last))
+	if err1 != nil || err2 != nil || r.First < 1 || r.Last > 65535 || r.First > r.Last {
+		return PortRange{}, fmt.Errorf("port range %q must be two ports between 1 and 65535, lowest first", s)
+	}
+	return r, nil
+}
+
+func (r PortRange) String() string {
+	return fmt.Sprintf("%d-%d", r.First, r.Last)
+}
+
+// PublishedPorts returns the host ports a stack publishes.
+func PublishedPorts(spec domain.StackSpec) []int {
+	var ports []int
+	if spec.TmuxAccess == "read" || spec.TmuxAccess == "write" {
+		ports = append(ports, spec.ReadOnlyPort)
+	}
+	if spec.TmuxAccess == "write" {
+		ports = append(ports, spec.InteractivePort)
+	}
+	return ports
+}
+
+// PortAllocator hands out host ports that neither another stack nor another
+// program on the host is using. Ports of stopped stacks count as used, since
+// the stack takes them back when it starts.
+type PortAllocator struct {
+	rng PortRange
+	// used maps ports to the stack that publishes them.
+	used map[int]string
+	// hostFree reports whether nothing on the host listens on a port.
+	hostFree func(port int) bool
+}
+
+// NewPortAllocator returns an allocator for ports on bind, the stack's bind
+// address setting, that stacks other than the one named self don't publish.
+func NewPortAllocator(stacks []domain.RunMetadata, self, bind string, rng PortRange) *PortAllocator {
+	addr := domain.PublishAddress(bind)
+	a := &PortAllocator{rng: rng, used: map[int]string{}, hostFree: func(port int) bool { return hostPortFree(addr, port) }}
+	for _, m := range stacks {
+		if m.Name == self {
+			continue
+		}
+		for _, p := range PublishedPorts(m.Spec) {
+			a.used[p] = m.Name
+		}
+	}
+	return a
+}
+
+// Check returns why port can't be published, or nil if it can.
+func (a *PortAllocator) Check(port int) error {
+	if name, ok := a.used[port]; ok {
+		if name == "" {
+			return fmt.Errorf("port %d is already taken by this stack", port)
+		}
+		return fmt.Errorf("port %d is already used by stack %s", port, name)
+	}
+	if !a.hostFree(port) {
+		return fmt.Errorf("port %d is already in use on this host", port)
+	}
+	return nil
+}
+
+// Resolve reserves port and returns it. domain.AutoPort, and a taken port
+// the user didn't pin, are replaced by the first free port in the range.
+func (a *PortAllocator) Resolve(port int, pinned bool) (int, error) {
+	if port != domain.AutoPort {
+		err := a.Check(port)
+		if err == nil {
+			a.used[port] = ""
+			return port, nil
+		}
+		if pinned {
+			return 0, err
+		}
+	}
+	for p := a.rng.First; p <= a.rng.Last; p++ {
+		if a.Check(p) == nil {
+			a.used[p] = ""
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("no free port in range %s; widen port_range in config.json", a.rng)
+}
+
+// hostPortFree tries to listen on port on the address ttyd is published on.
+func hostPortFree(addr string, port int) bool {
+	l, err := net.Listen("tcp", net.JoinHostPort(addr, strconv.Itoa(port)))
+	if err != nil {
+		return false
+	}
+	l.Close()
+	return true
+}
@@ -0,0 +1,53 @@
+package stack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openhoo/vibecontainer/internal/domain"
+)
+
+func TestParsePortRange(t *testing.T) {
+	r, err := ParsePortRange("7681-7780")
+	if err != nil {
+		t.Fatalf("ParsePortRange failed: %v", err)
+	}
+	if r != (PortRange{7681, 7780}) {
+		t.Fatalf("got %+v", r)
+	}
+	for _, bad := range []string{"7681", "7780-7681", "0-10", "1-70000", "a-b"} {
+		if _, err := ParsePortRange(bad); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestPortAllocator(t *testing.T) {
+	stacks := []domain.RunMetadata{
+		{Name: "one", Spec: domain.StackSpec{TmuxAccess: "write", ReadOnlyPort: 7681, InteractivePort: 7682}},
+		{Name: "two", Spec: domain.StackSpec{TmuxAccess: "read", ReadOnlyPort: 7683, InteractivePort: 7690}},
+		{Name: "self", Spec: domain.StackSpec{TmuxAccess: "read", ReadOnlyPort: 7685}},
+	}
+	a := NewPortAllocator(stacks, "self", "", PortRange{7681, 7686})
+	// 7684 is bound by something outside vibecontainer.
+	a.hostFree = func(port int) bool { return port != 7684 }
+
+	if err := a.Check(7682); err == nil || !strings.Contains(err.Error(), "stack one") {
+		t.Fatalf("expected 7682 to be used by stack one, got %v", err)
+	}
+	if err := a.Check(7690); err != nil {
+		t.Fatalf("an unpublished port is free: %v", err)
+	}
+	if _, err := a.Resolve(7681, true); err == nil {
+		t.Fatal("expected a pinned taken port to fail")
+	}
+	if got, err := a.Resolve(7681, false); err != nil || got != 7685 {
+		t.Fatalf("unpinned taken port: got %d, %v; want 7685", got, err)
+	}
+	if got, err := a.Resolve(domain.AutoPort, false); err != nil || got != 7686 {
+		t.Fatalf("auto port: got %d, %v; want 7686", got, err)
+	}
+	if _, err := a.Resolve(domain.AutoPort, false); err == nil || !strings.Contains(err.Error(), "no free port") {
+		t.Fatalf("expected the range to be exhausted, got %v", err)
+	}
+}
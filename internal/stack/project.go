@@ -0,0 +1,196 @@
+package stack
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/openhoo/vibecontainer/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Project is a stack's compose file with variables substituted the way
+// docker compose does, for backends that create containers themselves.
+type Project struct {
+	Name string
+	// Services are ordered so that every service comes after the ones it
+	// depends on.
+	Services []ProjectService
+}
+
+// ProjectService is a named service of a Project.
+type ProjectService struct {
+	Name string
+	Service
+}
+
+// Service returns the named service of the project.
+func (p Project) Service(name string) (ProjectService, bool) {
+	for _, s := range p.Services {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return ProjectService{}, false
+}
+
+// LoadProject reads the compose file and .env of a stack. As with docker
+// compose, variables set in the process environment win over the .env file.
+func LoadProject(name string) (Project, error) {
+	composeYAML, err := os.ReadFile(config.RunComposePath(name))
+	if err != nil {
+		return Project{}, fmt.Errorf("read stack %q: %w", name, err)
+	}
+	envFile, err := os.ReadFile(config.RunEnvPath(name))
+	if err != nil && !os.IsNotExist(err) {
+		return Project{}, fmt.Errorf("read stack %q: %w", name, err)
+	}
+	env, err := ParseEnvFile(envFile)
+	if err != nil {
+		return Project{}, fmt.Errorf("read stack %q: %w", name, err)
+	}
+	lookup := func(key string) (string, bool) {
+		if v, ok := os.LookupEnv(key); ok {
+			return v, true
+		}
+		v, ok := env[key]
+		return v, ok
+	}
+	return parseProject(name, composeYAML, lookup)
+}
+
+func parseProject(name string, composeYAML []byte, lookup func(string) (string, bool)) (Project, error) {
+	var cf composeFile
+	if err := yaml.Unmarshal(composeYAML, &cf); err != nil {
+		return Project{}, fmt.Errorf("parse compose file: %w", err)
+	}
+	sub := func(s string) string { return interpolate(s, lookup) }
+	subAll := func(in []string) []string {
+		out := make([]string, len(in))
+		for i, v := range in {
+			out[i] = sub(v)
+		}
+		return out
+	}
+	subMap := func(in map[string]string) map[string]string {
+		out := make(map[string]string, len(in))
+		for k, v := range in {
+			out[k] = sub(v)
+		}
+		return out
+	}
+
+	p := Project{Name: name}
+	for svcName, svc := range cf.Services {
+		svc.Image = sub(svc.Image)
+		svc.Container = sub(svc.Container)
+		svc.WorkingDir = sub(svc.WorkingDir)
+		svc.Command = sub(svc.Command)
+		svc.Environment = subMap(svc.Environment)
+		svc.Labels = subMap(svc.Labels)
+		svc.Volumes = subAll(svc.Volumes)
+		svc.Ports = subAll(svc.Ports)
+		if svc.Healthcheck != nil {
+			hc := *svc.Healthcheck
+			hc.Test = subAll(hc.Test)
+			svc.Healthcheck = &hc
+		}
+		p.Services = append(p.Services, ProjectService{Name: svcName, Service: svc})
+	}
+	ordered, err := startOrder(p.Services)
+	if err != nil {
+		return Project{}, err
+	}
+	p.Services = ordered
+	return p, nil
+}
+
+// startOrder sorts services so dependencies come first, breaking ties by
+// name.
+func startOrder(services []ProjectService) ([]ProjectService, error) {
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	byName := map[string]ProjectService{}
+	for _, s := range services {
+		byName[s.Name] = s
+	}
+	var out []ProjectService
+	state := map[string]int{} // 1 visiting, 2 done
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("compose file has a dependency cycle at service %s", name)
+		case 2:
+			return nil
+		}
+		svc, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("compose file depends on unknown service %s", name)
+		}
+		state[name] = 1
+		for _, dep := range svc.DependsOn {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		out = append(out, svc)
+		return nil
+	}
+	for _, s := range services {
+		if err := visit(s.Name); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// interpolate substitutes $VAR, ${VAR} and ${VAR:-default} and turns $$
+// into a literal $. Unset variables become empty, as in docker compose.
+func interpolate(s string, lookup func(string) (string, bool)) string {
+	if !strings.Contains(s, "$") {
+		return s
+	}
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			out.WriteByte(s[i])
+			continue
+		}
+		switch next := s[i+1]; {
+		case next == '$':
+			out.WriteByte('$')
+			i++
+		case next == '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				out.WriteString(s[i:])
+				return out.String()
+			}
+			expr := s[i+2 : i+2+end]
+			name, def, hasDef := strings.Cut(expr, ":-")
+			if v, ok := lookup(name); ok && (v != "" || !hasDef) {
+				out.WriteString(v)
+			} else {
+				out.WriteString(def)
+			}
+			i += 2 + end
+		case next == '_' || isAlpha(next):
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || isAlpha(s[j]) || s[j] >= '0' && s[j] <= '9') {
+				j++
+			}
+			v, _ := lookup(s[i+1 : j])
+			out.WriteString(v)
+			i = j - 1
+		default:
+			out.WriteByte('$')
+		}
+	}
+	return out.String()
+}
+
+func isAlpha(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
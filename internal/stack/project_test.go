@@ -0,0 +1,57 @@
+package stack
+
+import (
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	env := map[string]string{"TOKEN": "tok", "EMPTY": ""}
+	lookup := func(k string) (string, bool) {
+		v, ok := env[k]
+		return v, ok
+	}
+	for in, want := range map[string]string{
+		"${TOKEN}":             "tok",
+		"$TOKEN-x":             "tok-x",
+		"$$TOKEN":              "$TOKEN",
+		"${MISSING}":           "",
+		"${MISSING:-fallback}": "fallback",
+		"${EMPTY:-fallback}":   "fallback",
+		"price: $5":            "price: $5",
+		"plain":                "plain",
+		"${UNTERMINATED":       "${UNTERMINATED",
+	} {
+		if got := interpolate(in, lookup); got != want {
+			t.Errorf("interpolate(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParseProjectOrdersDependencies(t *testing.T) {
+	compose := []byte(`
+services:
+  cloudflared:
+    image: cf
+    depends_on: [vibecontainer]
+    environment:
+      TUNNEL_TOKEN: ${TUNNEL_TOKEN}
+  vibecontainer:
+    image: vibe
+`)
+	p, err := parseProject("demo", compose, func(k string) (string, bool) { return "tok", k == "TUNNEL_TOKEN" })
+	if err != nil {
+		t.Fatalf("parseProject failed: %v", err)
+	}
+	if len(p.Services) != 2 || p.Services[0].Name != "vibecontainer" || p.Services[1].Name != "cloudflared" {
+		t.Fatalf("unexpected order %+v", p.Services)
+	}
+	if p.Services[1].Environment["TUNNEL_TOKEN"] != "tok" {
+		t.Fatalf("expected the token to be substituted, got %v", p.Services[1].Environment)
+	}
+
+	if _, err := parseProject("demo", []byte("services:\n  a:\n    depends_on: [b]\n  b:\n    depends_on: [a]\n"), lookupNone); err == nil {
+		t.Fatalf("expected a dependency cycle error")
+	}
+}
+
+func lookupNone(string) (string, bool) { return "", false }
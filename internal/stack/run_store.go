@@ -1,8 +1,10 @@
 package stack
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
@@ -10,35 +12,77 @@ import (
 
 	"github.com/openhoo/vibecontainer/internal/config"
 	"github.com/openhoo/vibecontainer/internal/domain"
+	"github.com/openhoo/vibecontainer/internal/fsutil"
+	"github.com/openhoo/vibecontainer/internal/secretref"
+	"github.com/openhoo/vibecontainer/internal/validate"
 )
 
 type RunStore struct{}
 
 func NewRunStore() *RunStore { return &RunStore{} }
 
-func (s *RunStore) Save(opts domain.CreateOptions) (domain.RunMetadata, error) {
+func (s *RunStore) Save(ctx context.Context, opts domain.CreateOptions) (domain.RunMetadata, error) {
+	return s.write(ctx, opts, domain.RunMetadata{CreatedAt: time.Now().UTC()})
+}
+
+// Adopt saves a new stack that takes over container, which keeps running
+// until FinishAdoption is called after the stack's own containers start.
+func (s *RunStore) Adopt(ctx context.Context, opts domain.CreateOptions, container string) (domain.RunMetadata, error) {
+	return s.write(ctx, opts, domain.RunMetadata{CreatedAt: time.Now().UTC(), AdoptedFrom: container})
+}
+
+// FinishAdoption records that an adopted container has been replaced.
+func (s *RunStore) FinishAdoption(name string) error {
+	meta, err := s.Load(name)
+	if err != nil {
+		return err
+	}
+	meta.AdoptedFrom = ""
+	return s.writeMeta(meta)
+}
+
+// Update re-renders the compose file and .env of an existing stack from
+// opts, keeping its original creation time.
+func (s *RunStore) Update(ctx context.Context, opts domain.CreateOptions) (domain.RunMetadata, error) {
+	prev, err := s.Load(opts.Name)
+	if err != nil {
+		return domain.RunMetadata{}, err
+	}
+	return s.write(ctx, opts, prev)
+}
+
+// write renders opts into the stack's run dir. CreatedAt and AdoptedFrom
+// are carried over from prev. Secret references are resolved into .env and
+// kept in run.json.
+func (s *RunStore) write(ctx context.Context, opts domain.CreateOptions, prev domain.RunMetadata) (domain.RunMetadata, error) {
+	resolved, err := resolveSecrets(ctx, opts)
+	if err != nil {
+		return domain.RunMetadata{}, err
+	}
 	runDir := config.RunDir(opts.Name)
 	if err := os.MkdirAll(runDir, 0o700); err != nil {
 		return domain.RunMetadata{}, err
 	}
-	compose, image, err := ComposeYAML(opts)
+	compose, image, err := ComposeYAML(resolved)
 	if err != nil {
 		return domain.RunMetadata{}, err
 	}
-	if err := os.WriteFile(config.RunComposePath(opts.Name), compose, 0o600); err != nil {
+	if err := fsutil.WriteFile(config.RunComposePath(opts.Name), compose, 0o600); err != nil {
 		return domain.RunMetadata{}, err
 	}
-	if err := os.WriteFile(config.RunEnvPath(opts.Name), EnvFile(opts), 0o600); err != nil {
+	if err := fsutil.WriteFile(config.RunEnvPath(opts.Name), EnvFile(resolved), 0o600); err != nil {
 		return domain.RunMetadata{}, err
 	}
-	now := time.Now().UTC()
 	meta := domain.RunMetadata{
-		Name:      opts.Name,
-		Workspace: opts.WorkspacePath,
-		Provider:  opts.Provider,
-		Image:     image,
-		CreatedAt: now,
-		UpdatedAt: now,
+		Version:     domain.RunMetadataVersion,
+		Name:        opts.Name,
+		Workspace:   opts.WorkspacePath,
+		Provider:    opts.Provider,
+		Image:       image,
+		CreatedAt:   prev.CreatedAt,
+		UpdatedAt:   time.Now().UTC(),
+		Spec:        domain.SpecFromOptions(opts),
+		AdoptedFrom: prev.AdoptedFrom,
 	}
 	if err := s.writeMeta(meta); err != nil {
 		return domain.RunMetadata{}, err
@@ -46,6 +90,46 @@ func (s *RunStore) Save(opts domain.CreateOptions) (domain.RunMetadata, error) {
 	return meta, nil
 }
 
+// RefreshSecrets resolves the secret references of a stack again and
+// rewrites its .env, so a start picks up secrets changed in the secret
+// manager. Stacks without references are left alone. The caller must hold
+// the stack's Lock.
+func (s *RunStore) RefreshSecrets(ctx context.Context, name string) error {
+	meta, err := s.Load(name)
+	if err != nil {
+		return err
+	}
+	if len(meta.Spec.AuthRefs) == 0 {
+		return nil
+	}
+	opts, err := s.LoadOptions(name)
+	if err != nil {
+		return err
+	}
+	resolved, err := resolveSecrets(ctx, opts)
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFile(config.RunEnvPath(name), EnvFile(resolved), 0o600)
+}
+
+// resolveSecrets returns opts with the secret references in its Auth
+// replaced by the secrets they name, which are checked like credentials
+// given directly.
+func resolveSecrets(ctx context.Context, opts domain.CreateOptions) (domain.CreateOptions, error) {
+	auth, err := secretref.ResolveAuth(ctx, opts.Auth)
+	if err == nil {
+		err = validate.ResolvedAuth(auth, opts.Auth.Refs())
+	}
+	if err != nil {
+		return domain.CreateOptions{}, fmt.Errorf("stack %s: %w", opts.Name, err)
+	}
+	opts.Auth = auth
+	return opts, nil
+}
+
+// Touch records that the stack changed. It reads and rewrites run.json, so
+// the caller must hold the stack's Lock.
 func (s *RunStore) Touch(name string) error {
 	meta, err := s.Load(name)
 	if err != nil {
@@ -64,6 +148,9 @@ func (s *RunStore) Load(name string) (domain.RunMetadata, error) {
 	if err := json.Unmarshal(b, &meta); err != nil {
 		return domain.RunMetadata{}, err
 	}
+	if meta.Version < domain.RunMetadataVersion {
+		return migrate(meta)
+	}
 	return meta, nil
 }
 
@@ -72,30 +159,53 @@ func (s *RunStore) Exists(name string) bool {
 	return err == nil
 }
 
-func (s *RunStore) List() ([]domain.RunMetadata, error) {
+// List returns the metadata of every stack. Stacks whose run.json can't be
+// read are left out and reported in warnings, so one broken stack doesn't
+// block commands about the others.
+func (s *RunStore) List() (metas []domain.RunMetadata, warnings []string, err error) {
 	if err := os.MkdirAll(config.RunsDir(), 0o700); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	entries, err := os.ReadDir(config.RunsDir())
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	out := make([]domain.RunMetadata, 0, len(entries))
+	metas = make([]domain.RunMetadata, 0, len(entries))
 	for _, e := range entries {
 		if !e.IsDir() {
 			continue
 		}
 		meta, err := s.Load(e.Name())
 		if err != nil {
-			if errors.Is(err, os.ErrNotExist) {
-				continue
+			if !errors.Is(err, os.ErrNotExist) {
+				warnings = append(warnings, fmt.Sprintf("skipping stack %s: %v", e.Name(), err))
 			}
-			return nil, err
+			continue
+		}
+		metas = append(metas, meta)
+	}
+	sort.Slice(metas, func(i, j int) bool { return metas[i].Name < metas[j].Name })
+	return metas, warnings, nil
+}
+
+// Names returns the names of all run directories, including ones whose
+// run.json is missing and which List therefore skips.
+func (s *RunStore) Names() ([]string, error) {
+	entries, err := os.ReadDir(config.RunsDir())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
 		}
-		out = append(out, meta)
 	}
-	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
-	return out, nil
+	sort.Strings(names)
+	return names, nil
 }
 
 func (s *RunStore) Delete(name string) error {
@@ -111,5 +221,5 @@ func (s *RunStore) writeMeta(meta domain.RunMetadata) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, append(b, '\n'), 0o600)
+	return fsutil.WriteFile(path, append(b, '\n'), 0o600)
 }
@@ -0,0 +1,197 @@
+package stack
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/adrg/xdg"
+	"github.com/openhoo/vibecontainer/internal/config"
+	"github.com/openhoo/vibecontainer/internal/domain"
+)
+
+// useTempDataDir points the XDG data dir, and with it config.RunsDir, at a
+// fresh temp directory for the duration of the test.
+func useTempDataDir(t *testing.T) {
+	t.Helper()
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	xdg.Reload()
+	t.Cleanup(xdg.Reload)
+}
+
+func TestRunStoreSaveRecordsSpec(t *testing.T) {
+	useTempDataDir(t)
+	store := NewRunStore()
+	opts := domain.CreateOptions{
+		Name:            "demo-stack",
+		Provider:        domain.ProviderCodex,
+		ReadOnlyPort:    9001,
+		InteractivePort: 9002,
+		TmuxAccess:      "read",
+		TTYDCredential:  "user:pass",
+		FirewallEnable:  true,
+		Auth:            domain.Auth{OpenAIAPIKey: "sk-123"},
+	}
+	if _, err := store.Save(context.Background(), opts); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	meta, err := store.Load("demo-stack")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if meta.Version != domain.RunMetadataVersion {
+		t.Fatalf("expected version %d, got %d", domain.RunMetadataVersion, meta.Version)
+	}
+	if meta.Spec.InteractivePort != 9002 || !meta.Spec.TTYDCredentialSet {
+		t.Fatalf("unexpected spec: %+v", meta.Spec)
+	}
+	if meta.Spec.ReadOnlyURL() != "http://127.0.0.1:9001" || meta.Spec.InteractiveURL() != "" {
+		t.Fatalf("unexpected URLs: %q %q", meta.Spec.ReadOnlyURL(), meta.Spec.InteractiveURL())
+	}
+
+	loaded, err := store.LoadOptions("demo-stack")
+	if err != nil {
+		t.Fatalf("LoadOptions failed: %v", err)
+	}
+	if loaded.TTYDCredential != "user:pass" || loaded.Auth.OpenAIAPIKey != "sk-123" || loaded.InteractivePort != 9002 {
+		t.Fatalf("unexpected options: %+v", loaded)
+	}
+}
+
+func TestRunStoreSecretRefs(t *testing.T) {
+	useTempDataDir(t)
+	t.Setenv("VC_TEST_OPENAI", "sk-first")
+	store := NewRunStore()
+	opts := domain.CreateOptions{
+		Name:     "ref-stack",
+		Provider: domain.ProviderCodex,
+		Auth:     domain.Auth{OpenAIAPIKey: "env:VC_TEST_OPENAI"},
+	}
+	if _, err := store.Save(context.Background(), opts); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	readEnv := func() map[string]string {
+		t.Helper()
+		b, err := os.ReadFile(config.RunEnvPath("ref-stack"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		env, err := ParseEnvFile(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return env
+	}
+	if got := readEnv()["OPENAI_API_KEY"]; got != "sk-first" {
+		t.Fatalf(".env should hold the resolved secret, got %q", got)
+	}
+	loaded, err := store.LoadOptions("ref-stack")
+	if err != nil {
+		t.Fatalf("LoadOptions failed: %v", err)
+	}
+	if loaded.Auth.OpenAIAPIKey != "env:VC_TEST_OPENAI" {
+		t.Fatalf("LoadOptions should return the reference, got %q", loaded.Auth.OpenAIAPIKey)
+	}
+
+	t.Setenv("VC_TEST_OPENAI", "sk-rotated")
+	if err := store.RefreshSecrets(context.Background(), "ref-stack"); err != nil {
+		t.Fatalf("RefreshSecrets failed: %v", err)
+	}
+	if got := readEnv()["OPENAI_API_KEY"]; got != "sk-rotated" {
+		t.Fatalf("RefreshSecrets should resolve again, got %q", got)
+	}
+
+	t.Setenv("VC_TEST_OPENAI", "sk one")
+	if err := store.RefreshSecrets(context.Background(), "ref-stack"); err == nil || !strings.Contains(err.Error(), "OPENAI_API_KEY resolved from env:VC_TEST_OPENAI") {
+		t.Fatalf("expected a resolved secret with spaces to fail, got %v", err)
+	}
+
+	t.Setenv("VC_TEST_OPENAI", "")
+	if err := store.RefreshSecrets(context.Background(), "ref-stack"); err == nil {
+		t.Fatal("expected an unresolvable reference to fail")
+	}
+}
+
+func TestRunStoreMigratesVersion1(t *testing.T) {
+	useTempDataDir(t)
+	store := NewRunStore()
+	opts := domain.CreateOptions{
+		Name:            "old-stack",
+		WorkspacePath:   "/src",
+		Provider:        domain.ProviderClaude,
+		ReadOnlyPort:    9001,
+		InteractivePort: 9002,
+		TmuxAccess:      "write",
+		TunnelEnable:    true,
+		Auth:            domain.Auth{ClaudeOAuthToken: "oauth", TunnelToken: "tok"},
+	}
+	if _, err := store.Save(context.Background(), opts); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	// Rewrite run.json the way version 1 did: no version and no spec.
+	v1 := map[string]any{
+		"name":      "old-stack",
+		"workspace": "/src",
+		"provider":  "claude",
+		"image":     DefaultImage(domain.ProviderClaude),
+	}
+	b, _ := json.Marshal(v1)
+	if err := os.WriteFile(config.RunMetadataPath("old-stack"), b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	meta, err := store.Load("old-stack")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	want := domain.SpecFromOptions(opts)
+	if !reflect.DeepEqual(meta.Spec, want) {
+		t.Fatalf("migrated spec mismatch\n got: %+v\nwant: %+v", meta.Spec, want)
+	}
+
+	onDisk := func() domain.RunMetadata {
+		t.Helper()
+		var meta domain.RunMetadata
+		b, _ := os.ReadFile(config.RunMetadataPath("old-stack"))
+		if err := json.Unmarshal(b, &meta); err != nil {
+			t.Fatal(err)
+		}
+		return meta
+	}
+	if v := onDisk().Version; v != 0 {
+		t.Fatalf("Load takes no lock and must not rewrite run.json, found version %d", v)
+	}
+	if err := store.Touch("old-stack"); err != nil {
+		t.Fatalf("Touch failed: %v", err)
+	}
+	if got := onDisk(); got.Version != domain.RunMetadataVersion || !reflect.DeepEqual(got.Spec, want) {
+		t.Fatalf("expected the next write to persist the migration, got %+v", got)
+	}
+}
+
+func TestRunStoreListSkipsBrokenStacks(t *testing.T) {
+	useTempDataDir(t)
+	store := NewRunStore()
+	if _, err := store.Save(context.Background(), domain.CreateOptions{Name: "good", Provider: domain.ProviderBase}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := os.MkdirAll(config.RunDir("broken"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(config.RunMetadataPath("broken"), []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	metas, warnings, err := store.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(metas) != 1 || metas[0].Name != "good" {
+		t.Fatalf("expected only the good stack, got %+v", metas)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "broken") {
+		t.Fatalf("expected a warning naming the broken stack, got %q", warnings)
+	}
+}
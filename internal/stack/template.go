@@ -2,7 +2,9 @@ package stack
 
 import (
 	"fmt"
+	"net"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/openhoo/vibecontainer/internal/domain"
@@ -15,8 +17,28 @@ const (
 	providerLabel = "com.openhoo.vibecontainer.provider"
 	serviceLabel  = "com.openhoo.vibecontainer.service"
 	versionLabel  = "com.openhoo.vibecontainer.version"
+
+	// Settings recorded so a stack can be rebuilt from its containers.
+	workspaceLabel       = "com.openhoo.vibecontainer.workspace"
+	tmuxAccessLabel      = "com.openhoo.vibecontainer.tmux-access"
+	readOnlyPortLabel    = "com.openhoo.vibecontainer.readonly-port"
+	interactivePortLabel = "com.openhoo.vibecontainer.interactive-port"
+	firewallLabel        = "com.openhoo.vibecontainer.firewall"
+	tunnelLabel          = "com.openhoo.vibecontainer.tunnel"
+	bindAddressLabel     = "com.openhoo.vibecontainer.bind-address"
+
+	// labelsVersion is bumped whenever commonLabels records new settings.
+	labelsVersion = "2"
 )
 
+// CloudflaredImage is the image of the tunnel sidecar.
+const CloudflaredImage = "cloudflare/cloudflared:2026.2.0"
+
+// cloudflaredMetrics is where the sidecar serves its metrics and /ready
+// endpoint. It shares the vibecontainer's network, so loopback keeps it
+// private.
+const cloudflaredMetrics = "127.0.0.1:20241"
+
 func DefaultImage(provider domain.Provider) string {
 	switch provider {
 	case domain.ProviderBase:
@@ -31,10 +53,11 @@ func DefaultImage(provider domain.Provider) string {
 }
 
 type composeFile struct {
-	Services map[string]service `yaml:"services"`
+	Services map[string]Service `yaml:"services"`
 }
 
-type service struct {
+// Service is one service of the compose file ComposeYAML writes.
+type Service struct {
 	Image       string            `yaml:"image"`
 	Container   string            `yaml:"container_name,omitempty"`
 	WorkingDir  string            `yaml:"working_dir,omitempty"`
@@ -46,9 +69,20 @@ type service struct {
 	Command     string            `yaml:"command,omitempty"`
 	NetworkMode string            `yaml:"network_mode,omitempty"`
 	Restart     string            `yaml:"restart,omitempty"`
+	Healthcheck *Healthcheck      `yaml:"healthcheck,omitempty"`
 	Labels      map[string]string `yaml:"labels,omitempty"`
 }
 
+// Healthcheck is the healthcheck of a compose service. Durations use Go
+// syntax, which compose accepts.
+type Healthcheck struct {
+	Test        []string `yaml:"test"`
+	Interval    string   `yaml:"interval,omitempty"`
+	Timeout     string   `yaml:"timeout,omitempty"`
+	Retries     int      `yaml:"retries,omitempty"`
+	StartPeriod string   `yaml:"start_period,omitempty"`
+}
+
 func ComposeYAML(opts domain.CreateOptions) ([]byte, string, error) {
 	image := opts.Image
 	if strings.TrimSpace(image) == "" {
@@ -56,10 +90,17 @@ func ComposeYAML(opts domain.CreateOptions) ([]byte, string, error) {
 	}
 
 	tmuxEnabled := opts.TmuxAccess == "read" || opts.TmuxAccess == "write"
-	env := map[string]string{
+	env := map[string]string{}
+	for k, v := range opts.Env {
+		// Compose interpolates "$" in values; user values are literal.
+		env[k] = strings.ReplaceAll(v, "$", "$$")
+	}
+	for k, v := range map[string]string{
 		"TMUX_WEB_ENABLE":             boolTo01(tmuxEnabled),
 		"TMUX_WEB_INTERACTIVE_ENABLE": boolTo01(opts.TmuxAccess == "write"),
 		"FIREWALL_ENABLE":             boolTo01(opts.FirewallEnable),
+	} {
+		env[k] = v
 	}
 	if opts.TTYDCredential != "" {
 		env["TTYD_CREDENTIAL"] = "${TTYD_CREDENTIAL}"
@@ -87,40 +128,51 @@ func ComposeYAML(opts domain.CreateOptions) ([]byte, string, error) {
 
 	var ports []string
 	if tmuxEnabled {
-		ports = append(ports, fmt.Sprintf("127.0.0.1:%d:7681", opts.ReadOnlyPort))
+		ports = append(ports, publishPort(opts.BindAddress, opts.ReadOnlyPort, 7681))
 	}
 	if opts.TmuxAccess == "write" {
-		ports = append(ports, fmt.Sprintf("127.0.0.1:%d:7682", opts.InteractivePort))
+		ports = append(ports, publishPort(opts.BindAddress, opts.InteractivePort, 7682))
 	}
 
 	labelsVibe := commonLabels(opts, "vibecontainer")
-	vibeService := service{
+	vibeService := Service{
 		Image:       image,
 		Container:   opts.Name + "-vibecontainer",
 		CapAdd:      []string{"NET_ADMIN", "NET_RAW"},
 		Environment: env,
 		Ports:       ports,
 		Restart:     "unless-stopped",
+		Healthcheck: vibeHealthcheck(opts),
 		Labels:      labelsVibe,
 	}
 	if strings.TrimSpace(opts.WorkspacePath) != "" {
 		vibeService.WorkingDir = "/workspace"
 		vibeService.Volumes = []string{fmt.Sprintf("%s:/workspace", opts.WorkspacePath)}
 	}
+	vibeService.Volumes = append(vibeService.Volumes, opts.Mounts...)
 
-	services := map[string]service{
+	services := map[string]Service{
 		"vibecontainer": vibeService,
 	}
 	if opts.TunnelEnable {
-		services["cloudflared"] = service{
-			Image:       "cloudflare/cloudflared:2026.2.0",
+		services["cloudflared"] = Service{
+			Image:       CloudflaredImage,
 			Container:   opts.Name + "-cloudflared",
-			Command:     "tunnel run",
+			Command:     "tunnel --metrics " + cloudflaredMetrics + " run",
 			Environment: map[string]string{"TUNNEL_TOKEN": "${TUNNEL_TOKEN}"},
 			DependsOn:   []string{"vibecontainer"},
 			NetworkMode: "service:vibecontainer",
 			Restart:     "unless-stopped",
-			Labels:      commonLabels(opts, "cloudflared"),
+			// The image has no shell; `tunnel ready` asks the metrics
+			// server whether the tunnel is connected.
+			Healthcheck: &Healthcheck{
+				Test:        []string{"CMD", "cloudflared", "tunnel", "--metrics", cloudflaredMetrics, "ready"},
+				Interval:    "10s",
+				Timeout:     "5s",
+				Retries:     3,
+				StartPeriod: "20s",
+			},
+			Labels: commonLabels(opts, "cloudflared"),
 		}
 	}
 	compose := composeFile{Services: services}
@@ -132,7 +184,61 @@ func ComposeYAML(opts domain.CreateOptions) ([]byte, string, error) {
 	return b, image, nil
 }
 
+// vibeHealthcheck checks that the tmux session is up and that each enabled
+// ttyd answers. It runs bash for /dev/tcp; "$$" escapes compose
+// interpolation.
+func vibeHealthcheck(opts domain.CreateOptions) *Healthcheck {
+	checks := []string{`gosu dev tmux has-session -t "$${TMUX_SESSION_NAME:-vibe}"`}
+	if opts.TmuxAccess == "read" || opts.TmuxAccess == "write" {
+		checks = append(checks, ": >/dev/tcp/127.0.0.1/7681")
+	}
+	if opts.TmuxAccess == "write" {
+		checks = append(checks, ": >/dev/tcp/127.0.0.1/7682")
+	}
+	return &Healthcheck{
+		Test:        []string{"CMD", "bash", "-c", strings.Join(checks, " && ")},
+		Interval:    "10s",
+		Timeout:     "5s",
+		Retries:     3,
+		StartPeriod: "30s",
+	}
+}
+
 func EnvFile(opts domain.CreateOptions) []byte {
+	return formatEnv(secretEnv(opts))
+}
+
+// redacted replaces secret values in RedactedEnvFile.
+const redacted = "<redacted>"
+
+// RedactedEnvFile is EnvFile with every value replaced by a placeholder, so
+// it shows which credentials a stack gets without revealing them. Secret
+// references are kept, as they name a secret without holding it.
+func RedactedEnvFile(opts domain.CreateOptions) []byte {
+	env := secretEnv(opts)
+	for k, v := range env {
+		if !domain.IsSecretRef(v) {
+			env[k] = redacted
+		}
+	}
+	return formatEnv(env)
+}
+
+func formatEnv(env map[string]string) []byte {
+	if len(env) == 0 {
+		return []byte{}
+	}
+	lines := make([]string, 0, len(env))
+	for k, v := range env {
+		lines = append(lines, fmt.Sprintf("%s=%s", k, shellEscape(v)))
+	}
+	sort.Strings(lines)
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// secretEnv returns the variables EnvFile writes: the credentials that
+// ComposeYAML references but never contains.
+func secretEnv(opts domain.CreateOptions) map[string]string {
 	env := map[string]string{}
 	if opts.TunnelEnable && strings.TrimSpace(opts.Auth.TunnelToken) != "" {
 		env["TUNNEL_TOKEN"] = opts.Auth.TunnelToken
@@ -159,25 +265,34 @@ func EnvFile(opts domain.CreateOptions) []byte {
 			env["CODEX_API_KEY"] = opts.Auth.CodexAPIKey
 		}
 	}
-	if len(env) == 0 {
-		return []byte{}
-	}
-	lines := make([]string, 0, len(env))
-	for k, v := range env {
-		lines = append(lines, fmt.Sprintf("%s=%s", k, shellEscape(v)))
-	}
-	sort.Strings(lines)
-	return []byte(strings.Join(lines, "\n") + "\n")
+	return env
 }
 
 func commonLabels(opts domain.CreateOptions, serviceName string) map[string]string {
-	return map[string]string{
-		managedLabel:  "true",
-		stackLabel:    opts.Name,
-		providerLabel: string(opts.Provider),
-		serviceLabel:  serviceName,
-		versionLabel:  "1",
+	labels := map[string]string{
+		managedLabel:         "true",
+		stackLabel:           opts.Name,
+		providerLabel:        string(opts.Provider),
+		serviceLabel:         serviceName,
+		versionLabel:         labelsVersion,
+		tmuxAccessLabel:      opts.TmuxAccess,
+		readOnlyPortLabel:    strconv.Itoa(opts.ReadOnlyPort),
+		interactivePortLabel: strconv.Itoa(opts.InteractivePort),
+		firewallLabel:        strconv.FormatBool(opts.FirewallEnable),
+		tunnelLabel:          strconv.FormatBool(opts.TunnelEnable),
+	}
+	if opts.WorkspacePath != "" {
+		labels[workspaceLabel] = opts.WorkspacePath
+	}
+	if opts.BindAddress != "" {
+		labels[bindAddressLabel] = opts.BindAddress
 	}
+	return labels
+}
+
+// publishPort is the compose port mapping of a ttyd port.
+func publishPort(bind string, hostPort, containerPort int) string {
+	return fmt.Sprintf("%s:%d", net.JoinHostPort(domain.PublishAddress(bind), strconv.Itoa(hostPort)), containerPort)
 }
 
 func shellEscape(v string) string {
@@ -126,6 +126,18 @@ func TestEnvFileContainsClaudeSecrets(t *testing.T) {
 	}
 }
 
+func TestRedactedEnvFileHidesValues(t *testing.T) {
+	env := string(RedactedEnvFile(domain.CreateOptions{
+		Provider:       domain.ProviderClaude,
+		TTYDCredential: "dev:secret",
+		Auth:           domain.Auth{AnthropicAPIKey: "ant-key"},
+	}))
+	want := "ANTHROPIC_API_KEY=<redacted>\nTTYD_CREDENTIAL=<redacted>\n"
+	if env != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", env, want)
+	}
+}
+
 func TestComposeYAMLOmitsWorkspaceWhenUnset(t *testing.T) {
 	opts := domain.CreateOptions{
 		Name:            "demo-stack",
@@ -148,3 +160,71 @@ func TestComposeYAMLOmitsWorkspaceWhenUnset(t *testing.T) {
 		t.Fatal("expected no workspace mount by default")
 	}
 }
+
+func TestComposeYAMLHealthchecks(t *testing.T) {
+	opts := domain.CreateOptions{
+		Name:         "demo-stack",
+		Provider:     domain.ProviderBase,
+		TmuxAccess:   "read",
+		TunnelEnable: true,
+	}
+	b, _, err := ComposeYAML(opts)
+	if err != nil {
+		t.Fatalf("compose generation failed: %v", err)
+	}
+	project, err := parseProject("demo-stack", b, func(string) (string, bool) { return "", false })
+	if err != nil {
+		t.Fatalf("parse generated compose file: %v", err)
+	}
+	vibe, _ := project.Service("vibecontainer")
+	if vibe.Healthcheck == nil {
+		t.Fatal("expected a vibecontainer healthcheck")
+	}
+	script := vibe.Healthcheck.Test[len(vibe.Healthcheck.Test)-1]
+	// "$$" in the file reaches the container as a literal "$".
+	if !strings.Contains(script, `tmux has-session -t "${TMUX_SESSION_NAME:-vibe}"`) || !strings.Contains(script, "/dev/tcp/127.0.0.1/7681") {
+		t.Fatalf("unexpected healthcheck script %q", script)
+	}
+	if strings.Contains(script, "7682") {
+		t.Fatalf("read-only stack must not check the interactive port: %q", script)
+	}
+	cloudflared, _ := project.Service("cloudflared")
+	if cloudflared.Healthcheck == nil || !strings.Contains(strings.Join(cloudflared.Healthcheck.Test, " "), "ready") {
+		t.Fatalf("expected a cloudflared readiness check, got %+v", cloudflared.Healthcheck)
+	}
+	if !strings.Contains(cloudflared.Command, "--metrics "+cloudflaredMetrics) {
+		t.Fatalf("expected cloudflared to serve metrics on %s, got %q", cloudflaredMetrics, cloudflared.Command)
+	}
+}
+
+func TestComposeYAMLBindAddress(t *testing.T) {
+	cases := []struct {
+		bind, readOnly, interactive, url string
+	}{
+		{"", "127.0.0.1:9001:7681", "127.0.0.1:9002:7682", "http://127.0.0.1:9002"},
+		{"100.101.102.103", "100.101.102.103:9001:7681", "100.101.102.103:9002:7682", "http://100.101.102.103:9002"},
+		{"0.0.0.0", "0.0.0.0:9001:7681", "0.0.0.0:9002:7682", "http://127.0.0.1:9002"},
+		{"fd7a:115c:a1e0::1", "'[fd7a:115c:a1e0::1]:9001:7681'", "'[fd7a:115c:a1e0::1]:9002:7682'", "http://[fd7a:115c:a1e0::1]:9002"},
+	}
+	for _, tc := range cases {
+		opts := domain.CreateOptions{
+			Name:            "demo-stack",
+			Provider:        domain.ProviderBase,
+			ReadOnlyPort:    9001,
+			TmuxAccess:      "write",
+			InteractivePort: 9002,
+			TTYDCredential:  "dev:secret",
+			BindAddress:     tc.bind,
+		}
+		b, _, err := ComposeYAML(opts)
+		if err != nil {
+			t.Fatalf("compose generation failed: %v", err)
+		}
+		if s := string(b); !strings.Contains(s, "- "+tc.readOnly) || !strings.Contains(s, "- "+tc.interactive) {
+			t.Fatalf("bind %q: expected ports %s and %s in:\n%s", tc.bind, tc.readOnly, tc.interactive, s)
+		}
+		if got := domain.SpecFromOptions(opts).InteractiveURL(); got != tc.url {
+			t.Fatalf("bind %q: got URL %s, want %s", tc.bind, got, tc.url)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/openhoo/vibecontainer/internal/domain"
+)
+
+// dashboardRefresh is how often the dashboard polls container state when
+// it can't watch container events.
+const dashboardRefresh = 2 * time.Second
+
+// dashboardResync is how often the dashboard reloads while it watches
+// events, to pick up run directories created or removed elsewhere.
+const dashboardResync = 30 * time.Second
+
+// DashboardStack is one row of the dashboard: a stack and the state of its
+// containers.
+type DashboardStack struct {
+	Meta   domain.RunMetadata
+	States []string
+	Health []string
+}
+
+// TerminalFunc runs something that takes over the terminal, such as
+// attaching to tmux or following logs.
+type TerminalFunc func(stdin io.Reader, stdout, stderr io.Writer) error
+
+// DashboardActions connects the dashboard to stacks. Every field is
+// required.
+type DashboardActions struct {
+	Load    func(ctx context.Context) ([]DashboardStack, error)
+	Start   func(ctx context.Context, name string) error
+	Stop    func(ctx context.Context, name string) error
+	Restart func(ctx context.Context, name string) error
+	Remove  func(ctx context.Context, name string) error
+	Logs    func(name string) TerminalFunc
+	Attach  func(name string) TerminalFunc
+	Open    func(url string) error
+	// Watch calls changed whenever container state may have changed, until
+	// ctx is canceled. When it fails the dashboard falls back to polling.
+	Watch func(ctx context.Context, changed func()) error
+}
+
+// RunDashboard shows a full-screen, self-refreshing view of all stacks
+// until the user quits.
+func RunDashboard(actions DashboardActions) error {
+	p := tea.NewProgram(&dashboardModel{actions: actions}, tea.WithAltScreen())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		err := actions.Watch(ctx, func() { p.Send(changedMsg{}) })
+		p.Send(watchEndedMsg{err: err})
+	}()
+	_, err := p.Run()
+	return err
+}
+
+type (
+	stacksMsg struct {
+		stacks []DashboardStack
+		err    error
+		// poll is set on the periodic refresh, which schedules the next.
+		poll bool
+		gen  int
+		// seq orders loads, so a slow one can't overwrite a newer result.
+		seq int
+	}
+	tickMsg struct{ gen int }
+	// changedMsg reports a container event.
+	changedMsg struct{}
+	// watchEndedMsg reports that Watch stopped.
+	watchEndedMsg struct{ err error }
+	actionDoneMsg struct {
+		verb string
+		name string
+		err  error
+	}
+)
+
+var (
+	selectedStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("212"))
+	helpStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	errorStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+)
+
+type dashboardModel struct {
+	actions DashboardActions
+	stacks  []DashboardStack
+	loaded  bool
+	cursor  int
+	// busy names the stack an action is running on; keys other than quit
+	// are ignored until it finishes.
+	busy string
+	// removing names the stack awaiting confirmation of removal.
+	removing string
+	message  string
+	err      error
+	// loadErr is the last refresh failure; it clears once docker answers.
+	loadErr error
+	// polling is set once watching events failed.
+	polling bool
+	// gen numbers the chain of periodic refreshes; ticks and loads of an
+	// older chain are dropped when polling restarts it.
+	gen int
+	// seq numbers loads as they start; applied is the newest shown.
+	seq, applied int
+	// eventLoad is the seq of the load running for container events, or 0.
+	// Events arriving meanwhile set eventPending and are served by a
+	// single load once it returns.
+	eventLoad    int
+	eventPending bool
+}
+
+func (m *dashboardModel) Init() tea.Cmd {
+	return m.load(true)
+}
+
+func (m *dashboardModel) load(poll bool) tea.Cmd {
+	m.seq++
+	gen, seq := m.gen, m.seq
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		stacks, err := m.actions.Load(ctx)
+		return stacksMsg{stacks: stacks, err: err, poll: poll, gen: gen, seq: seq}
+	}
+}
+
+// eventRefresh reloads for container events, one load at a time.
+func (m *dashboardModel) eventRefresh() tea.Cmd {
+	if m.eventLoad != 0 {
+		m.eventPending = true
+		return nil
+	}
+	cmd := m.load(false)
+	m.eventLoad = m.seq
+	return cmd
+}
+
+func (m *dashboardModel) tick() tea.Cmd {
+	interval := dashboardResync
+	if m.polling {
+		interval = dashboardRefresh
+	}
+	gen := m.gen
+	return tea.Tick(interval, func(time.Time) tea.Msg { return tickMsg{gen: gen} })
+}
+
+func (m *dashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case stacksMsg:
+		if msg.seq > m.applied {
+			m.applied = msg.seq
+			m.loaded = true
+			m.loadErr = msg.err
+			if msg.err == nil {
+				m.stacks = msg.stacks
+				if m.cursor >= len(m.stacks) {
+					m.cursor = max(len(m.stacks)-1, 0)
+				}
+			}
+		}
+		var cmds []tea.Cmd
+		if msg.seq == m.eventLoad {
+			m.eventLoad = 0
+			if m.eventPending {
+				m.eventPending = false
+				cmds = append(cmds, m.eventRefresh())
+			}
+		}
+		if msg.poll && msg.gen == m.gen {
+			cmds = append(cmds, m.tick())
+		}
+		return m, tea.Batch(cmds...)
+	case tickMsg:
+		if msg.gen != m.gen {
+			return m, nil
+		}
+		return m, m.load(true)
+	case changedMsg:
+		return m, m.eventRefresh()
+	case watchEndedMsg:
+		// Restart the refreshes at the polling interval.
+		m.polling = true
+		m.gen++
+		return m, m.load(true)
+	case actionDoneMsg:
+		m.busy = ""
+		m.err = msg.err
+		if msg.err == nil && msg.verb != "" {
+			m.message = fmt.Sprintf("%s %s", msg.verb, msg.name)
+		}
+		return m, m.load(false)
+	case tea.KeyMsg:
+		return m.handleKey(msg)
+	}
+	return m, nil
+}
+
+func (m *dashboardModel) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	key := msg.String()
+	if key == "ctrl+c" || key == "q" {
+		return m, tea.Quit
+	}
+	if m.removing != "" {
+		name := m.removing
+		m.removing = ""
+		if key != "y" {
+			m.message = "Remove canceled"
+			return m, nil
+		}
+		return m, m.run("Removed", name, m.actions.Remove)
+	}
+	if m.busy != "" {
+		return m, nil
+	}
+
+	switch key {
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+		return m, nil
+	case "down", "j":
+		if m.cursor < len(m.stacks)-1 {
+			m.cursor++
+		}
+		return m, nil
+	}
+
+	current, ok := m.selected()
+	if !ok {
+		return m, nil
+	}
+	name := current.Meta.Name
+	m.message, m.err = "", nil
+	switch key {
+	case "s":
+		return m, m.run("Started", name, m.actions.Start)
+	case "t":
+		return m, m.run("Stopped", name, m.actions.Stop)
+	case "r":
+		return m, m.run("Restarted", name, m.actions.Restart)
+	case "d":
+		m.removing = name
+	case "l":
+		return m, m.terminal(m.actions.Logs(name))
+	case "a":
+		return m, m.terminal(m.actions.Attach(name))
+	case "o":
+		url := current.Meta.Spec.InteractiveURL()
+		if url == "" {
+			url = current.Meta.Spec.ReadOnlyURL()
+		}
+		if url == "" {
+			m.err = fmt.Errorf("stack %s has no web terminal", name)
+			return m, nil
+		}
+		m.err = m.actions.Open(url)
+	}
+	return m, nil
+}
+
+func (m *dashboardModel) selected() (DashboardStack, bool) {
+	if m.cursor < 0 || m.cursor >= len(m.stacks) {
+		return DashboardStack{}, false
+	}
+	return m.stacks[m.cursor], true
+}
+
+func (m *dashboardModel) run(verb, name string, action func(context.Context, string) error) tea.Cmd {
+	m.busy = name
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+		return actionDoneMsg{verb: verb, name: name, err: action(ctx, name)}
+	}
+}
+
+func (m *dashboardModel) terminal(fn TerminalFunc) tea.Cmd {
+	return tea.Exec(&terminalCommand{fn: fn}, func(err error) tea.Msg {
+		return actionDoneMsg{err: err}
+	})
+}
+
+func (m *dashboardModel) View() string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("vibecontainer stacks"))
+	b.WriteString("\n")
+
+	switch {
+	case !m.loaded:
+		b.WriteString("  Loading...\n")
+	case len(m.stacks) == 0:
+		b.WriteString("  No managed stacks found\n")
+	default:
+		header := []string{"NAME", "PROVIDER", "STATE", "HEALTH", "TMUX", "URL"}
+		rows := make([][]string, 0, len(m.stacks))
+		for _, s := range m.stacks {
+			url := s.Meta.Spec.InteractiveURL()
+			if url == "" {
+				url = s.Meta.Spec.ReadOnlyURL()
+			}
+			rows = append(rows, []string{s.Meta.Name, string(s.Meta.Provider), joinSorted(s.States, "not-created"), joinSorted(s.Health, "-"), orDash(s.Meta.Spec.TmuxAccess), orDash(url)})
+		}
+		lines := tableLines(header, rows)
+		b.WriteString("  " + lines[0] + "\n")
+		for i, line := range lines[1:] {
+			if i == m.cursor {
+				b.WriteString(selectedStyle.Render("> "+line) + "\n")
+			} else {
+				b.WriteString("  " + line + "\n")
+			}
+		}
+	}
+
+	b.WriteString("\n")
+	switch {
+	case m.removing != "":
+		b.WriteString(errorStyle.Render(fmt.Sprintf("  Remove stack %s and its configuration? (y/N)", m.removing)))
+	case m.busy != "":
+		b.WriteString(fmt.Sprintf("  Working on %s...", m.busy))
+	case m.err != nil:
+		b.WriteString(errorStyle.Render("  Error: " + m.err.Error()))
+	case m.message != "":
+		b.WriteString("  " + m.message)
+	case m.loadErr != nil:
+		b.WriteString(errorStyle.Render("  Refresh failed: " + m.loadErr.Error()))
+	}
+	b.WriteString("\n\n")
+	b.WriteString(helpStyle.Render("  ↑/↓ select • s start • t stop • r restart • d remove • l logs • a attach • o open • q quit"))
+	b.WriteString("\n")
+	return b.String()
+}
+
+// joinSorted joins values for display, or returns empty when there are
+// none.
+func joinSorted(values []string, empty string) string {
+	var out []string
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	if len(out) == 0 {
+		return empty
+	}
+	sort.Strings(out)
+	return strings.Join(out, ",")
+}
+
+// terminalCommand adapts a TerminalFunc to tea.ExecCommand so the
+// dashboard can hand over the terminal and take it back afterwards.
+type terminalCommand struct {
+	fn     TerminalFunc
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func (c *terminalCommand) Run() error            { return c.fn(c.stdin, c.stdout, c.stderr) }
+func (c *terminalCommand) SetStdin(r io.Reader)  { c.stdin = r }
+func (c *terminalCommand) SetStdout(w io.Writer) { c.stdout = w }
+func (c *terminalCommand) SetStderr(w io.Writer) { c.stderr = w }
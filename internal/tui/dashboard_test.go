@@ -0,0 +1,116 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/openhoo/vibecontainer/internal/domain"
+)
+
+func key(s string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+func TestDashboardRemoveNeedsConfirmation(t *testing.T) {
+	removed := ""
+	m := &dashboardModel{
+		actions: DashboardActions{
+			Remove: func(ctx context.Context, name string) error { removed = name; return nil },
+		},
+		loaded: true,
+		stacks: []DashboardStack{
+			{Meta: domain.RunMetadata{Name: "one"}},
+			{Meta: domain.RunMetadata{Name: "two"}},
+		},
+	}
+
+	m.Update(key("j"))
+	m.Update(key("d"))
+	if m.removing != "two" {
+		t.Fatalf("expected removal prompt for the selected stack, got %q", m.removing)
+	}
+	if _, cmd := m.Update(key("n")); cmd != nil || m.removing != "" {
+		t.Fatalf("expected removal to be canceled")
+	}
+
+	m.Update(key("d"))
+	_, cmd := m.Update(key("y"))
+	if cmd == nil || m.busy != "two" {
+		t.Fatalf("expected remove to start")
+	}
+	done := cmd().(actionDoneMsg)
+	if removed != "two" || done.err != nil {
+		t.Fatalf("remove ran for %q: %v", removed, done.err)
+	}
+	m.Update(done)
+	if m.busy != "" || m.message != "Removed two" {
+		t.Fatalf("unexpected state after remove: busy=%q message=%q", m.busy, m.message)
+	}
+}
+
+func TestDashboardFallsBackToPolling(t *testing.T) {
+	loads := 0
+	m := &dashboardModel{
+		actions: DashboardActions{
+			Load: func(ctx context.Context) ([]DashboardStack, error) {
+				loads++
+				return []DashboardStack{{Meta: domain.RunMetadata{Name: "one"}}}, nil
+			},
+		},
+	}
+
+	// A container event reloads without starting another refresh chain.
+	_, cmd := m.Update(changedMsg{})
+	msg := cmd().(stacksMsg)
+	if loads != 1 || msg.poll {
+		t.Fatalf("expected a one-off reload, got loads=%d poll=%v", loads, msg.poll)
+	}
+
+	stale := tickMsg{gen: m.gen}
+	m.Update(watchEndedMsg{})
+	if !m.polling {
+		t.Fatal("expected the dashboard to poll once watching ends")
+	}
+	if _, cmd := m.Update(stale); cmd != nil {
+		t.Fatal("a tick from before the fallback should be dropped")
+	}
+}
+
+func TestDashboardCoalescesEventLoads(t *testing.T) {
+	loads := 0
+	m := &dashboardModel{
+		actions: DashboardActions{
+			Load: func(ctx context.Context) ([]DashboardStack, error) {
+				loads++
+				return []DashboardStack{{Meta: domain.RunMetadata{Name: fmt.Sprint("load", loads)}}}, nil
+			},
+		},
+	}
+
+	_, first := m.Update(changedMsg{})
+	for range 3 {
+		if _, cmd := m.Update(changedMsg{}); cmd != nil {
+			t.Fatal("events during a load should wait for it")
+		}
+	}
+	_, next := m.Update(first())
+	if next == nil {
+		t.Fatal("events seen during the load should trigger one more")
+	}
+	if _, cmd := m.Update(changedMsg{}); cmd != nil {
+		t.Fatal("only one load should run at a time")
+	}
+	newer := next().(stacksMsg)
+	m.Update(newer)
+	if m.stacks[0].Meta.Name != "load2" {
+		t.Fatalf("unexpected stacks %+v", m.stacks)
+	}
+
+	// A slow load that started earlier must not overwrite a newer result.
+	m.Update(stacksMsg{stacks: nil, seq: newer.seq - 1})
+	if len(m.stacks) != 1 || m.stacks[0].Meta.Name != "load2" {
+		t.Fatalf("an out-of-order result replaced a newer one: %+v", m.stacks)
+	}
+}
@@ -2,6 +2,7 @@ package tui
 
 import (
 	"fmt"
+	"net"
 	"sort"
 	"strconv"
 	"strings"
@@ -42,7 +43,29 @@ var (
 	ColumnStyle = lipgloss.NewStyle().PaddingRight(4)
 )
 
-func RunCreateWizard(defaults domain.Defaults, seed domain.CreateOptions) (Result, error) {
+// wizardMode adjusts the shared wizard for creating versus editing a stack.
+type wizardMode struct {
+	title string
+	// editName shows the stack name input; existing stacks can't be renamed.
+	editName bool
+	// review prints the configuration and asks for confirmation at the end.
+	review bool
+}
+
+// RunCreateWizard asks for the settings of a new stack, prefilled from seed.
+// checkPort, when set, rejects ports that are already taken; the ports in
+// seed are offered as they are, so the caller should seed free ones.
+func RunCreateWizard(defaults domain.Defaults, seed domain.CreateOptions, checkPort func(int) error) (Result, error) {
+	return runWizard(defaults, seed, checkPort, wizardMode{title: "Vibecontainer Setup", editName: true, review: true})
+}
+
+// RunUpdateWizard edits the settings of an existing stack, prefilled from
+// current. The caller is expected to show the resulting changes and confirm.
+func RunUpdateWizard(defaults domain.Defaults, current domain.CreateOptions, checkPort func(int) error) (Result, error) {
+	return runWizard(defaults, current, checkPort, wizardMode{title: "Update " + current.Name})
+}
+
+func runWizard(defaults domain.Defaults, seed domain.CreateOptions, checkPort func(int) error, mode wizardMode) (Result, error) {
 	opts := seed
 	if !opts.Provider.Valid() {
 		opts.Provider = defaults.Provider
@@ -68,8 +91,8 @@ func RunCreateWizard(defaults domain.Defaults, seed domain.CreateOptions) (Resul
 		tmuxAccess         = opts.TmuxAccess
 		firewall           = opts.FirewallEnable
 		tunnelEnable       = opts.TunnelEnable
-		readOnlyPortStr    = strconv.Itoa(opts.ReadOnlyPort)
-		interactivePortStr = strconv.Itoa(opts.InteractivePort)
+		readOnlyPortStr    = portString(opts.ReadOnlyPort)
+		interactivePortStr = portString(opts.InteractivePort)
 		customizeAdvanced  bool
 		claudeAuthMethod   = "oauth"
 		codexAuthMethod    = "openai"
@@ -93,8 +116,21 @@ func RunCreateWizard(defaults domain.Defaults, seed domain.CreateOptions) (Resul
 	if tmuxAccess == "" {
 		tmuxAccess = "read"
 	}
+	// New stacks, and stacks that start publishing ttyd, get a login unless
+	// declined; otherwise a stack keeps the one it has.
+	ttydLogin := opts.TTYDCredential != "none" && (mode.review || opts.TTYDCredential != "" || !tmuxExpose)
+	// Preselect the auth method that already has a credential.
+	if !hasClaudeOAuth && hasAnthropicKey {
+		claudeAuthMethod = "apikey"
+	}
+	switch {
+	case !hasOpenAIKey && hasCodexAuth:
+		codexAuthMethod = "auth_json"
+	case !hasOpenAIKey && hasCodexKey:
+		codexAuthMethod = "codex_key"
+	}
 
-	fmt.Println(titleStyle.Render("Vibecontainer Setup"))
+	fmt.Println(titleStyle.Render(mode.title))
 
 	form := huh.NewForm(
 		// Stack Name
@@ -110,7 +146,7 @@ func RunCreateWizard(defaults domain.Defaults, seed domain.CreateOptions) (Resul
 					}
 					return nil
 				}),
-		),
+		).WithHideFunc(func() bool { return !mode.editName }),
 
 		// Provider
 		huh.NewGroup(
@@ -259,6 +295,14 @@ func RunCreateWizard(defaults domain.Defaults, seed domain.CreateOptions) (Resul
 				Value(&tmuxAccess),
 		).WithHideFunc(func() bool { return !tmuxExpose }),
 
+		// ttyd login
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title("Protect the web terminal with a login?").
+				Description("A random ttyd credential is generated; required beyond this host (tunnel or a non-loopback bind address)").
+				Value(&ttydLogin),
+		).WithHideFunc(func() bool { return !tmuxExpose }),
+
 		// Cloudflare Tunnel
 		huh.NewGroup(
 			huh.NewConfirm().
@@ -287,7 +331,7 @@ func RunCreateWizard(defaults domain.Defaults, seed domain.CreateOptions) (Resul
 		huh.NewGroup(
 			huh.NewConfirm().
 				Title("Customize advanced settings?").
-				Description("Ports, firewall, image overrides").
+				Description("Ports, bind address, firewall, image overrides").
 				Value(&customizeAdvanced),
 		),
 
@@ -295,20 +339,35 @@ func RunCreateWizard(defaults domain.Defaults, seed domain.CreateOptions) (Resul
 		huh.NewGroup(
 			huh.NewInput().
 				Title("Read-only Port").
-				Description("Port for the read-only terminal view").
+				Description("Port for the read-only terminal view (auto picks a free one)").
 				Value(&readOnlyPortStr).
-				Validate(validatePort("read-only port")),
+				Validate(validatePort("read-only port", checkPort)),
 		).WithHideFunc(func() bool { return !customizeAdvanced }),
 
 		// Interactive Port
 		huh.NewGroup(
 			huh.NewInput().
 				Title("Interactive Port").
-				Description("Port for the interactive terminal").
+				Description("Port for the interactive terminal (auto picks a free one)").
 				Value(&interactivePortStr).
-				Validate(validatePort("interactive port")),
+				Validate(validatePort("interactive port", checkPort)),
 		).WithHideFunc(func() bool { return !customizeAdvanced || tmuxAccess != "write" }),
 
+		// Bind Address
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Bind Address").
+				Description("Host address for the terminal ports; a LAN or Tailscale IP needs a ttyd credential").
+				Placeholder(domain.LoopbackAddress).
+				Value(&opts.BindAddress).
+				Validate(func(s string) error {
+					if s = strings.TrimSpace(s); s != "" && net.ParseIP(s) == nil {
+						return fmt.Errorf("bind address must be an IP address")
+					}
+					return nil
+				}),
+		).WithHideFunc(func() bool { return !customizeAdvanced || !tmuxExpose }),
+
 		// Firewall
 		huh.NewGroup(
 			huh.NewConfirm().
@@ -338,13 +397,20 @@ func RunCreateWizard(defaults domain.Defaults, seed domain.CreateOptions) (Resul
 	opts.Provider = domain.Provider(provider)
 	if tmuxExpose {
 		opts.TmuxAccess = tmuxAccess
+		switch {
+		case !ttydLogin:
+			opts.TTYDCredential = "none"
+		case opts.TTYDCredential == "" || opts.TTYDCredential == "none":
+			opts.TTYDCredential = "auto"
+		}
 	} else {
 		opts.TmuxAccess = "none"
 	}
 	opts.FirewallEnable = firewall
 	opts.TunnelEnable = tunnelEnable
-	opts.ReadOnlyPort, _ = strconv.Atoi(readOnlyPortStr)
-	opts.InteractivePort, _ = strconv.Atoi(interactivePortStr)
+	opts.BindAddress = strings.TrimSpace(opts.BindAddress)
+	opts.ReadOnlyPort = parsePort(readOnlyPortStr)
+	opts.InteractivePort = parsePort(interactivePortStr)
 
 	// Sync credentials: use new value when user declined saved
 	if !useExistingClaudeOAuth {
@@ -366,6 +432,10 @@ func RunCreateWizard(defaults domain.Defaults, seed domain.CreateOptions) (Resul
 		opts.Auth.TunnelToken = newTunnelToken
 	}
 
+	if !mode.review {
+		return Result{Options: opts, OK: true}, nil
+	}
+
 	// Build auth description for review
 	authDesc := authDescription(provider, claudeAuthMethod, codexAuthMethod)
 
@@ -389,19 +459,42 @@ func RunCreateWizard(defaults domain.Defaults, seed domain.CreateOptions) (Resul
 	return Result{Options: opts, OK: true}, nil
 }
 
-func validatePort(name string) func(string) error {
+func validatePort(name string, check func(int) error) func(string) error {
 	return func(s string) error {
-		n, err := strconv.Atoi(s)
+		if strings.TrimSpace(s) == "auto" {
+			return nil
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(s))
 		if err != nil {
-			return fmt.Errorf("%s must be a number", name)
+			return fmt.Errorf("%s must be a number or auto", name)
 		}
 		if n < 1 || n > 65535 {
 			return fmt.Errorf("%s must be between 1 and 65535", name)
 		}
+		if check != nil {
+			return check(n)
+		}
 		return nil
 	}
 }
 
+// portString shows domain.AutoPort as "auto".
+func portString(port int) string {
+	if port == domain.AutoPort {
+		return "auto"
+	}
+	return strconv.Itoa(port)
+}
+
+func parsePort(s string) int {
+	s = strings.TrimSpace(s)
+	if s == "auto" {
+		return domain.AutoPort
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
 func notEmpty(msg string) func(string) error {
 	return func(s string) error {
 		if strings.TrimSpace(s) == "" {
@@ -442,6 +535,7 @@ func printReview(opts domain.CreateOptions, authDesc string) {
 	line("Stack Name:", opts.Name)
 	line("Provider:", string(opts.Provider))
 	line("Auth:", authDesc)
+	line("Auth Profile:", domain.CredentialProfile(opts.AuthProfile))
 	if opts.WorkspacePath != "" {
 		line("Workspace:", opts.WorkspacePath)
 	} else {
@@ -450,10 +544,14 @@ func printReview(opts domain.CreateOptions, authDesc string) {
 	line("Tunnel:", boolWord(opts.TunnelEnable))
 	line("Tmux Access:", opts.TmuxAccess)
 	if opts.TmuxAccess == "read" || opts.TmuxAccess == "write" {
-		line("Read-only Port:", strconv.Itoa(opts.ReadOnlyPort))
+		line("Read-only Port:", portString(opts.ReadOnlyPort))
 	}
 	if opts.TmuxAccess == "write" {
-		line("Interactive Port:", strconv.Itoa(opts.InteractivePort))
+		line("Interactive Port:", portString(opts.InteractivePort))
+	}
+	if opts.TmuxAccess == "read" || opts.TmuxAccess == "write" {
+		line("Bind Address:", domain.PublishAddress(opts.BindAddress))
+		line("TTYD Login:", ttydLoginWord(opts.TTYDCredential))
 	}
 	line("Firewall:", boolWord(opts.FirewallEnable))
 	if opts.Image != "" {
@@ -463,6 +561,17 @@ func printReview(opts domain.CreateOptions, authDesc string) {
 	fmt.Println(divider)
 }
 
+// ttydLoginWord describes a ttyd credential setting without showing it.
+func ttydLoginWord(credential string) string {
+	switch credential {
+	case "", "none":
+		return "none"
+	case "auto":
+		return "generated"
+	}
+	return "set"
+}
+
 func boolWord(v bool) string {
 	if v {
 		return "yes"
@@ -470,6 +579,53 @@ func boolWord(v bool) string {
 	return "no"
 }
 
+// PromptSecret asks for a secret without echoing it. It returns "" when
+// the user aborts.
+func PromptSecret(title string) (string, error) {
+	value := ""
+	err := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title(title).
+				EchoMode(huh.EchoModePassword).
+				Value(&value),
+		),
+	).WithTheme(huh.ThemeCharm()).Run()
+	if err != nil {
+		if err == huh.ErrUserAborted {
+			return "", nil
+		}
+		return "", err
+	}
+	return value, nil
+}
+
+// SelectProfile asks which credential profile to use, starting at current.
+// It returns "" when the user aborts.
+func SelectProfile(profiles []string, current string) (string, error) {
+	options := make([]huh.Option[string], 0, len(profiles))
+	for _, p := range profiles {
+		options = append(options, huh.NewOption(p, p))
+	}
+	selected := current
+	err := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title("Credential Profile").
+				Description("Saved credentials are loaded from and saved to this profile").
+				Options(options...).
+				Value(&selected),
+		),
+	).WithTheme(huh.ThemeCharm()).Run()
+	if err != nil {
+		if err == huh.ErrUserAborted {
+			return "", nil
+		}
+		return "", err
+	}
+	return selected, nil
+}
+
 func Confirm(title string, description string, defaultYes bool) (bool, error) {
 	confirm := defaultYes
 	err := huh.NewForm(
@@ -490,8 +646,13 @@ func Confirm(title string, description string, defaultYes bool) (bool, error) {
 	return confirm, nil
 }
 
-func RenderList(metas []domain.RunMetadata, stateByStack map[string][]string) {
-	header := []string{"NAME", "PROVIDER", "STATE", "UPDATED"}
+// RenderList prints one row per stack. Wide output adds the image and
+// workspace.
+func RenderList(metas []domain.RunMetadata, stateByStack map[string][]string, wide bool) {
+	header := []string{"NAME", "PROVIDER", "STATE", "TMUX", "URL", "UPDATED"}
+	if wide {
+		header = append(header, "IMAGE", "WORKSPACE")
+	}
 	rows := [][]string{}
 
 	for _, m := range metas {
@@ -501,14 +662,54 @@ func RenderList(metas []domain.RunMetadata, stateByStack map[string][]string) {
 		if len(states) > 0 {
 			state = strings.Join(states, ",")
 		}
-		rows = append(rows, []string{m.Name, string(m.Provider), state, fmtTime(m.UpdatedAt)})
+		url := m.Spec.InteractiveURL()
+		if url == "" {
+			url = m.Spec.ReadOnlyURL()
+		}
+		row := []string{m.Name, string(m.Provider), state, orDash(m.Spec.TmuxAccess), orDash(url), fmtTime(m.UpdatedAt)}
+		if wide {
+			row = append(row, m.Image, orDash(m.Workspace))
+		}
+		rows = append(rows, row)
 	}
 
 	renderTable(header, rows)
 }
 
-func RenderStatus(statuses []domain.ServiceStatus) {
+// RenderStackInfo prints the settings a stack was created with.
+func RenderStackInfo(meta domain.RunMetadata) {
+	line := func(label, value string) {
+		fmt.Printf("%s %s\n", reviewLabelStyle.Render(label), value)
+	}
+	line("Stack:", meta.Name)
+	line("Provider:", string(meta.Provider))
+	line("Image:", meta.Image)
+	if meta.Workspace != "" {
+		line("Workspace:", meta.Workspace)
+	} else {
+		line("Workspace:", "(not mapped)")
+	}
+	line("Tmux Access:", meta.Spec.TmuxAccess)
+	if url := meta.Spec.ReadOnlyURL(); url != "" {
+		line("Read-only URL:", url)
+	}
+	if url := meta.Spec.InteractiveURL(); url != "" {
+		line("Interactive URL:", url)
+	}
+	line("TTYD Credential:", boolWord(meta.Spec.TTYDCredentialSet))
+	line("Firewall:", boolWord(meta.Spec.FirewallEnable))
+	line("Tunnel:", boolWord(meta.Spec.TunnelEnable))
+	line("Auth Profile:", domain.CredentialProfile(meta.Spec.AuthProfile))
+	fmt.Println()
+}
+
+// RenderStatus prints one row per service. Wide output adds the image and
+// published ports.
+func RenderStatus(statuses []domain.ServiceStatus, wide bool) {
 	header := []string{"SERVICE", "STATE", "HEALTH"}
+	if wide {
+		header = append(header, "IMAGE", "PORTS")
+	}
 	rows := [][]string{}
 
 	for _, s := range statuses {
@@ -516,13 +717,31 @@ func RenderStatus(statuses []domain.ServiceStatus) {
 		if health == "" {
 			health = "-"
 		}
-		rows = append(rows, []string{s.Name, s.State, health})
+		row := []string{s.Name, s.State, health}
+		if wide {
+			var ports []string
+			for _, p := range s.Publishers {
+				if p.PublishedPort != 0 {
+					ports = append(ports, fmt.Sprintf("%s:%d->%d/%s", p.URL, p.PublishedPort, p.TargetPort, p.Protocol))
+				}
+			}
+			row = append(row, orDash(s.Image), orDash(strings.Join(ports, ",")))
+		}
+		rows = append(rows, row)
 	}
 
 	renderTable(header, rows)
 }
 
 func renderTable(header []string, rows [][]string) {
+	for _, line := range tableLines(header, rows) {
+		fmt.Println(line)
+	}
+}
+
+// tableLines lays out a table as its header line followed by one line per
+// row.
+func tableLines(header []string, rows [][]string) []string {
 	colWidths := make([]int, len(header))
 	for i, h := range header {
 		colWidths[i] = len(h)
@@ -536,19 +755,31 @@ func renderTable(header []string, rows [][]string) {
 		}
 	}
 
+	lines := make([]string, 0, len(rows)+1)
+
 	// Render header
+	var line strings.Builder
 	for i, h := range header {
-		fmt.Print(HeaderStyle.Width(colWidths[i] + 4).Render(h))
+		line.WriteString(HeaderStyle.Width(colWidths[i] + 4).Render(h))
 	}
-	fmt.Println()
+	lines = append(lines, line.String())
 
 	// Render rows
 	for _, row := range rows {
+		line.Reset()
 		for i, cell := range row {
-			fmt.Print(lipgloss.NewStyle().Width(colWidths[i] + 4).Render(cell))
+			line.WriteString(lipgloss.NewStyle().Width(colWidths[i] + 4).Render(cell))
 		}
-		fmt.Println()
+		lines = append(lines, line.String())
+	}
+	return lines
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
 	}
+	return s
 }
 
 func fmtTime(t time.Time) string {
@@ -4,15 +4,23 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
 
 	"github.com/openhoo/vibecontainer/internal/domain"
+	"github.com/openhoo/vibecontainer/internal/keyring"
+	"github.com/openhoo/vibecontainer/internal/secretref"
 )
 
-var stackNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,29}[a-z0-9]$`)
+var (
+	stackNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,29}[a-z0-9]$`)
+	envKeyRe    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	profileRe   = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+)
 
 func CreateOptions(opts domain.CreateOptions) error {
 	if !stackNameRe.MatchString(opts.Name) {
@@ -21,6 +29,11 @@ func CreateOptions(opts domain.CreateOptions) error {
 	if !opts.Provider.Valid() {
 		return errors.New("provider must be one of: base, claude, codex")
 	}
+	if opts.AuthProfile != "" {
+		if err := AuthProfile(opts.AuthProfile); err != nil {
+			return err
+		}
+	}
 	if strings.TrimSpace(opts.WorkspacePath) != "" {
 		info, err := os.Stat(opts.WorkspacePath)
 		if err != nil {
@@ -56,6 +69,36 @@ func CreateOptions(opts domain.CreateOptions) error {
 			return errors.New("ttyd credential must be in user:password format and contain no spaces")
 		}
 	}
+	if err := BindAddress(opts.BindAddress); err != nil {
+		return err
+	}
+	if opts.TmuxAccess == "read" || opts.TmuxAccess == "write" {
+		addr := domain.PublishAddress(opts.BindAddress)
+		if !IsLoopback(addr) && opts.TTYDCredential == "" {
+			return fmt.Errorf("a ttyd credential (--ttyd-credential user:password) is required to publish ttyd on %s", addr)
+		}
+		if opts.TmuxAccess == "write" && IsPublic(addr) && !opts.ForcePublicWrite {
+			return fmt.Errorf("interactive access on %s, which may be reachable from the internet, needs --force-public-write; or use --tmux-access read or a private bind address", addr)
+		}
+	}
+	for k := range opts.Env {
+		if !envKeyRe.MatchString(k) {
+			return fmt.Errorf("env %q is not a valid variable name", k)
+		}
+		if domain.IsManagedEnv(k) {
+			return fmt.Errorf("env %q is managed by vibecontainer and can't be overridden", k)
+		}
+	}
+	for _, m := range opts.Mounts {
+		if err := Mount(m); err != nil {
+			return err
+		}
+	}
+	for env, ref := range opts.Auth.Refs() {
+		if err := secretref.Check(ref); err != nil {
+			return fmt.Errorf("%s: %w", env, err)
+		}
+	}
 	if opts.TunnelEnable {
 		if strings.TrimSpace(opts.Auth.TunnelToken) == "" {
 			return errors.New("tunnel token is required when tunnel is enabled")
@@ -69,8 +112,11 @@ func CreateOptions(opts domain.CreateOptions) error {
 		}
 	case domain.ProviderCodex:
 		if strings.TrimSpace(opts.Auth.CodexAuthJSON) != "" {
-			if err := ValidateCodexAuthJSON(opts.Auth.CodexAuthJSON); err != nil {
-				return err
+			// A reference is checked by ResolvedAuth once it is resolved.
+			if !domain.IsSecretRef(opts.Auth.CodexAuthJSON) {
+				if err := ValidateCodexAuthJSON(opts.Auth.CodexAuthJSON); err != nil {
+					return err
+				}
 			}
 		} else if strings.TrimSpace(opts.Auth.OpenAIAPIKey) == "" && strings.TrimSpace(opts.Auth.CodexAPIKey) == "" {
 			return errors.New("codex requires CODEX_AUTH_JSON or OPENAI_API_KEY or CODEX_API_KEY")
@@ -80,6 +126,57 @@ func CreateOptions(opts domain.CreateOptions) error {
 	return nil
 }
 
+// AuthProfile checks the name of a credential profile.
+func AuthProfile(name string) error {
+	if !profileRe.MatchString(name) {
+		return fmt.Errorf("auth profile %q must be 1-32 chars of lowercase alphanumerics, hyphens or underscores", name)
+	}
+	return nil
+}
+
+// Credential checks a value about to be stored under a keyring credential
+// key. Codex auth JSON must parse; tokens and keys must be a single word.
+// A secret reference is checked for syntax only.
+func Credential(key, value string) error {
+	if strings.TrimSpace(value) == "" {
+		return fmt.Errorf("%s is empty", key)
+	}
+	if domain.IsSecretRef(value) {
+		return secretref.Check(value)
+	}
+	if key == keyring.KeyCodexAuthJSON {
+		return ValidateCodexAuthJSON(value)
+	}
+	if strings.ContainsAny(value, " \t\r\n") {
+		return fmt.Errorf("%s must not contain spaces or line breaks", key)
+	}
+	return nil
+}
+
+// ResolvedAuth checks the credentials in auth that were resolved from the
+// secret references in refs, which could only be checked for syntax
+// before. Codex auth JSON must parse; tokens and keys must be a single
+// word.
+func ResolvedAuth(auth domain.Auth, refs map[string]string) error {
+	_, err := auth.Map(func(env, value string) (string, error) {
+		ref, ok := refs[env]
+		if !ok {
+			return value, nil
+		}
+		var err error
+		if env == "CODEX_AUTH_JSON" {
+			err = ValidateCodexAuthJSON(value)
+		} else if strings.ContainsAny(value, " \t\r\n") {
+			err = errors.New("must not contain spaces or line breaks")
+		}
+		if err != nil {
+			return "", fmt.Errorf("%s resolved from %s: %w", env, ref, err)
+		}
+		return value, nil
+	})
+	return err
+}
+
 func ValidateCodexAuthJSON(payload string) error {
 	var parsed map[string]any
 	if err := json.Unmarshal([]byte(payload), &parsed); err != nil {
@@ -114,6 +211,58 @@ func ValidateCodexAuthJSON(payload string) error {
 	return nil
 }
 
+// Mount checks a bind mount in host:container[:ro|rw] form.
+func Mount(m string) error {
+	parts := strings.Split(m, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return fmt.Errorf("mount %q must be in host:container[:ro|rw] format", m)
+	}
+	if !filepath.IsAbs(parts[0]) {
+		return fmt.Errorf("mount %q: host path must be absolute", m)
+	}
+	if !strings.HasPrefix(parts[1], "/") {
+		return fmt.Errorf("mount %q: container path must be absolute", m)
+	}
+	if path.Clean(parts[1]) == "/workspace" {
+		return fmt.Errorf("mount %q: /workspace is reserved for the workspace path", m)
+	}
+	if len(parts) == 3 && parts[2] != "ro" && parts[2] != "rw" {
+		return fmt.Errorf("mount %q: mode must be ro or rw", m)
+	}
+	return nil
+}
+
+// BindAddress checks a bind address setting: empty or an IP address.
+func BindAddress(addr string) error {
+	if addr != "" && net.ParseIP(addr) == nil {
+		return fmt.Errorf("bind address %q must be an IP address such as 127.0.0.1, 0.0.0.0 or a LAN or Tailscale address", addr)
+	}
+	return nil
+}
+
+// IsLoopback reports whether addr only accepts connections from this host.
+func IsLoopback(addr string) bool {
+	ip := net.ParseIP(addr)
+	return ip != nil && ip.IsLoopback()
+}
+
+// tailnet is the shared address space Tailscale and carrier-grade NAT use.
+var tailnet = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// IsPublic reports whether addr may be reachable from the internet: all
+// interfaces, or a global address outside the private, link-local and
+// Tailscale ranges.
+func IsPublic(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil || ip.IsLoopback() {
+		return false
+	}
+	if ip.IsUnspecified() {
+		return true
+	}
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !tailnet.Contains(ip)
+}
+
 func Port(name string, val int) error {
 	if val < 1 || val > 65535 {
 		return fmt.Errorf("%s must be between 1 and 65535", name)
@@ -1,9 +1,11 @@
 package validate
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/openhoo/vibecontainer/internal/domain"
+	"github.com/openhoo/vibecontainer/internal/keyring"
 )
 
 func TestCreateOptionsCodexValid(t *testing.T) {
@@ -112,3 +114,122 @@ func TestCreateOptionsValidWithoutTunnel(t *testing.T) {
 		t.Fatalf("expected valid options without tunnel, got %v", err)
 	}
 }
+
+func TestCreateOptionsRejectsManagedEnv(t *testing.T) {
+	opts := domain.CreateOptions{
+		Name:       "demo-stack",
+		Provider:   domain.ProviderBase,
+		TmuxAccess: "none",
+		Env:        map[string]string{"FIREWALL_ENABLE": "0"},
+	}
+	if err := CreateOptions(opts); err == nil {
+		t.Fatal("expected error for managed env key")
+	}
+}
+
+func TestMount(t *testing.T) {
+	for _, m := range []string{"/a:/b", "/a:/b:ro", "/a:/home/dev/.ssh:rw"} {
+		if err := Mount(m); err != nil {
+			t.Errorf("expected %q to be valid, got %v", m, err)
+		}
+	}
+	for _, m := range []string{"a:/b", "/a:b", "/a:/b:rx", "/a", "/a:/workspace"} {
+		if err := Mount(m); err == nil {
+			t.Errorf("expected %q to be rejected", m)
+		}
+	}
+}
+
+func TestCreateOptionsBindAddress(t *testing.T) {
+	base := domain.CreateOptions{
+		Name:            "demo-stack",
+		Provider:        domain.ProviderBase,
+		ReadOnlyPort:    7681,
+		InteractivePort: 7682,
+		TmuxAccess:      "write",
+	}
+	cases := []struct {
+		name    string
+		bind    string
+		cred    string
+		force   bool
+		wantErr string
+	}{
+		{name: "loopback needs no credential", bind: "127.0.0.1"},
+		{name: "ipv6 loopback", bind: "::1"},
+		{name: "hostname", bind: "example.com", wantErr: "must be an IP address"},
+		{name: "lan without credential", bind: "192.168.1.10", wantErr: "ttyd credential"},
+		{name: "lan write", bind: "192.168.1.10", cred: "dev:secret"},
+		{name: "tailscale write", bind: "100.101.102.103", cred: "dev:secret"},
+		{name: "all interfaces write", bind: "0.0.0.0", cred: "dev:secret", wantErr: "--force-public-write"},
+		{name: "public write", bind: "203.0.113.7", cred: "dev:secret", wantErr: "--force-public-write"},
+		{name: "public write forced", bind: "203.0.113.7", cred: "dev:secret", force: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := base
+			opts.BindAddress = tc.bind
+			opts.TTYDCredential = tc.cred
+			opts.ForcePublicWrite = tc.force
+			err := CreateOptions(opts)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("expected valid options, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("got %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+
+	opts := base
+	opts.TmuxAccess = "read"
+	opts.BindAddress = "0.0.0.0"
+	opts.TTYDCredential = "dev:secret"
+	if err := CreateOptions(opts); err != nil {
+		t.Fatalf("read-only access on all interfaces should be allowed: %v", err)
+	}
+}
+
+func TestCredential(t *testing.T) {
+	cases := []struct {
+		key, value string
+		ok         bool
+	}{
+		{keyring.KeyAnthropicAPIKey, "sk-ant-123", true},
+		{keyring.KeyAnthropicAPIKey, "  ", false},
+		{keyring.KeyTunnelToken, "eyJh bc", false},
+		{keyring.KeyCodexAuthJSON, `{"OPENAI_API_KEY":"sk-1"}`, true},
+		{keyring.KeyCodexAuthJSON, `{"auth_mode":"apikey"}`, false},
+		{keyring.KeyCodexAuthJSON, "not json", false},
+		{keyring.KeyCodexAuthJSON, "op://dev/codex/auth.json", true},
+		{keyring.KeyOpenAIAPIKey, "pass:work/openai", true},
+		{keyring.KeyOpenAIAPIKey, "op://dev/openai", false},
+	}
+	for _, tc := range cases {
+		if err := Credential(tc.key, tc.value); (err == nil) != tc.ok {
+			t.Errorf("Credential(%s, %q): got %v, want ok=%v", tc.key, tc.value, err, tc.ok)
+		}
+	}
+}
+
+func TestResolvedAuth(t *testing.T) {
+	refs := map[string]string{"CODEX_AUTH_JSON": "op://dev/codex/auth.json", "OPENAI_API_KEY": "env:KEY"}
+	cases := []struct {
+		auth domain.Auth
+		ok   bool
+	}{
+		{domain.Auth{CodexAuthJSON: `{"OPENAI_API_KEY":"sk-1"}`, OpenAIAPIKey: "sk-1"}, true},
+		{domain.Auth{CodexAuthJSON: "not json"}, false},
+		{domain.Auth{OpenAIAPIKey: "sk 1"}, false},
+		// Values given directly were checked before they were saved.
+		{domain.Auth{TunnelToken: "eyJh bc"}, true},
+	}
+	for _, tc := range cases {
+		if err := ResolvedAuth(tc.auth, refs); (err == nil) != tc.ok {
+			t.Errorf("ResolvedAuth(%+v): got %v, want ok=%v", tc.auth, err, tc.ok)
+		}
+	}
+}